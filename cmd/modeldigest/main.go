@@ -15,13 +15,21 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"math"
+	"math/big"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
 
 	modeldigest "github.com/carabiner-dev/model-signing/internal/serializer/dir"
 	"github.com/carabiner-dev/model-signing/internal/serializer/options"
+	intoto "github.com/in-toto/attestation/go/v1"
 )
 
 type arrayFlags []string
@@ -35,33 +43,183 @@ func (i *arrayFlags) Set(value string) error {
 	return nil
 }
 
+// sizeUnits are the suffixes accepted by parseSize, longest first so
+// "MB" is not read as "B".
+var sizeUnits = []struct {
+	suffix string
+	bytes  int64
+}{
+	{"KIB", 1 << 10}, {"MIB", 1 << 20}, {"GIB", 1 << 30}, {"TIB", 1 << 40},
+	{"KB", 1e3}, {"MB", 1e6}, {"GB", 1e9}, {"TB", 1e12},
+	{"B", 1},
+}
+
+// parseSize parses a human size like 500MB or 2GiB into bytes. Decimal
+// units are powers of 1000, binary ones of 1024, a bare number is bytes.
+// The number is decimal, without sign or exponent, fractions of a byte
+// are dropped, and the size must fit in an int64. It is computed with
+// integers, as floats lose precision above 2^53.
+func parseSize(value string) (int64, error) {
+	number, multiplier := strings.TrimSpace(value), int64(1)
+	for _, unit := range sizeUnits {
+		if strings.HasSuffix(strings.ToUpper(number), unit.suffix) {
+			number, multiplier = strings.TrimSpace(number[:len(number)-len(unit.suffix)]), unit.bytes
+			break
+		}
+	}
+	whole, fraction, _ := strings.Cut(number, ".")
+	if whole+fraction == "" || strings.Trim(whole+fraction, "0123456789") != "" {
+		return 0, fmt.Errorf("invalid size %q", value)
+	}
+
+	var size int64
+	if whole != "" {
+		n, err := strconv.ParseInt(whole, 10, 64)
+		if err != nil || n > math.MaxInt64/multiplier {
+			return 0, fmt.Errorf("size %q is too large", value)
+		}
+		size = n * multiplier
+	}
+	if fraction != "" {
+		// fraction * multiplier / 10^digits, below multiplier
+		n, _ := new(big.Int).SetString(fraction, 10)
+		n.Mul(n, big.NewInt(multiplier))
+		n.Quo(n, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(len(fraction))), nil))
+		if size > math.MaxInt64-n.Int64() {
+			return 0, fmt.Errorf("size %q is too large", value)
+		}
+		size += n.Int64()
+	}
+	return size, nil
+}
+
+// algorithmNames returns the supported hash algorithms as a comma-separated
+// list for the help of -algorithm.
+func algorithmNames() string {
+	names := make([]string, 0, len(modeldigest.SupportedAlgorithms()))
+	for _, algo := range modeldigest.SupportedAlgorithms() {
+		names = append(names, string(algo))
+	}
+	return strings.Join(names, ", ")
+}
+
+// jsonOutput is the structured output printed with -json.
+type jsonOutput struct {
+	RootDigest string                `json:"rootDigest"`
+	Manifest   *modeldigest.Manifest `json:"manifest"`
+}
+
 func main() {
-	var ignorePaths arrayFlags
-	ignoreGitPaths := flag.Bool("ignore-git-paths", true, "Ignore git-related files")
-	allowSymlinks := flag.Bool("allow-symlinks", false, "Allow following symlinks")
+	os.Exit(run(os.Args[0], os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run runs the command named name with the arguments args, writing its
+// output to stdout unless -output is set, and returns its exit code.
+func run(name string, args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	var ignorePaths, ignoreExtensions arrayFlags
+	ignoreGitPaths := flags.Bool("ignore-git-paths", true, "Ignore git-related files")
+	allowSymlinks := flags.Bool("allow-symlinks", false, "Allow following symlinks")
+	jsonFlag := flags.Bool("json", false, "Print the manifest and root digest as JSON")
+	output := flags.String("output", "", "Write the output to this file instead of stdout")
+	threads := flags.Int("threads", 0, "Number of files hashed in parallel, 0 for one per CPU (the digest does not depend on it)")
+	algorithm := flags.String("algorithm", string(intoto.AlgorithmSHA256), "Hash algorithm of the file and root digests, one of "+algorithmNames())
+	excludeLargerThan := flags.String("exclude-larger-than", "", "Ignore files larger than this size, like 500MB or 2GiB")
 
-	flag.Var(&ignorePaths, "ignore-paths", "File paths to ignore (can be specified multiple times)")
-	flag.Parse()
+	flags.Var(&ignorePaths, "ignore-paths", "Paths to ignore, relative to the model root (can be specified multiple times)")
+	flags.Var(&ignoreExtensions, "ignore-extensions", "File extensions to ignore, like .log (can be specified multiple times)")
+	if err := flags.Parse(args); err != nil {
+		// Exit as flag.ExitOnError does, the error is already printed
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
 
-	if flag.NArg() != 1 {
-		fmt.Fprintf(os.Stderr, "Usage: %s [OPTIONS] MODEL_PATH\n", os.Args[0])
-		flag.PrintDefaults()
-		os.Exit(1)
+	if flags.NArg() != 1 {
+		fmt.Fprintf(stderr, "Usage: %s [OPTIONS] MODEL_PATH\n", name)
+		flags.PrintDefaults()
+		return 1
 	}
 
-	modelPath := flag.Arg(0)
+	modelPath := flags.Arg(0)
+
+	if *threads < 0 {
+		fmt.Fprintf(stderr, "Error: -threads must not be negative\n")
+		return 1
+	}
+
+	var maxFileSize int64
+	if *excludeLargerThan != "" {
+		var err error
+		maxFileSize, err = parseSize(*excludeLargerThan)
+		if err != nil || maxFileSize == 0 {
+			fmt.Fprintf(stderr, "Error: invalid -exclude-larger-than size %q\n", *excludeLargerThan)
+			return 1
+		}
+	}
 
-	opts := &options.Options{
-		IgnorePaths:    ignorePaths,
-		IgnoreGitPaths: *ignoreGitPaths,
-		AllowSymlinks:  *allowSymlinks,
+	algo := intoto.HashAlgorithm(*algorithm)
+	if !slices.Contains(modeldigest.SupportedAlgorithms(), algo) {
+		fmt.Fprintf(stderr, "Error: unsupported hash algorithm %q\n", *algorithm)
+		return 1
 	}
 
-	digest, err := modeldigest.ComputeDigest(modelPath, opts)
+	opts := options.Default()
+	opts.IgnorePaths = ignorePaths
+	opts.IgnoreExtensions = ignoreExtensions
+	opts.IgnoreGitPaths = *ignoreGitPaths
+	opts.SymlinkPolicy = options.SymlinkReject
+	opts.HashAlgorithm = algo
+	opts.Concurrency = *threads
+	opts.MaxFileSize = maxFileSize
+	if *allowSymlinks {
+		opts.SymlinkPolicy = options.SymlinkFollowInternal
+	}
+
+	manifest, err := modeldigest.New(opts).Serialize(modelPath)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error computing digest: %v\n", err)
-		os.Exit(1)
+		fmt.Fprintf(stderr, "Error computing digest: %v\n", err)
+		return 1
 	}
 
-	fmt.Println(digest)
+	rootDigest, err := modeldigest.ComputeRootDigest(manifest)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error computing digest: %v\n", err)
+		return 1
+	}
+	digest := string(manifest.HashAlgorithm) + ":" + rootDigest
+
+	out := stdout
+	var file *os.File
+	if *output != "" {
+		file, err = os.Create(*output)
+		if err != nil {
+			fmt.Fprintf(stderr, "Error opening output file: %v\n", err)
+			return 1
+		}
+		out = file
+	}
+
+	if *jsonFlag {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(jsonOutput{RootDigest: digest, Manifest: manifest})
+	} else {
+		_, err = fmt.Fprintln(out, digest)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "Error writing output: %v\n", err)
+		return 1
+	}
+
+	if file != nil {
+		if err := file.Close(); err != nil {
+			fmt.Fprintf(stderr, "Error writing output: %v\n", err)
+			return 1
+		}
+	}
+	return 0
 }
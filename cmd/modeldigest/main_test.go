@@ -0,0 +1,109 @@
+// Copyright 2025 The Sigstore Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	modelPath := t.TempDir()
+	if err := os.WriteFile(filepath.Join(modelPath, "model.bin"), []byte("weights"), 0o644); err != nil {
+		t.Fatalf("Failed to write model.bin: %v", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := run("modeldigest", []string{modelPath}, &stdout, &stderr); code != 0 {
+		t.Fatalf("Expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	if !strings.HasPrefix(stdout.String(), "sha256:") {
+		t.Errorf("Expected a sha256 root digest, got %q", stdout.String())
+	}
+
+	t.Run("control characters", func(t *testing.T) {
+		if err := os.WriteFile(filepath.Join(modelPath, "evil\nname.bin"), []byte("evil"), 0o644); err != nil {
+			t.Skipf("File system does not support newlines in names: %v", err)
+		}
+		var stdout, stderr bytes.Buffer
+		if code := run("modeldigest", []string{modelPath}, &stdout, &stderr); code != 1 {
+			t.Errorf("Expected exit code 1, got %d", code)
+		}
+		if !strings.Contains(stderr.String(), "unsafe file path") {
+			t.Errorf("Expected an unsafe file path error, got %q", stderr.String())
+		}
+		if stdout.Len() != 0 {
+			t.Errorf("Expected no digest, got %q", stdout.String())
+		}
+	})
+}
+
+func TestParseSize(t *testing.T) {
+	for _, tc := range []struct {
+		value   string
+		size    int64
+		invalid bool
+	}{
+		{value: "0", size: 0},
+		{value: "512", size: 512},
+		{value: "512B", size: 512},
+		{value: "500MB", size: 500_000_000},
+		{value: "500mb", size: 500_000_000},
+		{value: "2GiB", size: 2 << 30},
+		{value: "1.5 KiB", size: 1536},
+		{value: " 1TB ", size: 1_000_000_000_000},
+		{value: "8388607TiB", size: 8388607 << 40},
+		{value: "8388608TiB", invalid: true},
+		{value: "9223372036854775807", size: math.MaxInt64},
+		{value: "9223372036854775808", invalid: true},
+		{value: "9007199254740993", size: 9007199254740993},
+		{value: "8388607.9999999999999999TiB", size: math.MaxInt64},
+		{value: "9223372036854775.807KB", size: math.MaxInt64},
+		{value: "9223372036854775.808KB", invalid: true},
+		{value: "0.0009765625KiB", size: 1},
+		{value: "1.9", size: 1},
+		{value: ".5KB", size: 500},
+		{value: "1.", size: 1},
+		{value: ".", invalid: true},
+		{value: "9300000TB", invalid: true},
+		{value: "1e400", invalid: true},
+		{value: "1e3", invalid: true},
+		{value: "Inf", invalid: true},
+		{value: "+Inf", invalid: true},
+		{value: "NaN", invalid: true},
+		{value: "0x1p10", invalid: true},
+		{value: "-1", invalid: true},
+		{value: "-1MB", invalid: true},
+		{value: "+1", invalid: true},
+		{value: "", invalid: true},
+		{value: "MB", invalid: true},
+		{value: "1.2.3", invalid: true},
+		{value: "ten", invalid: true},
+	} {
+		size, err := parseSize(tc.value)
+		switch {
+		case tc.invalid && err == nil:
+			t.Errorf("parseSize(%q): expected an error, got %d", tc.value, size)
+		case !tc.invalid && err != nil:
+			t.Errorf("parseSize(%q) failed: %v", tc.value, err)
+		case !tc.invalid && size != tc.size:
+			t.Errorf("parseSize(%q): expected %d, got %d", tc.value, tc.size, size)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package dir
+
+import (
+	"crypto/sha3"
+	"hash"
+	"slices"
+	"sync"
+
+	"github.com/carabiner-dev/hasher"
+	"github.com/carabiner-dev/model-signing/internal/serializer/options"
+	intoto "github.com/in-toto/attestation/go/v1"
+	"github.com/zeebo/blake3"
+)
+
+// supportedAlgorithms are the algorithms the default hasher computes.
+// The other algorithms of the hasher library are left out on purpose:
+// MD5 and SHA-1 are broken, and the digests of the git and dirHash ones
+// are not hashes of the file contents.
+var supportedAlgorithms = []intoto.HashAlgorithm{
+	intoto.AlgorithmSHA256,
+	intoto.AlgorithmSHA384,
+	intoto.AlgorithmSHA512,
+	intoto.AlgorithmSHA3_256,
+	intoto.AlgorithmSHA3_384,
+	intoto.AlgorithmSHA3_512,
+	options.AlgorithmBLAKE3,
+}
+
+// localHashers computes the supported algorithms the hasher library does
+// not: BLAKE3, which it lacks, and SHA3-384, which it maps to SHA-384.
+var localHashers = map[intoto.HashAlgorithm]func() hash.Hash{
+	intoto.AlgorithmSHA3_384: func() hash.Hash { return sha3.New384() },
+	options.AlgorithmBLAKE3:  func() hash.Hash { return blake3.New() },
+}
+
+// SupportedAlgorithms returns the hash algorithms the default hasher
+// supports: SHA-256, SHA-384, SHA-512, SHA3-256, SHA3-384, SHA3-512 and
+// BLAKE3.
+func SupportedAlgorithms() []intoto.HashAlgorithm {
+	return slices.Clone(supportedAlgorithms)
+}
+
+// newHasher returns a new hash.Hash computing algo with the hasher
+// library, or nil if the algorithm is not one of SupportedAlgorithms.
+func newHasher(algo intoto.HashAlgorithm) hash.Hash {
+	if !slices.Contains(supportedAlgorithms, algo) {
+		return nil
+	}
+	if newHash, ok := localHashers[algo]; ok {
+		return newHash()
+	}
+	return hasher.HasherFactory.GetHasher(algo)
+}
+
+// hasherPools holds a *sync.Pool of hashers per supported algorithm,
+// shared by every Serializer to avoid allocating a hasher per file.
+var hasherPools sync.Map
+
+// getHasher returns a reset hasher of algo from its pool, or nil if the
+// algorithm is not supported. Return it with putHasher once done.
+func getHasher(algo intoto.HashAlgorithm) hash.Hash {
+	pool, ok := hasherPools.Load(algo)
+	if !ok {
+		h := newHasher(algo)
+		if h == nil {
+			return nil
+		}
+		hasherPools.LoadOrStore(algo, &sync.Pool{
+			New: func() any { return newHasher(algo) },
+		})
+		return h
+	}
+	h := pool.(*sync.Pool).Get().(hash.Hash)
+	h.Reset()
+	return h
+}
+
+// putHasher returns a hasher of algo obtained with getHasher to its pool.
+func putHasher(algo intoto.HashAlgorithm, h hash.Hash) {
+	if pool, ok := hasherPools.Load(algo); ok {
+		pool.(*sync.Pool).Put(h)
+	}
+}
+
+// DefaultHasher returns the Hasher used when the options set none, the
+// carabiner hasher library. It supports the SupportedAlgorithms. Custom hashers can wrap it to only
+// handle some algorithms themselves.
+func DefaultHasher() options.Hasher {
+	return defaultHasher{}
+}
+
+// defaultHasher implements options.Hasher with newHasher.
+type defaultHasher struct{}
+
+func (defaultHasher) NewHash(algo intoto.HashAlgorithm) hash.Hash {
+	return newHasher(algo)
+}
+
+// newHash returns a new hash of algo from the configured Hasher, or nil
+// if it does not support the algorithm. The default hashers are pooled,
+// return them with releaseHash.
+func (s *Serializer) newHash(algo intoto.HashAlgorithm) hash.Hash {
+	if s.opts.Hasher != nil {
+		return s.opts.Hasher.NewHash(algo)
+	}
+	return getHasher(algo)
+}
+
+// releaseHash returns a hash obtained with newHash once it is done.
+func (s *Serializer) releaseHash(algo intoto.HashAlgorithm, h hash.Hash) {
+	if s.opts.Hasher == nil {
+		putHasher(algo, h)
+	}
+}
@@ -0,0 +1,199 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package dir
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/carabiner-dev/model-signing/internal/serializer/options"
+	intoto "github.com/in-toto/attestation/go/v1"
+)
+
+// benchmarkFileSize is the size of the large file fixture, 2 GiB.
+const benchmarkFileSize = 2 << 30
+
+// largeFileFixture creates a model directory holding a single file of
+// size bytes. The file is sparse so creating it is cheap, reading it goes
+// through the page cache like any other cached file.
+func largeFileFixture(b *testing.B, size int64) string {
+	b.Helper()
+
+	dir := b.TempDir()
+	f, err := os.Create(filepath.Join(dir, "model.safetensors"))
+	if err != nil {
+		b.Fatalf("Failed to create fixture: %v", err)
+	}
+	if err := f.Truncate(size); err != nil {
+		b.Fatalf("Failed to size fixture: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		b.Fatalf("Failed to close fixture: %v", err)
+	}
+	return dir
+}
+
+func BenchmarkHashAlgorithm(b *testing.B) {
+	dir := largeFileFixture(b, benchmarkFileSize)
+
+	for _, algo := range []intoto.HashAlgorithm{intoto.AlgorithmSHA256, options.AlgorithmBLAKE3} {
+		b.Run(string(algo), func(b *testing.B) {
+			opts := options.Default()
+			opts.HashAlgorithm = algo
+			s := New(opts)
+
+			b.SetBytes(benchmarkFileSize)
+			for b.Loop() {
+				if _, err := s.Serialize(dir); err != nil {
+					b.Fatalf("Serialize failed: %v", err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkMmap compares hashing a large file through a memory map with
+// streaming it.
+func BenchmarkMmap(b *testing.B) {
+	dir := largeFileFixture(b, benchmarkFileSize)
+
+	for _, useMmap := range []bool{false, true} {
+		b.Run(fmt.Sprintf("mmap=%t", useMmap), func(b *testing.B) {
+			opts := options.Default()
+			opts.UseMmap = useMmap
+			s := New(opts)
+
+			b.SetBytes(benchmarkFileSize)
+			for b.Loop() {
+				if _, err := s.Serialize(dir); err != nil {
+					b.Fatalf("Serialize failed: %v", err)
+				}
+			}
+		})
+	}
+}
+
+// largeTreeFixture creates a model directory of dirs directories holding
+// files small files each.
+func largeTreeFixture(b *testing.B, dirs, files int) string {
+	b.Helper()
+
+	root := b.TempDir()
+	for d := range dirs {
+		dir := filepath.Join(root, fmt.Sprintf("dir%03d", d))
+		if err := os.Mkdir(dir, 0755); err != nil {
+			b.Fatalf("Failed to create fixture dir: %v", err)
+		}
+		for f := range files {
+			name := filepath.Join(dir, fmt.Sprintf("file%04d.bin", f))
+			if err := os.WriteFile(name, []byte(name), 0644); err != nil {
+				b.Fatalf("Failed to create fixture file: %v", err)
+			}
+		}
+	}
+	return root
+}
+
+func BenchmarkSerializeTree(b *testing.B) {
+	dir := largeTreeFixture(b, 100, 200)
+	s := New(options.Default())
+
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := s.Serialize(dir); err != nil {
+			b.Fatalf("Serialize failed: %v", err)
+		}
+	}
+}
+
+// skewedTreeFixture creates a model directory of small files of 1 MiB
+// and, found last by the walk, a large file of large bytes.
+func skewedTreeFixture(b *testing.B, small int, large int64) string {
+	b.Helper()
+
+	root := b.TempDir()
+	data := make([]byte, 1<<20)
+	for i := range small {
+		if err := os.WriteFile(filepath.Join(root, fmt.Sprintf("shard%04d.bin", i)), data, 0644); err != nil {
+			b.Fatalf("Failed to create fixture file: %v", err)
+		}
+	}
+	f, err := os.Create(filepath.Join(root, "zz-model.safetensors"))
+	if err != nil {
+		b.Fatalf("Failed to create fixture: %v", err)
+	}
+	if err := f.Truncate(large); err != nil {
+		b.Fatalf("Failed to size fixture: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		b.Fatalf("Failed to close fixture: %v", err)
+	}
+	return root
+}
+
+// BenchmarkLargestFirst compares hashing a skewed tree in walk order,
+// the large file being hashed alone once the small ones are done, with
+// hashing it first, while the other workers go through the small files.
+func BenchmarkLargestFirst(b *testing.B) {
+	const small, large = 256, 256 << 20
+	dir := skewedTreeFixture(b, small, large)
+
+	for _, largestFirst := range []bool{false, true} {
+		b.Run(fmt.Sprintf("largestFirst=%t", largestFirst), func(b *testing.B) {
+			opts := options.Default()
+			opts.Concurrency = 4
+			opts.LargestFirst = largestFirst
+			s := New(opts)
+
+			b.SetBytes(small<<20 + large)
+			for b.Loop() {
+				if _, err := s.Serialize(dir); err != nil {
+					b.Fatalf("Serialize failed: %v", err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkWalkTree measures the walk of a large tree, without hashing.
+// The bare filepath.Walk calls Lstat on every entry, filepath.WalkDir types
+// them from their directory listing as the walk does; the difference
+// between both is the cost of the syscalls saved.
+func BenchmarkWalkTree(b *testing.B) {
+	dir := largeTreeFixture(b, 100, 200)
+	s := New(options.Default())
+	rules, err := s.newIgnoreRules(dir)
+	if err != nil {
+		b.Fatalf("Failed to build ignore rules: %v", err)
+	}
+
+	b.Run("filepath.Walk", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			if err := filepath.Walk(dir, func(string, os.FileInfo, error) error { return nil }); err != nil {
+				b.Fatalf("Walk failed: %v", err)
+			}
+		}
+	})
+	b.Run("filepath.WalkDir", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			if err := filepath.WalkDir(dir, func(string, fs.DirEntry, error) error { return nil }); err != nil {
+				b.Fatalf("WalkDir failed: %v", err)
+			}
+		}
+	})
+	b.Run("walkDir", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			if err := s.walkDir(context.Background(), dir, dir, dir, "", rules, nil, func(string) error { return nil }); err != nil {
+				b.Fatalf("walkDir failed: %v", err)
+			}
+		}
+	})
+}
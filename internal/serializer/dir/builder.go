@@ -0,0 +1,67 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package dir
+
+import (
+	"sort"
+	"sync"
+
+	intoto "github.com/in-toto/attestation/go/v1"
+)
+
+// RootDigestBuilder accumulates file descriptors, in any order, to compute
+// the root digest of a model without holding a manifest. Descriptors are
+// buffered and sorted by name when summing, so the result matches
+// ComputeRootDigest on the manifest of the same files in the default
+// RootDigestConcat mode. It is safe for concurrent use.
+type RootDigestBuilder struct {
+	algorithm intoto.HashAlgorithm
+
+	mu    sync.Mutex
+	files []*intoto.ResourceDescriptor
+}
+
+// NewRootDigestBuilder returns a builder computing the root digest with
+// algo, defaulting to SHA256 when empty.
+func NewRootDigestBuilder(algo intoto.HashAlgorithm) *RootDigestBuilder {
+	if algo == "" {
+		algo = intoto.AlgorithmSHA256
+	}
+	return &RootDigestBuilder{algorithm: algo}
+}
+
+// Add records the descriptor of a file. Its digest for the builder
+// algorithm is only checked by Sum.
+func (b *RootDigestBuilder) Add(descriptor *intoto.ResourceDescriptor) {
+	d := &intoto.ResourceDescriptor{
+		Name:   descriptor.GetName(),
+		Digest: map[string]string{},
+	}
+	if digest, ok := descriptor.GetDigest()[string(b.algorithm)]; ok {
+		d.Digest[string(b.algorithm)] = digest
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.files = append(b.files, d)
+}
+
+// Sum returns the hex-encoded root digest of the files added so far. It
+// fails if any of them lacks a digest for the builder algorithm. More
+// files can be added after calling it.
+func (b *RootDigestBuilder) Sum() (string, error) {
+	b.mu.Lock()
+	files := make([]*intoto.ResourceDescriptor, len(b.files))
+	copy(files, b.files)
+	b.mu.Unlock()
+
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].Name < files[j].Name
+	})
+
+	return ComputeRootDigestWithAlgorithm(&Manifest{
+		Files:         files,
+		HashAlgorithm: b.algorithm,
+	}, b.algorithm)
+}
@@ -0,0 +1,53 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package dir
+
+import (
+	"sync"
+	"testing"
+
+	intoto "github.com/in-toto/attestation/go/v1"
+)
+
+func TestRootDigestBuilder(t *testing.T) {
+	_, manifest := newTestManifest(t)
+
+	expected, err := ComputeRootDigest(manifest)
+	if err != nil {
+		t.Fatalf("ComputeRootDigest failed: %v", err)
+	}
+
+	b := NewRootDigestBuilder("")
+	var wg sync.WaitGroup
+	for i := len(manifest.Files) - 1; i >= 0; i-- {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b.Add(manifest.Files[i])
+		}()
+	}
+	wg.Wait()
+
+	digest, err := b.Sum()
+	if err != nil {
+		t.Fatalf("Sum failed: %v", err)
+	}
+	if digest != expected {
+		t.Errorf("Builder digest %s does not match %s", digest, expected)
+	}
+
+	empty, err := NewRootDigestBuilder(intoto.AlgorithmSHA256).Sum()
+	if err != nil {
+		t.Fatalf("Sum failed: %v", err)
+	}
+	if empty != "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855" {
+		t.Errorf("Unexpected digest of no files: %s", empty)
+	}
+
+	b = NewRootDigestBuilder(intoto.AlgorithmSHA512)
+	b.Add(manifest.Files[0])
+	if _, err := b.Sum(); err == nil {
+		t.Error("Expected error for a descriptor without the builder digest")
+	}
+}
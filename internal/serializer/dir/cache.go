@@ -0,0 +1,236 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package dir
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	intoto "github.com/in-toto/attestation/go/v1"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// cacheVersion is the version of the digest cache file format. Cache
+// files of other versions are discarded.
+const cacheVersion = 1
+
+// digestCache is the on-disk cache of file digests of the CachePath
+// option. Entries are keyed by the absolute path of a file and reused
+// while its size, modification time and mode are unchanged and it is
+// hashed with the same settings. It is safe for concurrent use.
+type digestCache struct {
+	path string
+
+	once    sync.Once
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+	dirty   bool
+}
+
+// cacheFile is the JSON schema of the cache file.
+type cacheFile struct {
+	Version int                   `json:"version"`
+	Entries map[string]cacheEntry `json:"entries"`
+}
+
+// cacheEntry is the cached hash of a file.
+type cacheEntry struct {
+	Size     int64      `json:"size"`
+	ModTime  int64      `json:"modTime"`
+	Mode     uint32     `json:"mode"`
+	Settings string     `json:"settings"`
+	Name     string     `json:"name"`
+	Files    []jsonFile `json:"files"`
+}
+
+// newDigestCache returns the cache stored at path, or nil when path is
+// empty and caching is disabled.
+func newDigestCache(path string) *digestCache {
+	if path == "" {
+		return nil
+	}
+	return &digestCache{path: path}
+}
+
+// load reads the cache file the first time it is called. A missing,
+// unreadable or outdated cache file starts an empty cache, as entries
+// can always be computed again.
+func (c *digestCache) load() {
+	c.once.Do(func() {
+		c.entries = map[string]cacheEntry{}
+		data, err := os.ReadFile(c.path)
+		if err != nil {
+			return
+		}
+		var file cacheFile
+		if err := json.Unmarshal(data, &file); err != nil || file.Version != cacheVersion {
+			return
+		}
+		for key, entry := range file.Entries {
+			c.entries[key] = entry
+		}
+	})
+}
+
+// cacheKey returns the cache key of the file f was opened from and its
+// file info. It returns false for anything but regular files on disk.
+func cacheKey(f io.Reader) (string, fs.FileInfo, bool) {
+	file, ok := f.(*os.File)
+	if !ok {
+		return "", nil, false
+	}
+	info, err := file.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		return "", nil, false
+	}
+	key, err := filepath.Abs(file.Name())
+	if err != nil {
+		return "", nil, false
+	}
+	return key, info, true
+}
+
+// lookup returns the cached descriptors of the file f, opened as the
+// model file name and hashed with settings, renamed after name. It
+// returns nil descriptors when the cache has no valid entry for the file,
+// along with the file info to store its hash under, nil if it cannot be
+// cached.
+func (c *digestCache) lookup(f io.Reader, name, settings string) ([]*intoto.ResourceDescriptor, fs.FileInfo) {
+	if c == nil {
+		return nil, nil
+	}
+	key, info, ok := cacheKey(f)
+	if !ok {
+		return nil, nil
+	}
+
+	c.load()
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+	if !ok || entry.Size != info.Size() || entry.ModTime != info.ModTime().UnixNano() ||
+		entry.Mode != uint32(info.Mode()) || entry.Settings != settings {
+		return nil, info
+	}
+
+	descriptors := make([]*intoto.ResourceDescriptor, 0, len(entry.Files))
+	for _, file := range entry.Files {
+		descriptor := &intoto.ResourceDescriptor{
+			Name:   name + strings.TrimPrefix(file.Name, entry.Name),
+			Digest: file.Digest,
+		}
+		if len(file.Annotations) > 0 {
+			annotations, err := structpb.NewStruct(file.Annotations)
+			if err != nil {
+				return nil, info
+			}
+			descriptor.Annotations = annotations
+		}
+		descriptors = append(descriptors, descriptor)
+	}
+	return descriptors, info
+}
+
+// store records the descriptors of the file f, opened as the model file
+// name and hashed with settings. info is the file info of the file
+// before it was hashed, so a file changing while it is read is not
+// cached under its new modification time.
+func (c *digestCache) store(f io.Reader, info fs.FileInfo, name, settings string, descriptors []*intoto.ResourceDescriptor) {
+	if c == nil || info == nil {
+		return
+	}
+	key, _, ok := cacheKey(f)
+	if !ok {
+		return
+	}
+
+	entry := cacheEntry{
+		Size:     info.Size(),
+		ModTime:  info.ModTime().UnixNano(),
+		Mode:     uint32(info.Mode()),
+		Settings: settings,
+		Name:     name,
+		Files:    make([]jsonFile, 0, len(descriptors)),
+	}
+	for _, descriptor := range descriptors {
+		entry.Files = append(entry.Files, jsonFile{
+			Name:        descriptor.GetName(),
+			Digest:      descriptor.GetDigest(),
+			Annotations: descriptor.GetAnnotations().AsMap(),
+		})
+	}
+
+	c.load()
+	c.mu.Lock()
+	c.entries[key] = entry
+	c.dirty = true
+	c.mu.Unlock()
+}
+
+// save writes the cache file if entries were added since it was loaded.
+// The file is replaced atomically so concurrent readers never see a
+// partial cache.
+func (c *digestCache) save() error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.dirty {
+		return nil
+	}
+
+	data, err := json.Marshal(cacheFile{Version: cacheVersion, Entries: c.entries})
+	if err != nil {
+		return fmt.Errorf("encoding digest cache: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".*")
+	if err != nil {
+		return fmt.Errorf("writing digest cache: %w", err)
+	}
+	_, err = tmp.Write(data)
+	err = errors.Join(err, tmp.Close())
+	if err == nil {
+		err = os.Rename(tmp.Name(), c.path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name()) //nolint:errcheck
+		return fmt.Errorf("writing digest cache: %w", err)
+	}
+
+	c.dirty = false
+	return nil
+}
+
+// fileCache returns the digest cache to look files up in, nil when
+// CachePath is not set or a custom Hasher is, as the digests of those
+// cannot be told apart from the built-in ones. So it is with a
+// ResourceAnnotator, whose annotations may change between runs.
+func (s *Serializer) fileCache() *digestCache {
+	if s.opts.Hasher != nil || s.opts.ResourceAnnotator != nil {
+		return nil
+	}
+	return s.cache
+}
+
+// cacheSettings returns the settings the digests of the model file name
+// depend on. Cached entries are only reused when they were computed with
+// the same ones.
+func (s *Serializer) cacheSettings(name string) string {
+	algos := make([]string, 0, len(s.algorithms()))
+	for _, algo := range s.algorithms() {
+		algos = append(algos, string(algo))
+	}
+	return fmt.Sprintf("algorithms=%s method=%s shard=%d compression=%s sizes=%t mode=%t",
+		strings.Join(algos, ","), s.opts.Method, s.shardSize(), s.compressionFor(name),
+		s.opts.RecordSizes, s.opts.RecordPermissions)
+}
@@ -0,0 +1,147 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package dir
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/carabiner-dev/model-signing/internal/serializer/options"
+)
+
+func TestCachePath(t *testing.T) {
+	tempDir, expected := newTestManifest(t)
+	cacheDir, err := os.MkdirTemp("", "modeldigest-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(cacheDir)
+	cachePath := filepath.Join(cacheDir, "digests.json")
+
+	opts := options.Default()
+	opts.CachePath = cachePath
+
+	manifest, err := New(opts).Serialize(tempDir)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	if diff := Compare(expected, manifest); !diff.Empty() {
+		t.Fatalf("Cached serialization differs: %v", diff)
+	}
+
+	data, err := os.ReadFile(cachePath)
+	if err != nil {
+		t.Fatalf("Failed to read cache: %v", err)
+	}
+	var cache cacheFile
+	if err := json.Unmarshal(data, &cache); err != nil {
+		t.Fatalf("Failed to decode cache: %v", err)
+	}
+	if len(cache.Entries) != 3 {
+		t.Fatalf("Expected 3 cache entries, got %d", len(cache.Entries))
+	}
+
+	// Poison the cached digest of model.bin: it is used as long as the
+	// file is unchanged, which shows it is not hashed again
+	modelPath := filepath.Join(tempDir, "model.bin")
+	key, err := filepath.Abs(modelPath)
+	if err != nil {
+		t.Fatalf("Failed to resolve path: %v", err)
+	}
+	entry := cache.Entries[key]
+	entry.Files[0].Digest = map[string]string{"sha256": "cached"}
+	cache.Entries[key] = entry
+	data, err = json.Marshal(cache)
+	if err != nil {
+		t.Fatalf("Failed to encode cache: %v", err)
+	}
+	if err := os.WriteFile(cachePath, data, 0644); err != nil {
+		t.Fatalf("Failed to write cache: %v", err)
+	}
+
+	digestOf := func(t *testing.T, opts *options.Options) string {
+		t.Helper()
+		manifest, err := New(opts).Serialize(tempDir)
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+		for _, file := range manifest.Files {
+			if file.Name == "model.bin" {
+				return file.Digest["sha256"]
+			}
+		}
+		t.Fatal("model.bin not in manifest")
+		return ""
+	}
+
+	if got := digestOf(t, opts); got != "cached" {
+		t.Errorf("Expected the cached digest, got %s", got)
+	}
+
+	// Without a cache path the digest is computed
+	if got := digestOf(t, options.Default()); got == "cached" {
+		t.Error("Expected the cache to be disabled")
+	}
+
+	// Other settings do not use the entry
+	sized := *opts
+	sized.RecordSizes = true
+	if got := digestOf(t, &sized); got == "cached" {
+		t.Error("Expected the entry of other settings not to be used")
+	}
+
+	// Changing the modification time invalidates the entry
+	mtime := time.Now().Add(time.Hour)
+	if err := os.Chtimes(modelPath, mtime, mtime); err != nil {
+		t.Fatalf("Failed to change modification time: %v", err)
+	}
+	if got := digestOf(t, opts); got == "cached" {
+		t.Error("Expected a changed modification time to invalidate the entry")
+	}
+
+	// As does changing the size, with the modification time restored
+	if err := os.WriteFile(modelPath, []byte("new weights"), 0644); err != nil {
+		t.Fatalf("Failed to update file: %v", err)
+	}
+	if err := os.Chtimes(modelPath, mtime, mtime); err != nil {
+		t.Fatalf("Failed to change modification time: %v", err)
+	}
+	manifest, err = New(opts).Serialize(tempDir)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	fresh, err := New(options.Default()).Serialize(tempDir)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	if diff := Compare(fresh, manifest); !diff.Empty() {
+		t.Errorf("Expected a changed size to invalidate the entry: %v", diff)
+	}
+}
+
+func TestCachePathCorrupt(t *testing.T) {
+	tempDir, expected := newTestManifest(t)
+	cacheDir, err := os.MkdirTemp("", "modeldigest-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(cacheDir)
+	cachePath := filepath.Join(cacheDir, "digests.json")
+	if err := os.WriteFile(cachePath, []byte("not json"), 0644); err != nil {
+		t.Fatalf("Failed to write cache: %v", err)
+	}
+
+	opts := options.Default()
+	opts.CachePath = cachePath
+	manifest, err := New(opts).Serialize(tempDir)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	if diff := Compare(expected, manifest); !diff.Empty() {
+		t.Errorf("Manifests differ: %v", diff)
+	}
+}
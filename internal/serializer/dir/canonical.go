@@ -0,0 +1,85 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package dir
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	intoto "github.com/in-toto/attestation/go/v1"
+)
+
+// Canonicalize returns the canonical encoding of the manifest, the exact
+// bytes to sign. Any implementation producing the same bytes from the
+// same manifest verifies the same signatures. The encoding is the JSON
+// document of MarshalJSON with:
+//
+//   - the files sorted by name, compared byte by byte;
+//   - the keys of every object (digests and annotations included) sorted
+//     by their UTF-8 bytes;
+//   - no whitespace between tokens and no trailing newline;
+//   - strings encoded as UTF-8, escaping only the quote, the backslash,
+//     the control characters (as \uXXXX, except \b \f \n \r \t) and
+//     U+2028 and U+2029 (as \u2028 and \u2029);
+//   - numbers formatted as ECMAScript's Number.prototype.toString does.
+//
+// The manifest itself is not modified.
+func (m *Manifest) Canonicalize() ([]byte, error) {
+	encoded, err := m.MarshalJSON()
+	if err != nil {
+		return nil, fmt.Errorf("encoding manifest: %w", err)
+	}
+
+	var doc map[string]any
+	if err := decodeJSON(encoded, &doc); err != nil {
+		return nil, fmt.Errorf("decoding manifest: %w", err)
+	}
+
+	files, _ := doc["files"].([]any)
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].(map[string]any)["name"].(string) < files[j].(map[string]any)["name"].(string)
+	})
+
+	canonical, err := encodeCanonical(doc)
+	if err != nil {
+		return nil, fmt.Errorf("encoding manifest: %w", err)
+	}
+	return canonical, nil
+}
+
+// canonicalAnnotations returns the annotations of the file in the
+// canonical JSON form of Canonicalize, "{}" when it has none.
+func canonicalAnnotations(file *intoto.ResourceDescriptor) ([]byte, error) {
+	encoded, err := json.Marshal(file.GetAnnotations().AsMap())
+	if err != nil {
+		return nil, err
+	}
+	var doc any
+	if err := decodeJSON(encoded, &doc); err != nil {
+		return nil, err
+	}
+	return encodeCanonical(doc)
+}
+
+// decodeJSON decodes data into v keeping the numbers as written.
+func decodeJSON(data []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+// encodeCanonical encodes a document decoded with decodeJSON in the
+// canonical form described in Canonicalize. Maps are encoded with their
+// keys sorted.
+func encodeCanonical(doc any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
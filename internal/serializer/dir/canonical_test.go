@@ -0,0 +1,62 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package dir
+
+import (
+	"testing"
+
+	intoto "github.com/in-toto/attestation/go/v1"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestCanonicalize(t *testing.T) {
+	annotated := func(fields map[string]any) *structpb.Struct {
+		s, err := structpb.NewStruct(fields)
+		if err != nil {
+			t.Fatalf("NewStruct failed: %v", err)
+		}
+		return s
+	}
+
+	a := &Manifest{
+		ModelName: "model<1>",
+		Files: []*intoto.ResourceDescriptor{
+			{Name: "b.bin", Digest: map[string]string{"sha256": "bb", "blake3": "b3"}},
+			{Name: "a.bin", Digest: map[string]string{"sha256": "aa"}, Annotations: annotated(map[string]any{"size": 1024, "mode": "0644"})},
+		},
+	}
+	b := &Manifest{
+		ModelName:     "model<1>",
+		HashAlgorithm: intoto.AlgorithmSHA256,
+		Files: []*intoto.ResourceDescriptor{
+			{Name: "a.bin", Digest: map[string]string{"sha256": "aa"}, Annotations: annotated(map[string]any{"mode": "0644", "size": 1024.0})},
+			{Name: "b.bin", Digest: map[string]string{"blake3": "b3", "sha256": "bb"}},
+		},
+	}
+
+	ca, err := a.Canonicalize()
+	if err != nil {
+		t.Fatalf("Canonicalize failed: %v", err)
+	}
+	cb, err := b.Canonicalize()
+	if err != nil {
+		t.Fatalf("Canonicalize failed: %v", err)
+	}
+
+	expected := `{"files":[` +
+		`{"annotations":{"mode":"0644","size":1024},"digest":{"sha256":"aa"},"name":"a.bin"},` +
+		`{"digest":{"blake3":"b3","sha256":"bb"},"name":"b.bin"}],` +
+		`"hashAlgorithm":"sha256","modelName":"model<1>"}`
+	if string(ca) != expected {
+		t.Errorf("Expected\n%s\ngot\n%s", expected, ca)
+	}
+	if string(cb) != string(ca) {
+		t.Errorf("Equivalent manifests encode differently:\n%s\n%s", ca, cb)
+	}
+
+	// The manifest keeps its order
+	if a.Files[0].Name != "b.bin" {
+		t.Error("Canonicalize modified the manifest")
+	}
+}
@@ -0,0 +1,77 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package dir
+
+// FileChange describes a file present in two manifests with different
+// digests, in algorithm:hash format.
+type FileChange struct {
+	Name string
+	Old  string
+	New  string
+}
+
+// ManifestDiff lists the differences between two manifests of a model.
+// All lists are sorted by file name.
+type ManifestDiff struct {
+	// Added are files of the new manifest missing from the old one.
+	Added []string
+
+	// Removed are files of the old manifest missing from the new one.
+	Removed []string
+
+	// Modified are files whose digest changed.
+	Modified []FileChange
+}
+
+// Empty returns true if the manifests list the same files and digests.
+func (d *ManifestDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Modified) == 0
+}
+
+// Compare returns the differences between the old manifest a and the new
+// manifest b. Digests are compared with the hash algorithm of a, files of
+// b lacking a digest for it are reported as modified. Digests of b are
+// converted to the DigestEncoding of a before being compared and
+// reported. Model names and
+// annotations are not compared.
+func Compare(a, b *Manifest) *ManifestDiff {
+	algo := string(a.algorithm())
+
+	old := make(map[string]string, len(a.Files))
+	for _, file := range a.Files {
+		old[file.Name] = file.Digest[algo]
+	}
+
+	diff := &ManifestDiff{}
+	seen := make(map[string]struct{}, len(b.Files))
+	for _, file := range b.Files {
+		seen[file.Name] = struct{}{}
+		want, ok := old[file.Name]
+		if !ok {
+			diff.Added = append(diff.Added, file.Name)
+			continue
+		}
+		got, ok := file.Digest[algo]
+		if ok && a.digestEncoding() != b.digestEncoding() {
+			// Digests that cannot be decoded are reported as they are
+			if converted, err := transcodeDigest(b.digestEncoding(), a.digestEncoding(), got); err == nil {
+				got = converted
+			}
+		}
+		if !ok || got != want {
+			change := FileChange{Name: file.Name, Old: algo + ":" + want}
+			if ok {
+				change.New = algo + ":" + got
+			}
+			diff.Modified = append(diff.Modified, change)
+		}
+	}
+	for _, file := range a.Files {
+		if _, ok := seen[file.Name]; !ok {
+			diff.Removed = append(diff.Removed, file.Name)
+		}
+	}
+
+	return diff
+}
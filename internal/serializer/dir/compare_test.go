@@ -0,0 +1,69 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package dir
+
+import (
+	"testing"
+
+	intoto "github.com/in-toto/attestation/go/v1"
+)
+
+func TestCompare(t *testing.T) {
+	descriptor := func(name, digest string) *intoto.ResourceDescriptor {
+		return &intoto.ResourceDescriptor{Name: name, Digest: map[string]string{"sha256": digest}}
+	}
+
+	a := &Manifest{
+		ModelName: "model",
+		Files: []*intoto.ResourceDescriptor{
+			descriptor("config.json", "aa"),
+			descriptor("model.bin", "bb"),
+			descriptor("old.bin", "cc"),
+		},
+		HashAlgorithm: intoto.AlgorithmSHA256,
+	}
+	b := &Manifest{
+		ModelName: "model-v2",
+		Files: []*intoto.ResourceDescriptor{
+			descriptor("config.json", "aa"),
+			descriptor("model.bin", "dd"),
+			descriptor("new.bin", "ee"),
+		},
+		HashAlgorithm: intoto.AlgorithmSHA256,
+	}
+
+	diff := Compare(a, b)
+	if diff.Empty() {
+		t.Fatal("Expected differences")
+	}
+	if len(diff.Added) != 1 || diff.Added[0] != "new.bin" {
+		t.Errorf("Expected new.bin added, got %v", diff.Added)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0] != "old.bin" {
+		t.Errorf("Expected old.bin removed, got %v", diff.Removed)
+	}
+	if len(diff.Modified) != 1 {
+		t.Fatalf("Expected 1 modified file, got %v", diff.Modified)
+	}
+	if m := diff.Modified[0]; m.Name != "model.bin" || m.Old != "sha256:bb" || m.New != "sha256:dd" {
+		t.Errorf("Unexpected change %+v", m)
+	}
+
+	if diff := Compare(a, a); !diff.Empty() {
+		t.Errorf("Expected no differences comparing a manifest with itself, got %+v", diff)
+	}
+
+	// Files lacking the algorithm of a are modified
+	c := &Manifest{
+		Files: []*intoto.ResourceDescriptor{
+			{Name: "config.json", Digest: map[string]string{"sha512": "aa"}},
+			descriptor("model.bin", "bb"),
+			descriptor("old.bin", "cc"),
+		},
+	}
+	diff = Compare(a, c)
+	if len(diff.Modified) != 1 || diff.Modified[0].Name != "config.json" || diff.Modified[0].New != "" {
+		t.Errorf("Expected config.json modified without a new digest, got %+v", diff.Modified)
+	}
+}
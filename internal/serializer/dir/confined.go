@@ -0,0 +1,36 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package dir
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// confinedOpener returns an openFunc reading files through root. Opening
+// a file whose path resolves outside of the model directory fails.
+func confinedOpener(root *os.Root) openFunc {
+	return func(name string) (io.ReadCloser, error) {
+		return root.Open(filepath.FromSlash(name))
+	}
+}
+
+// confinedReadLink returns the function reading the symlinks of the model
+// at absPath, opened as root. Links are checked with the root before their
+// target is read, so only links inside of it are read.
+func confinedReadLink(root *os.Root, absPath string) func(name string) (string, error) {
+	return func(name string) (string, error) {
+		info, err := root.Lstat(filepath.FromSlash(name))
+		if err != nil {
+			return "", err
+		}
+		if info.Mode()&fs.ModeSymlink == 0 {
+			return "", fmt.Errorf("%s is not a symlink", name)
+		}
+		return os.Readlink(filepath.Join(absPath, filepath.FromSlash(name)))
+	}
+}
@@ -0,0 +1,110 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package dir
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/carabiner-dev/model-signing/internal/serializer/options"
+)
+
+func TestConfineToRoot(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "modeldigest-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	testFiles := map[string]string{
+		"model.bin":          "weights",
+		"config.json":        "{}",
+		"subdir/layer.bin":   "layer",
+		".git/config":        "git config",
+		"subdir/nested/data": "nested",
+	}
+	for name, content := range testFiles {
+		path := filepath.Join(tempDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create dir for %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test file %s: %v", name, err)
+		}
+	}
+
+	t.Run("MatchesUnconfined", func(t *testing.T) {
+		expected, err := ComputeDigest(tempDir, options.Default())
+		if err != nil {
+			t.Fatalf("ComputeDigest failed: %v", err)
+		}
+
+		opts := options.Default()
+		opts.ConfineToRoot = true
+		digest, err := ComputeDigest(tempDir, opts)
+		if err != nil {
+			t.Fatalf("ComputeDigest (confined) failed: %v", err)
+		}
+
+		if digest != expected {
+			t.Errorf("Confined digest %s does not match %s", digest, expected)
+		}
+	})
+
+	t.Run("IgnorePaths", func(t *testing.T) {
+		opts := options.Default()
+		opts.ConfineToRoot = true
+		opts.IgnorePaths = []string{filepath.Join(tempDir, "subdir", "nested")}
+
+		manifest, err := New(opts).Serialize(tempDir)
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+
+		if len(manifest.Files) != 3 {
+			t.Errorf("Expected 3 files, got %d", len(manifest.Files))
+			for _, f := range manifest.Files {
+				t.Logf("  Found file: %s", f.Name)
+			}
+		}
+	})
+
+	t.Run("RejectsSymlinks", func(t *testing.T) {
+		outside, err := os.MkdirTemp("", "modeldigest-outside-*")
+		if err != nil {
+			t.Fatalf("Failed to create temp dir: %v", err)
+		}
+		defer os.RemoveAll(outside)
+
+		secret := filepath.Join(outside, "secret")
+		if err := os.WriteFile(secret, []byte("secret"), 0644); err != nil {
+			t.Fatalf("Failed to create secret: %v", err)
+		}
+
+		link := filepath.Join(tempDir, "escape")
+		if err := os.Symlink(secret, link); err != nil {
+			t.Skipf("Symlinks not supported: %v", err)
+		}
+		defer os.Remove(link)
+
+		opts := options.Default()
+		opts.ConfineToRoot = true
+		if _, err := New(opts).Serialize(tempDir); err == nil {
+			t.Error("Expected error for symlink without AllowSymlinks")
+		}
+
+		// Escaping links can never be read through the root
+		root, err := os.OpenRoot(tempDir)
+		if err != nil {
+			t.Fatalf("Failed to open root: %v", err)
+		}
+		defer root.Close()
+
+		if _, err := New(opts).hashFiles(context.Background(), []string{"escape"}, confinedOpener(root)); err == nil {
+			t.Error("Expected error hashing a symlink escaping the root")
+		}
+	})
+}
@@ -0,0 +1,113 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package dir
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	intoto "github.com/in-toto/attestation/go/v1"
+)
+
+// DryRun walks the model at modelPath applying the ignore rules of the
+// serializer, without hashing any file. It returns the names of the files
+// a manifest of the model would cover, ExternalFiles included, and the
+// paths the rules ignored. Ignored directories are listed with a trailing slash
+// and their contents are not. Both lists are sorted. PostHash is not
+// called, as it needs the digests.
+func (s *Serializer) DryRun(modelPath string) (included, ignored []string, err error) {
+	absPath, err := filepath.Abs(modelPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to resolve model path: %w", err)
+	}
+
+	if err := s.validateSymlinkPolicy(); err != nil {
+		return nil, nil, err
+	}
+	if s.opts.ConfineToRoot && s.opts.HuggingFaceSnapshot {
+		return nil, nil, errors.New("HuggingFaceSnapshot cannot be combined with ConfineToRoot, snapshot blobs are outside of the snapshot directory")
+	}
+	if s.opts.Strict && s.opts.HuggingFaceSnapshot {
+		return nil, nil, errors.New("HuggingFaceSnapshot cannot be combined with Strict, snapshot files are symlinks")
+	}
+
+	rules, err := s.newIgnoreRules(absPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	rules.onIgnore = func(name string, isDir bool) {
+		if isDir {
+			name += "/"
+		}
+		ignored = append(ignored, name)
+	}
+
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read model path: %w", err)
+	}
+
+	var names []string
+	yield := func(name string) error {
+		names = append(names, name)
+		return nil
+	}
+
+	ctx := context.Background()
+	switch {
+	case info.Mode().IsRegular():
+		names = []string{filepath.Base(absPath)}
+	case !info.IsDir():
+		return nil, nil, fmt.Errorf("model path %s is not a directory or a regular file", modelPath)
+	case s.opts.ConfineToRoot:
+		root, err := os.OpenRoot(absPath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open model root: %w", err)
+		}
+		defer root.Close() //nolint:errcheck
+		links := s.recordedLinks(confinedReadLink(root, absPath))
+		err = s.walkFS(ctx, root.FS(), absPath, ".", rules, links, rules.yieldIncluded(yield))
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %w", ErrWalkFailed, err)
+		}
+	default:
+		realRoot, err := filepath.EvalSymlinks(absPath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to resolve model path: %w", err)
+		}
+		links := s.recordedLinks(func(name string) (string, error) {
+			return os.Readlink(filepath.Join(absPath, filepath.FromSlash(name)))
+		})
+		err = s.walkDir(ctx, absPath, realRoot, realRoot, "", rules, links, rules.yieldIncluded(yield))
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %w", ErrWalkFailed, err)
+		}
+	}
+
+	for name := range s.opts.ExternalFiles {
+		names = append(names, name)
+	}
+
+	// Record the names as a manifest would: normalized, sorted and with
+	// the markers of non-empty directories dropped
+	descriptors := make([]*intoto.ResourceDescriptor, 0, len(names))
+	for _, name := range names {
+		descriptors = append(descriptors, &intoto.ResourceDescriptor{Name: name})
+	}
+	manifest, err := s.newManifest("", descriptors)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	included = make([]string, 0, len(manifest.Files))
+	for _, file := range manifest.Files {
+		included = append(included, file.Name)
+	}
+	sort.Strings(ignored)
+	return included, ignored, nil
+}
@@ -0,0 +1,71 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package dir
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/carabiner-dev/model-signing/internal/serializer/options"
+)
+
+func TestDryRun(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "modeldigest-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	for _, name := range []string{
+		"model.bin", "config.json", "train.log", ".gitignore",
+		".git/config", "logs/run.txt", "subdir/layer.bin",
+	} {
+		path := filepath.Join(tempDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create dir for %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatalf("Failed to create test file %s: %v", name, err)
+		}
+	}
+
+	opts := options.Default()
+	opts.IgnorePaths = []string{"*.log", "logs/"}
+
+	for _, confine := range []bool{false, true} {
+		opts.ConfineToRoot = confine
+		s := New(opts)
+
+		included, ignored, err := s.DryRun(tempDir)
+		if err != nil {
+			t.Fatalf("DryRun failed: %v", err)
+		}
+		if got := strings.Join(included, ","); got != "config.json,model.bin,subdir/layer.bin" {
+			t.Errorf("ConfineToRoot %v: unexpected included files %s", confine, got)
+		}
+		if got := strings.Join(ignored, ","); got != ".git/,.gitignore,logs/,train.log" {
+			t.Errorf("ConfineToRoot %v: unexpected ignored paths %s", confine, got)
+		}
+
+		// The included files are the ones Serialize records
+		manifest, err := s.Serialize(tempDir)
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+		if len(manifest.Files) != len(included) {
+			t.Fatalf("Expected %d files, got %d", len(included), len(manifest.Files))
+		}
+		for i, file := range manifest.Files {
+			if file.Name != included[i] {
+				t.Errorf("Expected %s, got %s", included[i], file.Name)
+			}
+		}
+	}
+
+	if _, _, err := New(nil).DryRun(filepath.Join(tempDir, "missing")); err == nil {
+		t.Error("Expected error for a missing model path")
+	}
+}
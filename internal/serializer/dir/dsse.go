@@ -0,0 +1,118 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package dir
+
+import (
+	"fmt"
+	"strconv"
+
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// PredicateType is the in-toto predicate type of model signatures, as
+// used by the model signing library.
+const PredicateType = "https://model_signing/signature/v1.0"
+
+// PayloadTypeInToto is the DSSE payload type of in-toto statements.
+const PayloadTypeInToto = "application/vnd.in-toto+json"
+
+// StatementPayload returns the manifest as an in-toto statement of
+// PredicateType (see ToStatement), encoded as JSON in the canonical form
+// of Canonicalize, so the payload is the same on every run.
+func (m *Manifest) StatementPayload() ([]byte, error) {
+	statement, err := m.ToStatement(PredicateType)
+	if err != nil {
+		return nil, err
+	}
+
+	// protojson randomizes its whitespace, canonicalize it away
+	encoded, err := protojson.Marshal(statement)
+	if err != nil {
+		return nil, fmt.Errorf("encoding statement: %w", err)
+	}
+	var doc any
+	if err := decodeJSON(encoded, &doc); err != nil {
+		return nil, fmt.Errorf("decoding statement: %w", err)
+	}
+	payload, err := encodeCanonical(doc)
+	if err != nil {
+		return nil, fmt.Errorf("encoding statement: %w", err)
+	}
+	return payload, nil
+}
+
+// DSSEPreimage returns the bytes a DSSE signer signs for the manifest: the
+// pre-authentication encoding (PAE) of its StatementPayload with the given
+// payload type, PayloadTypeInToto when empty. Verifiers rebuild the same
+// bytes from the envelope payload and type, so any signer (a KMS, a
+// hardware key, sigstore) can sign them.
+func (m *Manifest) DSSEPreimage(payloadType string) ([]byte, error) {
+	if payloadType == "" {
+		payloadType = PayloadTypeInToto
+	}
+	payload, err := m.StatementPayload()
+	if err != nil {
+		return nil, err
+	}
+	return dssePAE(payloadType, payload), nil
+}
+
+// Signer signs the DSSE pre-authentication encoding of a payload, for
+// example with a KMS or hardware key. It returns the raw signature.
+type Signer interface {
+	Sign(data []byte) ([]byte, error)
+}
+
+// DSSEEnvelope is a DSSE envelope. It encodes to the JSON of the DSSE
+// specification, the payload and signatures in base64.
+type DSSEEnvelope struct {
+	PayloadType string          `json:"payloadType"`
+	Payload     []byte          `json:"payload"`
+	Signatures  []DSSESignature `json:"signatures"`
+}
+
+// DSSESignature is a signature of a DSSEEnvelope.
+type DSSESignature struct {
+	KeyID string `json:"keyid,omitempty"`
+	Sig   []byte `json:"sig"`
+}
+
+// SignManifestWith signs the StatementPayload of the manifest with signer
+// and returns it in a DSSE envelope of the given payload type,
+// PayloadTypeInToto when empty. The signer gets the DSSEPreimage of the
+// manifest.
+func SignManifestWith(manifest *Manifest, signer Signer, payloadType string) (*DSSEEnvelope, error) {
+	if payloadType == "" {
+		payloadType = PayloadTypeInToto
+	}
+	payload, err := manifest.StatementPayload()
+	if err != nil {
+		return nil, err
+	}
+	sig, err := signer.Sign(dssePAE(payloadType, payload))
+	if err != nil {
+		return nil, fmt.Errorf("signing statement: %w", err)
+	}
+	return &DSSEEnvelope{
+		PayloadType: payloadType,
+		Payload:     payload,
+		Signatures:  []DSSESignature{{Sig: sig}},
+	}, nil
+}
+
+// dssePAE returns the DSSE v1 pre-authentication encoding of the payload:
+// "DSSEv1 <len(type)> <type> <len(payload)> <payload>", lengths in ASCII
+// decimal.
+func dssePAE(payloadType string, payload []byte) []byte {
+	pae := make([]byte, 0, len(payloadType)+len(payload)+32)
+	pae = append(pae, "DSSEv1 "...)
+	pae = strconv.AppendInt(pae, int64(len(payloadType)), 10)
+	pae = append(pae, ' ')
+	pae = append(pae, payloadType...)
+	pae = append(pae, ' ')
+	pae = strconv.AppendInt(pae, int64(len(payload)), 10)
+	pae = append(pae, ' ')
+	pae = append(pae, payload...)
+	return pae
+}
@@ -0,0 +1,141 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package dir
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestDSSEPreimage(t *testing.T) {
+	_, manifest := newTestManifest(t)
+
+	payload, err := manifest.StatementPayload()
+	if err != nil {
+		t.Fatalf("StatementPayload failed: %v", err)
+	}
+
+	// The payload is stable and holds the statement
+	for range 10 {
+		again, err := manifest.StatementPayload()
+		if err != nil {
+			t.Fatalf("StatementPayload failed: %v", err)
+		}
+		if !bytes.Equal(again, payload) {
+			t.Fatalf("Unstable payload:\n%s\n%s", payload, again)
+		}
+	}
+	var statement struct {
+		Type          string `json:"_type"`
+		PredicateType string `json:"predicateType"`
+		Subject       []any  `json:"subject"`
+	}
+	if err := json.Unmarshal(payload, &statement); err != nil {
+		t.Fatalf("Failed to decode payload: %v", err)
+	}
+	if statement.PredicateType != PredicateType || len(statement.Subject) != len(manifest.Files)+1 {
+		t.Errorf("Unexpected statement %+v", statement)
+	}
+
+	preimage, err := manifest.DSSEPreimage("")
+	if err != nil {
+		t.Fatalf("DSSEPreimage failed: %v", err)
+	}
+	expected := fmt.Sprintf("DSSEv1 %d %s %d %s", len(PayloadTypeInToto), PayloadTypeInToto, len(payload), payload)
+	if string(preimage) != expected {
+		t.Errorf("Expected %q, got %q", expected, preimage)
+	}
+
+	custom, err := manifest.DSSEPreimage("application/json")
+	if err != nil {
+		t.Fatalf("DSSEPreimage failed: %v", err)
+	}
+	if !bytes.HasPrefix(custom, []byte("DSSEv1 16 application/json ")) {
+		t.Errorf("Unexpected preimage prefix %q", custom[:32])
+	}
+}
+
+func TestDSSEPAE(t *testing.T) {
+	// Test vector from the DSSE specification
+	got := dssePAE("http://example.com/HelloWorld", []byte("hello world"))
+	if string(got) != "DSSEv1 29 http://example.com/HelloWorld 11 hello world" {
+		t.Errorf("Unexpected PAE %q", got)
+	}
+}
+
+// signerFunc adapts a function to the Signer interface.
+type signerFunc func([]byte) ([]byte, error)
+
+func (f signerFunc) Sign(data []byte) ([]byte, error) { return f(data) }
+
+func TestSignManifestWith(t *testing.T) {
+	_, manifest := newTestManifest(t)
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	signer := signerFunc(func(data []byte) ([]byte, error) {
+		return ed25519.Sign(priv, data), nil
+	})
+
+	envelope, err := SignManifestWith(manifest, signer, "")
+	if err != nil {
+		t.Fatalf("SignManifestWith failed: %v", err)
+	}
+	payload, err := manifest.StatementPayload()
+	if err != nil {
+		t.Fatalf("StatementPayload failed: %v", err)
+	}
+	if envelope.PayloadType != PayloadTypeInToto || !bytes.Equal(envelope.Payload, payload) || len(envelope.Signatures) != 1 {
+		t.Fatalf("Unexpected envelope %+v", envelope)
+	}
+	preimage, err := manifest.DSSEPreimage("")
+	if err != nil {
+		t.Fatalf("DSSEPreimage failed: %v", err)
+	}
+	if !ed25519.Verify(pub, preimage, envelope.Signatures[0].Sig) {
+		t.Error("Signature does not verify over the preimage")
+	}
+
+	// The JSON follows the DSSE specification
+	encoded, err := json.Marshal(envelope)
+	if err != nil {
+		t.Fatalf("Failed to encode envelope: %v", err)
+	}
+	var doc struct {
+		PayloadType string `json:"payloadType"`
+		Payload     string `json:"payload"`
+		Signatures  []struct {
+			Sig string `json:"sig"`
+		} `json:"signatures"`
+	}
+	if err := json.Unmarshal(encoded, &doc); err != nil {
+		t.Fatalf("Failed to decode envelope: %v", err)
+	}
+	if doc.PayloadType != PayloadTypeInToto || doc.Payload != base64.StdEncoding.EncodeToString(payload) ||
+		len(doc.Signatures) != 1 || doc.Signatures[0].Sig != base64.StdEncoding.EncodeToString(envelope.Signatures[0].Sig) {
+		t.Errorf("Unexpected envelope JSON %s", encoded)
+	}
+
+	// Custom payload types are signed as given
+	custom, err := SignManifestWith(manifest, signer, "application/json")
+	if err != nil {
+		t.Fatalf("SignManifestWith failed: %v", err)
+	}
+	if custom.PayloadType != "application/json" || !ed25519.Verify(pub, dssePAE("application/json", payload), custom.Signatures[0].Sig) {
+		t.Errorf("Unexpected envelope %+v", custom)
+	}
+
+	// Signer errors are returned
+	failure := errors.New("kms unavailable")
+	if _, err := SignManifestWith(manifest, signerFunc(func([]byte) ([]byte, error) { return nil, failure }), ""); !errors.Is(err, failure) {
+		t.Errorf("Expected the signer error, got %v", err)
+	}
+}
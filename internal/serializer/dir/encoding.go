@@ -0,0 +1,114 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package dir
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"maps"
+
+	"github.com/carabiner-dev/model-signing/internal/serializer/options"
+)
+
+// encodeDigest returns the raw digest in the text encoding enc.
+func encodeDigest(enc options.DigestEncoding, raw []byte) (string, error) {
+	switch enc {
+	case "", options.DigestEncodingHex:
+		return hex.EncodeToString(raw), nil
+	case options.DigestEncodingBase64:
+		return base64.StdEncoding.EncodeToString(raw), nil
+	case options.DigestEncodingBase64URL:
+		return base64.RawURLEncoding.EncodeToString(raw), nil
+	default:
+		return "", fmt.Errorf("unsupported digest encoding %q", enc)
+	}
+}
+
+// decodeDigest returns the raw bytes of a digest in the text encoding enc.
+func decodeDigest(enc options.DigestEncoding, digest string) ([]byte, error) {
+	switch enc {
+	case "", options.DigestEncodingHex:
+		return hex.DecodeString(digest)
+	case options.DigestEncodingBase64:
+		return base64.StdEncoding.DecodeString(digest)
+	case options.DigestEncodingBase64URL:
+		return base64.RawURLEncoding.DecodeString(digest)
+	default:
+		return nil, fmt.Errorf("unsupported digest encoding %q", enc)
+	}
+}
+
+// transcodeDigest converts a digest from the encoding from to the
+// encoding to.
+func transcodeDigest(from, to options.DigestEncoding, digest string) (string, error) {
+	if from == to {
+		return digest, nil
+	}
+	raw, err := decodeDigest(from, digest)
+	if err != nil {
+		return "", err
+	}
+	return encodeDigest(to, raw)
+}
+
+// digestEncoding returns the digest encoding of the manifest, defaulting
+// to hex.
+func (m *Manifest) digestEncoding() options.DigestEncoding {
+	if m.DigestEncoding == "" {
+		return options.DigestEncodingHex
+	}
+	return m.DigestEncoding
+}
+
+// digestEncoding returns the configured digest encoding, defaulting to
+// hex.
+func (s *Serializer) digestEncoding() options.DigestEncoding {
+	if s.opts.DigestEncoding == "" {
+		return options.DigestEncodingHex
+	}
+	return s.opts.DigestEncoding
+}
+
+// validateDigestEncoding checks the configured digest encoding is known.
+func (s *Serializer) validateDigestEncoding() error {
+	_, err := encodeDigest(s.digestEncoding(), nil)
+	return err
+}
+
+// transcodeDigests returns a copy of the digests converted from the
+// encoding from to the encoding to.
+func transcodeDigests(from, to options.DigestEncoding, digests map[string]string) (map[string]string, error) {
+	if from == to {
+		return maps.Clone(digests), nil
+	}
+	out := make(map[string]string, len(digests))
+	for algo, digest := range digests {
+		encoded, err := transcodeDigest(from, to, digest)
+		if err != nil {
+			return nil, fmt.Errorf("decoding %s digest: %w", algo, err)
+		}
+		out[algo] = encoded
+	}
+	return out, nil
+}
+
+// encodeDigests converts the hex digests of the freshly hashed manifest
+// files to the configured encoding and records it in the manifest. The
+// digest maps are replaced, not modified, as the digest cache may hold
+// them.
+func (s *Serializer) encodeDigests(manifest *Manifest) error {
+	enc := s.digestEncoding()
+	if enc != options.DigestEncodingHex {
+		for _, file := range manifest.Files {
+			digests, err := transcodeDigests(options.DigestEncodingHex, enc, file.Digest)
+			if err != nil {
+				return fmt.Errorf("encoding digests of %s: %w", file.Name, err)
+			}
+			file.Digest = digests
+		}
+	}
+	manifest.DigestEncoding = s.opts.DigestEncoding
+	return nil
+}
@@ -0,0 +1,100 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package dir
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+var (
+	// ErrSymlinkNotAllowed is returned when the model holds a symlink under
+	// the SymlinkReject policy. The error is a *SymlinkError naming it.
+	ErrSymlinkNotAllowed = errors.New("symlink not allowed")
+
+	// ErrWalkFailed wraps the errors found while walking the model tree.
+	ErrWalkFailed = errors.New("failed to walk directory")
+
+	// ErrHashFailed wraps the errors found while reading and hashing the
+	// model files. The file that failed is named by a wrapped *HashError.
+	ErrHashFailed = errors.New("failed to hash files")
+
+	// ErrPartial is returned along with a partial manifest under the
+	// ContinueOnError option, when some files could not be hashed. The
+	// error also wraps ErrHashFailed and joins a *HashError per file left
+	// out of the manifest.
+	ErrPartial = errors.New("partial manifest")
+
+	// ErrUnsupportedFileType is returned when the model holds a device, a
+	// named pipe or a socket, unless the SkipSpecialFiles option is set.
+	ErrUnsupportedFileType = errors.New("unsupported file type")
+
+	// ErrUnsafePath is returned for manifest file names that are absolute
+	// or hold ".." components, which could resolve outside of the model
+	// directory, and for names holding control characters under the
+	// RejectControlChars option.
+	ErrUnsafePath = errors.New("unsafe file path")
+)
+
+// SymlinkError reports a symlink found in the model under the
+// SymlinkReject policy. Path is the path of the link.
+type SymlinkError struct {
+	Path string
+}
+
+func (e *SymlinkError) Error() string {
+	return fmt.Sprintf("%s: %s (use SymlinkPolicy option)", ErrSymlinkNotAllowed, e.Path)
+}
+
+// Is makes errors.Is match SymlinkError with ErrSymlinkNotAllowed.
+func (e *SymlinkError) Is(target error) bool {
+	return target == ErrSymlinkNotAllowed
+}
+
+// HashError reports the failure to hash the model file Name, as recorded
+// in the manifest.
+type HashError struct {
+	Name string
+	Err  error
+}
+
+func (e *HashError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *HashError) Unwrap() error {
+	return e.Err
+}
+
+// checkSafeName returns an ErrUnsafePath error if the manifest file name is
+// absolute or has a ".." component. Both slashes and backslashes are
+// taken as separators, so names are safe on every platform.
+func checkSafeName(name string) error {
+	isSeparator := func(r rune) bool { return r == '/' || r == '\\' }
+
+	// Shard names hold colons too, only a drive letter followed by a
+	// separator is an absolute Windows path
+	if name != "" && isSeparator(rune(name[0])) ||
+		len(name) >= 3 && name[1] == ':' && isSeparator(rune(name[2])) {
+		return fmt.Errorf("%w: %q is absolute", ErrUnsafePath, name)
+	}
+	for part := range strings.FieldsFuncSeq(name, isSeparator) {
+		if part == ".." {
+			return fmt.Errorf("%w: %q has a .. component", ErrUnsafePath, name)
+		}
+	}
+	return nil
+}
+
+// checkControlChars returns an ErrUnsafePath error if the file name holds
+// a control character (NUL, newline, tab, escape, DEL or a C1 control),
+// which could make tools reading manifests line by line misparse them.
+func checkControlChars(name string) error {
+	if i := strings.IndexFunc(name, unicode.IsControl); i >= 0 {
+		return fmt.Errorf("%w: %q holds the control character %U", ErrUnsafePath, name, []rune(name[i:])[0])
+	}
+	return nil
+}
@@ -0,0 +1,245 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package dir
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/carabiner-dev/model-signing/internal/serializer/options"
+	intoto "github.com/in-toto/attestation/go/v1"
+)
+
+func TestTypedErrors(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "modeldigest-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	if err := os.WriteFile(filepath.Join(tempDir, "model.bin"), []byte("weights"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tempDir, "corrupt.gz"), []byte("not gzip"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	link := filepath.Join(tempDir, "link.bin")
+	if err := os.Symlink("model.bin", link); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+
+	// Symlinks are rejected during the walk
+	for _, confine := range []bool{false, true} {
+		opts := options.Default()
+		opts.ConfineToRoot = confine
+		_, err := New(opts).Serialize(tempDir)
+		if !errors.Is(err, ErrSymlinkNotAllowed) || !errors.Is(err, ErrWalkFailed) {
+			t.Fatalf("ConfineToRoot %v: expected ErrSymlinkNotAllowed and ErrWalkFailed, got %v", confine, err)
+		}
+		var symlinkErr *SymlinkError
+		if !errors.As(err, &symlinkErr) || symlinkErr.Path != link {
+			t.Errorf("ConfineToRoot %v: expected a SymlinkError for %s, got %v", confine, link, err)
+		}
+	}
+
+	// Corrupt compressed files fail the hashing
+	opts := options.Default()
+	opts.AllowSymlinks = true
+	opts.DecompressExtensions = map[string]options.Compression{".gz": options.CompressionGzip}
+	_, err = New(opts).Serialize(tempDir)
+	if !errors.Is(err, ErrHashFailed) || errors.Is(err, ErrWalkFailed) {
+		t.Fatalf("Expected ErrHashFailed, got %v", err)
+	}
+	var hashErr *HashError
+	if !errors.As(err, &hashErr) || hashErr.Name != "corrupt.gz" {
+		t.Errorf("Expected a HashError for corrupt.gz, got %v", err)
+	}
+
+	// Archives report their symlinks too
+	archive := buildTar(t, []tarEntry{{name: "link.bin", typeflag: tar.TypeSymlink, linkname: "model.bin"}})
+	if _, err := SerializeTar(bytes.NewReader(archive), nil); !errors.Is(err, ErrSymlinkNotAllowed) {
+		t.Errorf("Expected ErrSymlinkNotAllowed from SerializeTar, got %v", err)
+	}
+}
+
+func TestUnsafePath(t *testing.T) {
+	tempDir, manifest := newTestManifest(t)
+	digest := manifest.Files[0].Digest["sha256"]
+
+	for _, tc := range []struct {
+		name   string
+		unsafe bool
+	}{
+		{"model.bin", false},
+		{"subdir/layer.bin", false},
+		{"model.bin:0:10", false},
+		{"a:0:10", false},
+		{"cache/", false},
+		{"weights..bin", false},
+		{"../../etc/passwd", true},
+		{"subdir/../../escape", true},
+		{"..", true},
+		{"/etc/passwd", true},
+		{`..\escape`, true},
+		{`\\server\share`, true},
+		{"C:/Windows", true},
+		{`c:\Windows`, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			data := []byte(`{"modelName":"model","hashAlgorithm":"sha256","files":[{"name":` +
+				strconv.Quote(tc.name) + `,"digest":{"sha256":"` + digest + `"}}]}`)
+			var decoded Manifest
+			err := json.Unmarshal(data, &decoded)
+			if got := errors.Is(err, ErrUnsafePath); got != tc.unsafe {
+				t.Errorf("UnmarshalJSON: expected unsafe %v, got error %v", tc.unsafe, err)
+			}
+
+			m := &Manifest{Files: []*intoto.ResourceDescriptor{{Name: tc.name, Digest: map[string]string{"sha256": digest}}}}
+			err = New(nil).Verify(tempDir, m)
+			if got := errors.Is(err, ErrUnsafePath); got != tc.unsafe {
+				t.Errorf("Verify: expected unsafe %v, got error %v", tc.unsafe, err)
+			}
+		})
+	}
+}
+
+func TestContinueOnError(t *testing.T) {
+	tempDir, expected := newTestManifest(t)
+	for _, name := range []string{"a.gz", "b.gz"} {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte("not gzip"), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	opts := options.Default()
+	opts.DecompressExtensions = map[string]options.Compression{".gz": options.CompressionGzip}
+
+	// Without the option the first failure is fatal
+	if manifest, err := New(opts).Serialize(tempDir); manifest != nil || errors.Is(err, ErrPartial) {
+		t.Fatalf("Expected no manifest and a non-partial error, got %v", err)
+	}
+
+	for _, limit := range []int{0, 100} {
+		opts.ContinueOnError = true
+		opts.MaxFiles = limit
+		manifest, err := New(opts).Serialize(tempDir)
+		if !errors.Is(err, ErrPartial) || !errors.Is(err, ErrHashFailed) {
+			t.Fatalf("MaxFiles %d: expected ErrPartial and ErrHashFailed, got %v", limit, err)
+		}
+		if manifest == nil {
+			t.Fatalf("MaxFiles %d: expected a partial manifest", limit)
+		}
+		if diff := Compare(expected, manifest); !diff.Empty() {
+			t.Errorf("MaxFiles %d: unexpected partial manifest: %v", limit, diff)
+		}
+		if manifest.Stats.FailedFiles != 2 {
+			t.Errorf("MaxFiles %d: expected 2 failed files, got %d", limit, manifest.Stats.FailedFiles)
+		}
+
+		var hashErr *HashError
+		if !errors.As(err, &hashErr) || hashErr.Name != "a.gz" {
+			t.Errorf("MaxFiles %d: expected a HashError for a.gz first, got %v", limit, err)
+		}
+		if !strings.Contains(err.Error(), "b.gz") {
+			t.Errorf("MaxFiles %d: expected the failure of b.gz to be reported, got %v", limit, err)
+		}
+	}
+}
+
+func TestRejectControlChars(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "modeldigest-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	name := "model\nconfig.json"
+	if err := os.WriteFile(filepath.Join(tempDir, name), []byte("{}"), 0644); err != nil {
+		t.Skipf("File system does not allow newlines in names: %v", err)
+	}
+
+	// Rejected by default
+	if _, err := New(options.Default()).Serialize(tempDir); !errors.Is(err, ErrUnsafePath) {
+		t.Fatalf("Expected ErrUnsafePath, got %v", err)
+	}
+
+	archive := buildTar(t, []tarEntry{{name: "a\tb.bin", typeflag: tar.TypeReg, content: "weights"}})
+	if _, err := SerializeTar(bytes.NewReader(archive), nil); !errors.Is(err, ErrUnsafePath) {
+		t.Errorf("Expected ErrUnsafePath from SerializeTar, got %v", err)
+	}
+
+	// Recorded as found otherwise
+	manifest, err := NewWithOptions(options.WithRejectControlChars(false)).Serialize(tempDir)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	if len(manifest.Files) != 1 || manifest.Files[0].Name != name {
+		t.Errorf("Expected the file %q, got %v", name, manifest.Files)
+	}
+}
+
+func TestStrict(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		entries []tarEntry
+		opts    []options.Option
+		err     error
+	}{
+		{
+			"symlink",
+			[]tarEntry{{name: "model.bin", typeflag: tar.TypeReg, content: "weights"}, {name: "link.bin", typeflag: tar.TypeSymlink, linkname: "model.bin"}},
+			[]options.Option{options.WithSymlinkPolicy(options.SymlinkRecordLink)},
+			ErrSymlinkNotAllowed,
+		},
+		{
+			"special file",
+			[]tarEntry{{name: "model.bin", typeflag: tar.TypeReg, content: "weights"}, {name: "fifo", typeflag: tar.TypeFifo}},
+			[]options.Option{options.WithSkipSpecialFiles(true)},
+			ErrUnsupportedFileType,
+		},
+		{
+			"control characters",
+			[]tarEntry{{name: "a\tb.bin", typeflag: tar.TypeReg, content: "weights"}},
+			[]options.Option{options.WithRejectControlChars(false)},
+			ErrUnsafePath,
+		},
+		{
+			"normalization",
+			[]tarEntry{{name: "caf\u00e9.bin", typeflag: tar.TypeReg, content: "a"}, {name: "cafe\u0301.bin", typeflag: tar.TypeReg, content: "b"}},
+			[]options.Option{options.WithNameNormalization(options.NormalizationNone)},
+			ErrDuplicateName,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			archive := buildTar(t, tc.entries)
+
+			// Each guard is off on its own
+			opts := options.Default().Apply(tc.opts...)
+			if _, err := SerializeTar(bytes.NewReader(archive), opts); err != nil {
+				t.Fatalf("SerializeTar failed: %v", err)
+			}
+
+			opts.Strict = true
+			if _, err := SerializeTar(bytes.NewReader(archive), opts); !errors.Is(err, tc.err) {
+				t.Errorf("Expected %v under Strict, got %v", tc.err, err)
+			}
+		})
+	}
+
+	// Snapshots are made of symlinks
+	tempDir, _ := newTestManifest(t)
+	if _, err := NewWithOptions(options.WithStrict(true), options.WithHuggingFaceSnapshot(true)).Serialize(tempDir); err == nil {
+		t.Error("Expected HuggingFaceSnapshot to fail under Strict")
+	}
+	if _, err := NewWithOptions(options.WithStrict(true)).Serialize(tempDir); err != nil {
+		t.Errorf("Serialize failed under Strict: %v", err)
+	}
+}
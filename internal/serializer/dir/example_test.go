@@ -112,3 +112,33 @@ func ExampleOptions_IgnorePaths() {
 	// Files included: 1
 	// First file: model.bin
 }
+
+func ExampleNewWithOptions() {
+	// Create a temporary model directory
+	tempDir, err := os.MkdirTemp("", "example-model-*")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	os.WriteFile(filepath.Join(tempDir, "model.bin"), []byte("weights"), 0644)
+	os.WriteFile(filepath.Join(tempDir, "train.log"), []byte("log"), 0644)
+
+	// Build the serializer inline with functional options
+	serializer := modeldigest.NewWithOptions(
+		options.WithIgnorePaths("*.log"),
+		options.WithRecordSizes(true),
+	)
+
+	manifest, err := serializer.Serialize(tempDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, file := range manifest.Files {
+		fmt.Println(file.Name)
+	}
+
+	// Output:
+	// model.bin
+}
@@ -0,0 +1,94 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package dir
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// validateExternalName checks that an external file name is a clean,
+// relative, slash-separated path so it cannot be confused with another
+// entry of the manifest.
+func validateExternalName(name string) error {
+	if name == "" {
+		return fmt.Errorf("external file name cannot be empty")
+	}
+	if strings.Contains(name, "\\") {
+		return fmt.Errorf("external file name %q must use forward slashes", name)
+	}
+	if path.IsAbs(name) {
+		return fmt.Errorf("external file name %q must be relative", name)
+	}
+	if path.Clean(name) != name || name == "." || name == ".." || strings.HasPrefix(name, "../") {
+		return fmt.Errorf("external file name %q is not a clean path", name)
+	}
+	return nil
+}
+
+// addExternalFiles hashes the files declared in the ExternalFiles option
+// and records them in the manifest under their declared names.
+func (s *Serializer) addExternalFiles(ctx context.Context, manifest *Manifest) error {
+	if len(s.opts.ExternalFiles) == 0 {
+		return nil
+	}
+
+	existing := make(map[string]struct{}, len(manifest.Files))
+	for _, file := range manifest.Files {
+		existing[file.Name] = struct{}{}
+	}
+
+	// Sorted, so the collisions are reported the same on every run
+	names := make([]string, 0, len(s.opts.ExternalFiles))
+	for name := range s.opts.ExternalFiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	added := map[string]string{}
+	for _, name := range names {
+		filePath := s.opts.ExternalFiles[name]
+		if err := validateExternalName(name); err != nil {
+			return err
+		}
+		if s.rejectControlChars() {
+			if err := checkControlChars(name); err != nil {
+				return err
+			}
+		}
+		original := name
+		name = s.normalizeName(name)
+		if _, ok := existing[name]; ok {
+			return fmt.Errorf("external file name %q collides with a model file", name)
+		}
+		if previous, ok := added[name]; ok {
+			return fmt.Errorf("%w: external files %q and %q are both recorded as %q", ErrDuplicateName, previous, original, name)
+		}
+		added[name] = original
+
+		descriptors, err := s.hashFile(ctx, name, func(string) (io.ReadCloser, error) {
+			return os.Open(filePath)
+		})
+		if err != nil {
+			failure := &HashError{Name: name, Err: err}
+			if s.skipFailure(ctx, failure) {
+				continue
+			}
+			return fmt.Errorf("%w: external file %s: %w", ErrHashFailed, name, failure)
+		}
+
+		manifest.Files = append(manifest.Files, descriptors...)
+	}
+
+	sort.Slice(manifest.Files, func(i, j int) bool {
+		return manifest.Files[i].Name < manifest.Files[j].Name
+	})
+
+	return nil
+}
@@ -0,0 +1,111 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package dir
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/carabiner-dev/model-signing/internal/serializer/options"
+)
+
+func TestExternalFiles(t *testing.T) {
+	modelDir, err := os.MkdirTemp("", "modeldigest-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(modelDir)
+
+	sharedDir, err := os.MkdirTemp("", "modeldigest-shared-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(sharedDir)
+
+	if err := os.WriteFile(filepath.Join(modelDir, "model.bin"), []byte("weights"), 0644); err != nil {
+		t.Fatalf("Failed to create model file: %v", err)
+	}
+	vocab := filepath.Join(sharedDir, "vocab.txt")
+	if err := os.WriteFile(vocab, []byte("a\nb\nc\n"), 0644); err != nil {
+		t.Fatalf("Failed to create vocab file: %v", err)
+	}
+
+	t.Run("IncludedInDigest", func(t *testing.T) {
+		opts := options.Default()
+		opts.ExternalFiles = map[string]string{"shared/vocab.txt": vocab}
+
+		manifest, err := New(opts).Serialize(modelDir)
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+
+		if len(manifest.Files) != 2 {
+			t.Fatalf("Expected 2 files, got %d", len(manifest.Files))
+		}
+		if manifest.Files[1].Name != "shared/vocab.txt" {
+			t.Errorf("Expected shared/vocab.txt, got %s", manifest.Files[1].Name)
+		}
+
+		// The digest must match a directory holding the same tree
+		unionDir, err := os.MkdirTemp("", "modeldigest-union-*")
+		if err != nil {
+			t.Fatalf("Failed to create temp dir: %v", err)
+		}
+		defer os.RemoveAll(unionDir)
+
+		if err := os.WriteFile(filepath.Join(unionDir, "model.bin"), []byte("weights"), 0644); err != nil {
+			t.Fatalf("Failed to create model file: %v", err)
+		}
+		if err := os.Mkdir(filepath.Join(unionDir, "shared"), 0755); err != nil {
+			t.Fatalf("Failed to create shared dir: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(unionDir, "shared", "vocab.txt"), []byte("a\nb\nc\n"), 0644); err != nil {
+			t.Fatalf("Failed to create vocab file: %v", err)
+		}
+
+		expected, err := ComputeDigest(unionDir, options.Default())
+		if err != nil {
+			t.Fatalf("ComputeDigest failed: %v", err)
+		}
+		digest, err := ComputeDigest(modelDir, opts)
+		if err != nil {
+			t.Fatalf("ComputeDigest failed: %v", err)
+		}
+		if digest != expected {
+			t.Errorf("Expected digest %s, got %s", expected, digest)
+		}
+	})
+
+	t.Run("Collision", func(t *testing.T) {
+		opts := options.Default()
+		opts.ExternalFiles = map[string]string{"model.bin": vocab}
+
+		if _, err := New(opts).Serialize(modelDir); err == nil {
+			t.Error("Expected error for external name colliding with a model file")
+		}
+	})
+
+	t.Run("DuplicateNames", func(t *testing.T) {
+		// Both names normalize to the NFC form
+		opts := options.Default()
+		opts.ExternalFiles = map[string]string{"caf\u00e9.txt": vocab, "cafe\u0301.txt": vocab}
+
+		if _, err := New(opts).Serialize(modelDir); !errors.Is(err, ErrDuplicateName) {
+			t.Errorf("Expected ErrDuplicateName, got %v", err)
+		}
+	})
+
+	t.Run("InvalidNames", func(t *testing.T) {
+		for _, name := range []string{"", "/abs/vocab.txt", "../vocab.txt", "shared//vocab.txt", "./vocab.txt", `shared\vocab.txt`} {
+			opts := options.Default()
+			opts.ExternalFiles = map[string]string{name: vocab}
+
+			if _, err := New(opts).Serialize(modelDir); err == nil {
+				t.Errorf("Expected error for external name %q", name)
+			}
+		}
+	})
+}
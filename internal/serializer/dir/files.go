@@ -0,0 +1,133 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package dir
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/carabiner-dev/model-signing/internal/serializer/options"
+)
+
+// SerializeFiles serializes the model at root made of the given files,
+// without walking its directory, for when another tool already knows the
+// files to sign. files are paths relative to root, slash-separated or
+// using the OS separator; paths that are absolute or leave root fail with
+// ErrUnsafePath. Each must be a regular file, or a symlink handled as
+// the SymlinkPolicy says. Files are read through an os.Root opened at
+// root, as with ConfineToRoot, so symlinks are only followed within it.
+//
+// The manifest is the one Serialize produces for a copy of root holding
+// only these files, and is named after root. The ignore options,
+// FilterFunc and MaxFileSize do not apply to the files listed; the other
+// options, like ExternalFiles, PostHash or MaxFiles, do.
+func SerializeFiles(root string, files []string, opts *options.Options) (*Manifest, error) {
+	s := New(opts)
+	ctx := withStats(context.Background())
+
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve model path: %w", err)
+	}
+	if err := s.validateAlgorithms(); err != nil {
+		return nil, err
+	}
+	if err := s.validateSymlinkPolicy(); err != nil {
+		return nil, err
+	}
+	if err := s.validateMethod(); err != nil {
+		return nil, err
+	}
+	if err := s.validateDigestEncoding(); err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(files))
+	for _, file := range files {
+		name := path.Clean(filepath.ToSlash(file))
+		if !filepath.IsLocal(file) || name == "." {
+			return nil, fmt.Errorf("%w: %q is not a file under the model root", ErrUnsafePath, file)
+		}
+		names = append(names, name)
+	}
+
+	r, err := os.OpenRoot(absRoot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open model root: %w", err)
+	}
+	defer r.Close() //nolint:errcheck
+
+	links := s.recordedLinks(confinedReadLink(r, absRoot))
+	fileDescriptors, walkErr, hashErr := s.hashWalk(ctx, func(yield func(string) error) error {
+		for _, name := range names {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := s.yieldListed(r, absRoot, name, links, yield); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, links.opener(confinedOpener(r)))
+	if walkErr != nil {
+		return nil, fmt.Errorf("%w: %w", ErrWalkFailed, walkErr)
+	}
+	if hashErr != nil {
+		return nil, fmt.Errorf("%w: %w", ErrHashFailed, hashErr)
+	}
+
+	manifest, err := s.newManifest(filepath.Base(absRoot), fileDescriptors)
+	if err != nil {
+		return nil, err
+	}
+	manifest.ModelPath = absRoot
+	if err := s.finishManifest(ctx, manifest); err != nil {
+		return nil, err
+	}
+
+	return manifest, statsFrom(ctx).partialErr()
+}
+
+// yieldListed checks the file name listed to SerializeFiles, in the model
+// at absRoot opened as root, and yields it when it is to be hashed.
+// Symlinks are handled as walkFS does.
+func (s *Serializer) yieldListed(root *os.Root, absRoot, name string, links *recordedLinks, yield func(name string) error) error {
+	path := filepath.Join(absRoot, filepath.FromSlash(name))
+	info, err := root.Lstat(filepath.FromSlash(name))
+	if err != nil {
+		return err
+	}
+
+	if info.Mode()&fs.ModeSymlink != 0 {
+		switch s.symlinkPolicy() {
+		case options.SymlinkReject:
+			return &SymlinkError{Path: path}
+		case options.SymlinkRecordLink:
+			if err := links.add(name, info); err != nil {
+				return err
+			}
+			return yield(name)
+		}
+		info, err = root.Stat(filepath.FromSlash(name))
+		if err != nil {
+			if s.opts.SkipExternalSymlinks {
+				return nil
+			}
+			return fmt.Errorf("resolving symlink %s: %w", path, err)
+		}
+	}
+
+	switch {
+	case info.Mode().IsRegular():
+		return yield(name)
+	case info.IsDir():
+		return fmt.Errorf("%s is a directory, not a file", path)
+	default:
+		return s.specialFile(path, info.Mode().Type())
+	}
+}
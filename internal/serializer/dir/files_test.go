@@ -0,0 +1,83 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package dir
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/carabiner-dev/model-signing/internal/serializer/options"
+)
+
+func TestSerializeFiles(t *testing.T) {
+	tempDir, _ := newTestManifest(t)
+	if err := os.WriteFile(filepath.Join(tempDir, "notes.txt"), []byte("notes"), 0644); err != nil {
+		t.Fatalf("Failed to create notes.txt: %v", err)
+	}
+
+	expected, err := New(options.Default().Apply(options.WithIgnorePaths("config.json", "notes.txt"))).Serialize(tempDir)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+
+	if err := os.Symlink("model.bin", filepath.Join(tempDir, "link.bin")); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+
+	// Any order and separator, the manifest is sorted
+	manifest, err := SerializeFiles(tempDir, []string{filepath.Join("subdir", "layer.bin"), "./model.bin"}, nil)
+	if err != nil {
+		t.Fatalf("SerializeFiles failed: %v", err)
+	}
+	if names := manifestNames(manifest); !slices.Equal(names, []string{"model.bin", "subdir/layer.bin"}) {
+		t.Errorf("Expected the listed files, got %v", names)
+	}
+	if manifest.ModelName != filepath.Base(tempDir) {
+		t.Errorf("Expected model name %s, got %s", filepath.Base(tempDir), manifest.ModelName)
+	}
+	if diff := Compare(expected, manifest); !diff.Empty() {
+		t.Errorf("SerializeFiles differs from Serialize: %+v", diff)
+	}
+	if manifest.Stats.FileCount != 2 || manifest.Stats.BytesHashed != 12 {
+		t.Errorf("Expected 2 files and 12 bytes hashed, got %+v", manifest.Stats)
+	}
+
+	t.Run("symlinks", func(t *testing.T) {
+		if _, err := SerializeFiles(tempDir, []string{"link.bin"}, nil); !errors.Is(err, ErrSymlinkNotAllowed) {
+			t.Errorf("Expected ErrSymlinkNotAllowed, got %v", err)
+		}
+		manifest, err := SerializeFiles(tempDir, []string{"link.bin"}, options.Default().Apply(options.WithSymlinkPolicy(options.SymlinkFollowInternal)))
+		if err != nil {
+			t.Fatalf("SerializeFiles failed: %v", err)
+		}
+		link, _ := manifest.GetFile("link.bin")
+		model, _ := expected.GetFile("model.bin")
+		if link.GetDigest()["sha256"] != model.GetDigest()["sha256"] {
+			t.Errorf("Expected the link to hash as its target, got %v", link.GetDigest())
+		}
+	})
+
+	for _, tc := range []struct {
+		name  string
+		files []string
+		err   error
+	}{
+		{"parent", []string{"../model.bin"}, ErrUnsafePath},
+		{"escaping", []string{"subdir/../../model.bin"}, ErrUnsafePath},
+		{"absolute", []string{filepath.Join(tempDir, "model.bin")}, ErrUnsafePath},
+		{"root", []string{"subdir/.."}, ErrUnsafePath},
+		{"directory", []string{"subdir"}, ErrWalkFailed},
+		{"missing", []string{"missing.bin"}, ErrWalkFailed},
+		{"duplicate", []string{"model.bin", "./model.bin"}, ErrDuplicateName},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := SerializeFiles(tempDir, tc.files, nil); !errors.Is(err, tc.err) {
+				t.Errorf("Expected %v, got %v", tc.err, err)
+			}
+		})
+	}
+}
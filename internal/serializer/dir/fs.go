@@ -0,0 +1,251 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package dir
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/carabiner-dev/model-signing/internal/serializer/options"
+)
+
+// SerializeFS is like Serialize but reads the model from the directory
+// root of fsys, so embedded filesystems, archives or any other fs.FS can
+// be serialized. root must be a valid fs.FS path ("." for the top of
+// fsys). Ignore paths and git paths apply to the paths relative to root
+// exactly as they do to a model directory; absolute ignore paths cannot
+// point into fsys and are handled as external ones. Symlinks follow the
+// SymlinkPolicy as in Serialize: their targets are resolved in fsys, and
+// those leaving the model root are external. Following links of a file
+// system unable to read them, one without ReadLink and Lstat methods,
+// needs SymlinkFollowAll.
+func (s *Serializer) SerializeFS(fsys fs.FS, root string) (*Manifest, error) {
+	ctx := withStats(context.Background())
+
+	if !fs.ValidPath(root) {
+		return nil, fmt.Errorf("invalid model root %q", root)
+	}
+
+	if err := s.validateAlgorithms(); err != nil {
+		return nil, err
+	}
+	if err := s.validateSymlinkPolicy(); err != nil {
+		return nil, err
+	}
+	if err := s.validateMethod(); err != nil {
+		return nil, err
+	}
+	if err := s.validateDigestEncoding(); err != nil {
+		return nil, err
+	}
+
+	sub, err := fs.Sub(fsys, root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open model root: %w", err)
+	}
+
+	rules, err := s.newIgnoreRules("")
+	if err != nil {
+		return nil, err
+	}
+
+	links := s.recordedLinks(fsReadLink(sub))
+	fileDescriptors, walkErr, hashErr := s.hashWalk(ctx, func(yield func(string) error) error {
+		return s.walkFS(ctx, sub, root, ".", rules, links, rules.yieldIncluded(yield))
+	}, links.opener(func(name string) (io.ReadCloser, error) {
+		return sub.Open(name)
+	}))
+	if walkErr != nil {
+		return nil, fmt.Errorf("%w: %w", ErrWalkFailed, walkErr)
+	}
+	if hashErr != nil {
+		return nil, fmt.Errorf("%w: %w", ErrHashFailed, hashErr)
+	}
+
+	manifest, err := s.newManifest(path.Base(root), fileDescriptors)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.finishManifest(ctx, manifest); err != nil {
+		return nil, err
+	}
+
+	return manifest, statsFrom(ctx).partialErr()
+}
+
+// walkFS walks the directory start of fsys, calling yield with the name
+// of every file to hash. base is the location of the model, used in error
+// messages only. Both the ConfineToRoot mode and SerializeFS use it, so
+// directory listings never leave fsys. Directory symlinks are followed by
+// walking them as the start of a new walk.
+func (s *Serializer) walkFS(ctx context.Context, fsys fs.FS, base, start string, rules *ignoreRules, links *recordedLinks, yield func(name string) error) error {
+	return fs.WalkDir(fsys, start, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return s.walkError(name, d, err)
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if d.Type()&fs.ModeSymlink != 0 {
+			path := filepath.Join(base, filepath.FromSlash(name))
+			policy := s.symlinkPolicy()
+			if policy == options.SymlinkReject {
+				return &SymlinkError{Path: path}
+			}
+			if rules.match(name, false) {
+				return nil
+			}
+
+			if policy == options.SymlinkRecordLink {
+				info, err := d.Info()
+				if err != nil {
+					return err
+				}
+				if keep, err := rules.keep(name, d.Info); err != nil || !keep {
+					return err
+				}
+				if err := links.add(name, info); err != nil {
+					return err
+				}
+				return yield(name)
+			}
+
+			// Links leaving fsys, or the model root in it, are external
+			if policy != options.SymlinkFollowAll {
+				internal, err := resolveFSLink(fsys, name)
+				if err != nil {
+					return fmt.Errorf("resolving symlink %s: %w", path, err)
+				}
+				if !internal {
+					return s.externalSymlink(path)
+				}
+			}
+			info, err := fs.Stat(fsys, name)
+			if err != nil {
+				if s.opts.SkipExternalSymlinks {
+					return nil
+				}
+				return fmt.Errorf("resolving symlink %s: %w", path, err)
+			}
+			if info.IsDir() {
+				if err := checkSymlinkLoop(name, info, func(name string) (fs.FileInfo, error) {
+					return fs.Stat(fsys, name)
+				}); err != nil {
+					return err
+				}
+				return s.walkFS(ctx, fsys, base, name, rules, links, yield)
+			}
+			if keep, err := rules.keep(name, func() (fs.FileInfo, error) { return info, nil }); err != nil || !keep {
+				return err
+			}
+			if info.Mode().IsRegular() {
+				return yield(name)
+			}
+			return s.specialFile(path, info.Mode().Type())
+		}
+
+		ignore := rules.match(name, d.IsDir())
+
+		if !ignore {
+			keep, err := rules.keep(name, d.Info)
+			if err != nil {
+				return err
+			}
+			ignore = !keep
+		}
+
+		if d.IsDir() {
+			if ignore {
+				return fs.SkipDir
+			}
+			if s.opts.RecordEmptyDirs && name != "." {
+				if err := yield(name + "/"); err != nil {
+					return err
+				}
+			}
+			return rules.loadIgnoreFiles(name, func(name string) ([]byte, error) {
+				return fs.ReadFile(fsys, name)
+			})
+		}
+
+		if ignore {
+			return nil
+		}
+
+		if d.Type().IsRegular() {
+			return yield(name)
+		}
+
+		return s.specialFile(filepath.Join(base, filepath.FromSlash(name)), d.Type())
+	})
+}
+
+// maxLinkHops is the number of symlinks resolveFSLink follows resolving a
+// path before giving up, as the kernel does.
+const maxLinkHops = 40
+
+// linkFS is implemented by the file systems able to read symlinks, like
+// os.DirFS and the fs.Sub of one in recent Go releases.
+type linkFS interface {
+	readLinkFS
+	Lstat(name string) (fs.FileInfo, error)
+}
+
+// resolveFSLink resolves the symlink name of fsys, following every link
+// found on the way to its target, and returns whether the target is in
+// fsys. Absolute targets and ".." components above the top of fsys leave
+// it. File systems that cannot read links fail.
+func resolveFSLink(fsys fs.FS, name string) (bool, error) {
+	lfs, ok := fsys.(linkFS)
+	if !ok {
+		return false, errors.New("the file system cannot read symlinks (use SymlinkFollowAll)")
+	}
+
+	pending, resolved, hops := strings.Split(name, "/"), ".", 0
+	for len(pending) > 0 {
+		part := pending[0]
+		pending = pending[1:]
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			if resolved == "." {
+				return false, nil
+			}
+			resolved = path.Dir(resolved)
+			continue
+		}
+
+		next := path.Join(resolved, part)
+		info, err := lfs.Lstat(next)
+		if err != nil {
+			return false, err
+		}
+		if info.Mode()&fs.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+		if hops++; hops > maxLinkHops {
+			return false, ErrSymlinkLoop
+		}
+		target, err := lfs.ReadLink(next)
+		if err != nil {
+			return false, err
+		}
+		target = filepath.ToSlash(target)
+		if path.IsAbs(target) || filepath.IsAbs(target) {
+			return false, nil
+		}
+		pending = append(strings.Split(target, "/"), pending...)
+	}
+	return true, nil
+}
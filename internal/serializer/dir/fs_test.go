@@ -0,0 +1,220 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package dir
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/carabiner-dev/model-signing/internal/serializer/options"
+)
+
+func TestSerializeFS(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "modeldigest-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	testFiles := map[string]string{
+		"model.bin":          "weights",
+		"config.json":        "{}",
+		"subdir/layer.bin":   "layer",
+		".git/config":        "git config",
+		"subdir/nested/data": "nested",
+		"logs/run.log":       "log",
+	}
+	fsys := fstest.MapFS{}
+	modelDir := filepath.Join(tempDir, "model")
+	for name, content := range testFiles {
+		path := filepath.Join(modelDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create dir for %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test file %s: %v", name, err)
+		}
+		fsys["model/"+name] = &fstest.MapFile{Data: []byte(content), Mode: 0644}
+	}
+
+	opts := options.Default()
+	opts.IgnorePaths = []string{"subdir/nested", "logs/"}
+
+	expected, err := New(opts).Serialize(modelDir)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+
+	manifest, err := New(opts).SerializeFS(fsys, "model")
+	if err != nil {
+		t.Fatalf("SerializeFS failed: %v", err)
+	}
+
+	if manifest.ModelName != "model" {
+		t.Errorf("Expected model name model, got %s", manifest.ModelName)
+	}
+	if len(manifest.Files) != 3 {
+		t.Errorf("Expected 3 files, got %d", len(manifest.Files))
+		for _, f := range manifest.Files {
+			t.Logf("  Found file: %s", f.Name)
+		}
+	}
+
+	expectedDigest, err := ComputeRootDigest(expected)
+	if err != nil {
+		t.Fatalf("ComputeRootDigest failed: %v", err)
+	}
+	digest, err := ComputeRootDigest(manifest)
+	if err != nil {
+		t.Fatalf("ComputeRootDigest failed: %v", err)
+	}
+	if digest != expectedDigest {
+		t.Errorf("SerializeFS digest %s does not match Serialize %s", digest, expectedDigest)
+	}
+
+	if _, err := New(nil).SerializeFS(fsys, "../model"); err == nil {
+		t.Error("Expected error for invalid root")
+	}
+	if _, err := New(nil).SerializeFS(fsys, "missing"); err == nil {
+		t.Error("Expected error for missing root")
+	}
+
+	// Absolute ignore paths are external to file systems
+	opts.IgnorePaths = append(opts.IgnorePaths, filepath.Join(modelDir, "config.json"))
+	if _, err := New(opts).SerializeFS(fsys, "model"); err == nil || !strings.Contains(err.Error(), "outside of the model directory") {
+		t.Errorf("Expected error for an absolute ignore path, got %v", err)
+	}
+	opts.AllowExternalIgnorePaths = true
+	manifest, err = New(opts).SerializeFS(fsys, "model")
+	if err != nil {
+		t.Fatalf("SerializeFS failed: %v", err)
+	}
+	if diff := Compare(expected, manifest); !diff.Empty() {
+		t.Errorf("Expected the absolute ignore path to be skipped: %+v", diff)
+	}
+}
+
+// unreadableDirFS is a file system failing to list the directory dir.
+type unreadableDirFS struct {
+	fsys fstest.MapFS
+	dir  string
+}
+
+func (f unreadableDirFS) Open(name string) (fs.File, error) {
+	return f.fsys.Open(name)
+}
+
+func (f unreadableDirFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if name == f.dir {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errUnreadable}
+	}
+	return f.fsys.ReadDir(name)
+}
+
+var errUnreadable = errors.New("stale file handle")
+
+func TestOnWalkError(t *testing.T) {
+	fsys := unreadableDirFS{fsys: fstest.MapFS{
+		"model/model.bin":          {Data: []byte("weights")},
+		"model/config.json":        {Data: []byte("{}")},
+		"model/subdir/layer.bin":   {Data: []byte("layer")},
+		"model/subdir/nested/data": {Data: []byte("nested")},
+	}, dir: "model/subdir"}
+
+	t.Run("unset", func(t *testing.T) {
+		_, err := New(nil).SerializeFS(fsys, "model")
+		if !errors.Is(err, ErrWalkFailed) || !errors.Is(err, errUnreadable) {
+			t.Errorf("Expected ErrWalkFailed wrapping the walk error, got %v", err)
+		}
+	})
+
+	t.Run("skip", func(t *testing.T) {
+		var failed []string
+		manifest, err := New(options.Default().Apply(options.WithOnWalkError(func(path string, err error) error {
+			if !errors.Is(err, errUnreadable) {
+				t.Errorf("Unexpected error for %s: %v", path, err)
+			}
+			failed = append(failed, path)
+			return nil
+		}))).SerializeFS(fsys, "model")
+		if err != nil {
+			t.Fatalf("SerializeFS failed: %v", err)
+		}
+		if !slices.Equal(failed, []string{"subdir"}) {
+			t.Errorf("Expected a walk error for subdir, got %v", failed)
+		}
+		if names := manifestNames(manifest); !slices.Equal(names, []string{"config.json", "model.bin"}) {
+			t.Errorf("Expected the files outside subdir, got %v", names)
+		}
+	})
+
+	t.Run("abort", func(t *testing.T) {
+		errAbort := errors.New("abort")
+		_, err := New(options.Default().Apply(options.WithOnWalkError(func(string, error) error {
+			return errAbort
+		}))).SerializeFS(fsys, "model")
+		if !errors.Is(err, ErrWalkFailed) || !errors.Is(err, errAbort) {
+			t.Errorf("Expected ErrWalkFailed wrapping the callback error, got %v", err)
+		}
+	})
+}
+
+func TestSerializeFSExternalSymlink(t *testing.T) {
+	tempDir, _ := newTestManifest(t)
+	outside := filepath.Join(t.TempDir(), "secret.bin")
+	if err := os.WriteFile(outside, []byte("secret"), 0644); err != nil {
+		t.Fatalf("Failed to create outside file: %v", err)
+	}
+	for link, target := range map[string]string{
+		"absolute.bin":     outside,
+		"subdir/inner.bin": "../model.bin",
+	} {
+		if err := os.Symlink(target, filepath.Join(tempDir, filepath.FromSlash(link))); err != nil {
+			t.Fatalf("Failed to create symlink: %v", err)
+		}
+	}
+	fsys := os.DirFS(tempDir)
+
+	for _, policy := range []options.SymlinkPolicy{options.SymlinkFollowInternal, options.SymlinkFollowAll} {
+		opts := options.Default().Apply(options.WithSymlinkPolicy(policy))
+		_, serializeErr := New(opts).Serialize(tempDir)
+		_, fsErr := New(opts).SerializeFS(fsys, ".")
+		if (serializeErr == nil) != (fsErr == nil) {
+			t.Errorf("%s: Serialize returned %v but SerializeFS %v", policy, serializeErr, fsErr)
+		}
+		if policy == options.SymlinkFollowInternal && fsErr == nil {
+			t.Errorf("%s: expected an error for the link outside of the model", policy)
+		}
+	}
+
+	// Links leaving the model root through another link, or through ".."
+	// from a subdirectory of the file system, are external too
+	if err := os.Remove(filepath.Join(tempDir, "absolute.bin")); err != nil {
+		t.Fatalf("Failed to remove symlink: %v", err)
+	}
+	if err := os.Symlink("subdir/up.bin", filepath.Join(tempDir, "chain.bin")); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+	if err := os.Symlink("../../"+filepath.Base(tempDir)+"/model.bin", filepath.Join(tempDir, "subdir", "up.bin")); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+	opts := options.Default().Apply(options.WithSymlinkPolicy(options.SymlinkFollowInternal))
+	if _, err := New(opts).SerializeFS(os.DirFS(filepath.Dir(tempDir)), filepath.Base(tempDir)); err == nil {
+		t.Error("Expected an error for a chain of links leaving the model root")
+	}
+	opts.SkipExternalSymlinks = true
+	manifest, err := New(opts).SerializeFS(os.DirFS(filepath.Dir(tempDir)), filepath.Base(tempDir))
+	if err != nil {
+		t.Fatalf("SerializeFS failed: %v", err)
+	}
+	if names := manifestNames(manifest); !slices.Equal(names, []string{"config.json", "model.bin", "subdir/inner.bin", "subdir/layer.bin"}) {
+		t.Errorf("Expected the external links to be skipped, got %v", names)
+	}
+}
@@ -0,0 +1,593 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package dir
+
+import (
+	"compress/gzip"
+	"context"
+	_ "crypto/sha512" // registers the SHA-384/512 hashes looked up through crypto.Hash
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/carabiner-dev/model-signing/internal/serializer/options"
+	intoto "github.com/in-toto/attestation/go/v1"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const (
+	// AnnotationDecompressed is the descriptor annotation recording the
+	// compression format that was removed before hashing a file.
+	AnnotationDecompressed = "decompressed"
+
+	// AnnotationSize is the descriptor annotation recording the size in
+	// bytes of a file, as stored on disk.
+	AnnotationSize = "size"
+
+	// AnnotationMode is the descriptor annotation recording the Unix mode
+	// of a file as an octal string.
+	AnnotationMode = "mode"
+)
+
+// openFunc opens the file recorded under name for reading.
+type openFunc func(name string) (io.ReadCloser, error)
+
+// HashReader hashes the contents of r as the model file name and returns
+// its descriptor, as Serialize would record it, with the digests of the
+// configured algorithms, encoded as DigestEncoding says.
+// DecompressExtensions and RecordSizes apply,
+// ShardSize and Method do not: the stream always produces a single
+// descriptor.
+func HashReader(name string, r io.Reader, opts *options.Options) (*intoto.ResourceDescriptor, error) {
+	if opts == nil {
+		opts = options.Default()
+	}
+	o := *opts
+	o.ShardSize = 0
+	o.Method = options.FilesMethod
+
+	s := New(&o)
+	descriptors, err := s.hashFile(context.Background(), name, func(string) (io.ReadCloser, error) {
+		return io.NopCloser(r), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	descriptors[0].Name = s.normalizeName(descriptors[0].Name)
+	descriptors[0].Digest, err = transcodeDigests(options.DigestEncodingHex, s.digestEncoding(), descriptors[0].Digest)
+	if err != nil {
+		return nil, err
+	}
+	return descriptors[0], nil
+}
+
+// walkFunc walks a model, calling yield with the name of every file to
+// hash. It must stop and return the error yield returns, if any.
+type walkFunc func(yield func(name string) error) error
+
+// errStopWalk is returned by the yield function of hashWalk once hashing
+// failed, to end the walk early.
+var errStopWalk = errors.New("walk stopped")
+
+// hashFiles hashes the named files, reading them through open, and
+// returns their descriptors in the same order (sharded files produce one
+// descriptor per shard). Files are hashed by up to
+// Concurrency workers; the first error, or ctx being done, stops the
+// remaining ones.
+func (s *Serializer) hashFiles(ctx context.Context, names []string, open openFunc) ([]*intoto.ResourceDescriptor, error) {
+	descriptors, _, err := s.hashWalk(ctx, func(yield func(string) error) error {
+		for _, name := range names {
+			if err := yield(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, open)
+	return descriptors, err
+}
+
+// hashWalk runs walk and hashes the files it finds while it goes on, so
+// the walk and the hashing overlap and no list of names is built. The
+// descriptors are returned in the order the files were found. Errors of
+// the walk are returned in walkErr, the first hashing error (or ctx being
+// done) in hashErr; either one stops both the walk and the hashing. With
+// MaxFiles or MaxTotalBytes set, the whole walk runs first so the limits
+// are checked before any file is hashed. So it does with LargestFirst, to
+// hash the files largest first.
+func (s *Serializer) hashWalk(ctx context.Context, walk walkFunc, open openFunc) (descriptors []*intoto.ResourceDescriptor, walkErr, hashErr error) {
+	var (
+		collected    bool
+		names        []string
+		order        []int
+		l            = s.newLimits()
+		largestFirst = s.largestFirst()
+	)
+	if l != nil || largestFirst {
+		names, order, walkErr = s.collectWalk(ctx, walk, open, l)
+		if walkErr != nil {
+			return nil, walkErr, nil
+		}
+		collected = true
+	}
+
+	type job struct {
+		index int
+		name  string
+	}
+
+	var (
+		wg      sync.WaitGroup
+		once    sync.Once
+		mu      sync.Mutex
+		results [][]*intoto.ResourceDescriptor
+	)
+	done := make(chan struct{})
+	jobs := make(chan job)
+
+	for range s.concurrency() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				descriptors, err := s.hashFile(ctx, j.name, open)
+				if err != nil {
+					failure := &HashError{Name: j.name, Err: err}
+					if s.skipFailure(ctx, failure) {
+						continue
+					}
+					once.Do(func() {
+						hashErr = failure
+						close(done)
+					})
+					return
+				}
+
+				mu.Lock()
+				results[j.index] = descriptors
+				mu.Unlock()
+			}
+		}()
+	}
+
+	submit := func(index int, name string) error {
+		select {
+		case jobs <- job{index: index, name: name}:
+			return nil
+		case <-done:
+			return errStopWalk
+		case <-ctx.Done():
+			once.Do(func() {
+				hashErr = fmt.Errorf("serialization canceled: %w", ctx.Err())
+				close(done)
+			})
+			return errStopWalk
+		}
+	}
+
+	if collected {
+		// The results keep the order the files were found in
+		results = make([][]*intoto.ResourceDescriptor, len(names))
+		for _, i := range order {
+			if walkErr = submit(i, names[i]); walkErr != nil {
+				break
+			}
+		}
+	} else {
+		walkErr = walk(func(name string) error {
+			mu.Lock()
+			index := len(results)
+			results = append(results, nil)
+			mu.Unlock()
+
+			return submit(index, name)
+		})
+	}
+	close(jobs)
+	wg.Wait()
+
+	if errors.Is(walkErr, errStopWalk) {
+		walkErr = nil
+	}
+	if walkErr != nil || hashErr != nil {
+		return nil, walkErr, hashErr
+	}
+
+	descriptors = make([]*intoto.ResourceDescriptor, 0, len(results))
+	for _, result := range results {
+		descriptors = append(descriptors, result...)
+	}
+	return descriptors, nil, nil
+}
+
+// skipFailure records the failure to hash a file under ContinueOnError,
+// leaving the file out of the manifest, and returns true. It returns false
+// when the failure must stop the serialization: without the option, or
+// once ctx is done.
+func (s *Serializer) skipFailure(ctx context.Context, failure *HashError) bool {
+	c := statsFrom(ctx)
+	if !s.opts.ContinueOnError || c == nil || ctx.Err() != nil {
+		return false
+	}
+	c.addFailure(failure)
+	return true
+}
+
+// largestFirst returns true when the files are hashed largest first,
+// which only makes a difference with several workers.
+func (s *Serializer) largestFirst() bool {
+	return s.opts.LargestFirst && s.concurrency() > 1
+}
+
+// concurrency returns the number of hashing workers to run.
+func (s *Serializer) concurrency() int {
+	if s.opts.Concurrency <= 0 {
+		return runtime.NumCPU()
+	}
+	return s.opts.Concurrency
+}
+
+// hashFile hashes a single file. Files matching one of the
+// DecompressExtensions are hashed over their decompressed contents, files
+// larger than ShardSize, or all of them with ShardsMethod, produce a
+// descriptor per shard.
+func (s *Serializer) hashFile(ctx context.Context, name string, open openFunc) ([]*intoto.ResourceDescriptor, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("serialization canceled: %w", err)
+	}
+
+	// Empty directory markers hash as empty files, without a file to open
+	if isDirMarker(name) {
+		shards, err := s.hashShards(strings.NewReader(""))
+		if err != nil {
+			return nil, fmt.Errorf("hashing %s: %w", name, err)
+		}
+		return []*intoto.ResourceDescriptor{{Name: name, Digest: shards[0].digests}}, nil
+	}
+
+	f, err := open(name)
+	if err != nil {
+		return nil, fmt.Errorf("opening file: %w", err)
+	}
+	defer f.Close() //nolint:errcheck
+
+	// Files unchanged since they were cached are not hashed again
+	cache := s.fileCache()
+	var (
+		settings string
+		info     fs.FileInfo
+	)
+	if cache != nil {
+		settings = s.cacheSettings(name)
+		var cached []*intoto.ResourceDescriptor
+		if cached, info = cache.lookup(f, name, settings); cached != nil {
+			statsFrom(ctx).addFile(0)
+			return cached, nil
+		}
+	}
+
+	// So are the files unchanged since the previous manifest
+	if reused := s.reusePrevious(ctx, f, name); reused != nil {
+		statsFrom(ctx).addFile(0)
+		return reused, nil
+	}
+
+	var src countingReader = &contextReader{ctx: ctx, r: f}
+	if s.opts.UseMmap {
+		if mr, unmap := mapFileReader(ctx, f); mr != nil {
+			defer unmap() //nolint:errcheck
+			src = mr
+		}
+	}
+
+	// Recorded symlinks are hashed over their target path, as is
+	link, isLink := f.(*linkFile)
+
+	var r io.Reader = src
+	var compression options.Compression
+	if !isLink {
+		compression = s.compressionFor(name)
+	}
+	if compression != "" {
+		dr, err := decompress(compression, src)
+		if err != nil {
+			return nil, fmt.Errorf("decompressing %s as %s: %w", name, compression, err)
+		}
+		defer dr.Close() //nolint:errcheck
+		r = dr
+	}
+
+	shards, err := s.hashShards(r)
+	if err != nil {
+		if compression != "" {
+			return nil, fmt.Errorf("decompressing %s as %s: %w", name, compression, err)
+		}
+		return nil, fmt.Errorf("hashing %s: %w", name, err)
+	}
+
+	// Empty files have no shards in the Python shards method
+	sharded := s.opts.Method == options.ShardsMethod
+	if sharded && len(shards) == 1 && shards[0].end == 0 {
+		shards = nil
+	}
+
+	descriptors := make([]*intoto.ResourceDescriptor, 0, len(shards))
+	for _, sh := range shards {
+		shardName := name
+		if len(shards) > 1 || sharded {
+			shardName = ShardName(name, sh.start, sh.end)
+		}
+		descriptors = append(descriptors, &intoto.ResourceDescriptor{
+			Name:   shardName,
+			Digest: sh.digests,
+		})
+	}
+
+	annotations := map[string]any{}
+	if isLink {
+		annotations[AnnotationLinkTarget] = link.target
+	}
+	if compression != "" {
+		annotations[AnnotationDecompressed] = string(compression)
+	}
+	if s.opts.RecordSizes {
+		annotations[AnnotationSize] = src.bytesRead()
+	}
+	var fi fs.FileInfo
+	if st, ok := f.(interface{ Stat() (fs.FileInfo, error) }); ok && (s.opts.RecordPermissions || s.opts.ResourceAnnotator != nil) {
+		fi, err = st.Stat()
+		if err != nil {
+			return nil, fmt.Errorf("reading info of %s: %w", name, err)
+		}
+	}
+	if s.opts.RecordPermissions {
+		if fi == nil {
+			return nil, fmt.Errorf("cannot read the mode of %s", name)
+		}
+		annotations[AnnotationMode] = unixMode(fi.Mode())
+	}
+	if s.opts.ResourceAnnotator != nil {
+		// The built-in annotations win over the custom ones
+		for key, value := range s.opts.ResourceAnnotator(name, fi) {
+			if _, ok := annotations[key]; !ok {
+				annotations[key] = value
+			}
+		}
+	}
+
+	if len(annotations) > 0 {
+		for _, descriptor := range descriptors {
+			descriptor.Annotations, err = structpb.NewStruct(annotations)
+			if err != nil {
+				return nil, fmt.Errorf("annotating %s: %w", name, err)
+			}
+		}
+	}
+
+	cache.store(f, info, name, settings, descriptors)
+	statsFrom(ctx).addFile(src.bytesRead())
+	return descriptors, nil
+}
+
+// unixMode formats the permission, setuid, setgid and sticky bits of mode
+// as a Unix octal mode string.
+func unixMode(mode fs.FileMode) string {
+	bits := uint32(mode.Perm())
+	if mode&fs.ModeSetuid != 0 {
+		bits |= 0o4000
+	}
+	if mode&fs.ModeSetgid != 0 {
+		bits |= 0o2000
+	}
+	if mode&fs.ModeSticky != 0 {
+		bits |= 0o1000
+	}
+	return fmt.Sprintf("%04o", bits)
+}
+
+// shard is the hash of a byte range of a file.
+type shard struct {
+	start, end int64
+	digests    map[string]string
+}
+
+// ShardName returns the manifest name of the shard of file covering the
+// bytes from start (inclusive) to end (exclusive).
+func ShardName(name string, start, end int64) string {
+	return fmt.Sprintf("%s:%d:%d", name, start, end)
+}
+
+// shardedFile returns the name of the file the descriptor name belongs
+// to: the file name of a shard named by ShardName when files are split
+// in shards, name itself otherwise.
+func (s *Serializer) shardedFile(name string) string {
+	if s.shardSize() <= 0 {
+		return name
+	}
+	rest, end, ok := cutLastField(name)
+	if !ok {
+		return name
+	}
+	file, start, ok := cutLastField(rest)
+	if !ok || start > end {
+		return name
+	}
+	return file
+}
+
+// cutLastField splits name at its last colon, returning what precedes it
+// and the non-negative integer that follows it, if it is one.
+func cutLastField(name string) (string, int64, bool) {
+	i := strings.LastIndexByte(name, ':')
+	if i < 0 {
+		return "", 0, false
+	}
+	n, err := strconv.ParseInt(name[i+1:], 10, 64)
+	if err != nil || n < 0 {
+		return "", 0, false
+	}
+	return name[:i], n, true
+}
+
+// shardSize returns the size of the shards files are split in, or zero
+// if they are hashed whole.
+func (s *Serializer) shardSize() int64 {
+	if s.opts.Method == options.ShardsMethod && s.opts.ShardSize <= 0 {
+		return options.DefaultShardSize
+	}
+	return max(s.opts.ShardSize, 0)
+}
+
+// validateMethod checks the configured serialization method is known.
+func (s *Serializer) validateMethod() error {
+	switch s.opts.Method {
+	case "", options.FilesMethod, options.ShardsMethod:
+		return nil
+	default:
+		return fmt.Errorf("unsupported serialization method %q", s.opts.Method)
+	}
+}
+
+// hashShards hashes r, splitting it in shards of the shard size when one
+// is set. Streams not larger than it, or all of them if it is not set,
+// produce a single shard. Empty streams produce one empty shard too.
+func (s *Serializer) hashShards(r io.Reader) ([]shard, error) {
+	var (
+		shards []shard
+		start  int64
+		size   = s.shardSize()
+	)
+	for {
+		var lr io.Reader = r
+		if size > 0 {
+			lr = io.LimitReader(r, size)
+		}
+
+		digests, n, err := s.hashReader(lr, s.algorithms()...)
+		if err != nil {
+			return nil, err
+		}
+
+		// The stream ended right at a shard boundary
+		if n == 0 && len(shards) > 0 {
+			break
+		}
+
+		shards = append(shards, shard{start: start, end: start + n, digests: digests})
+		start += n
+
+		if size <= 0 || n < size {
+			break
+		}
+	}
+	return shards, nil
+}
+
+// compressionFor returns the compression configured for the file name in
+// DecompressExtensions, or an empty string if it is hashed as is. When
+// several extensions match, the longest one wins.
+func (s *Serializer) compressionFor(name string) options.Compression {
+	var match string
+	for ext := range s.opts.DecompressExtensions {
+		if strings.HasSuffix(name, ext) && len(ext) > len(match) {
+			match = ext
+		}
+	}
+	if match == "" {
+		return ""
+	}
+	return s.opts.DecompressExtensions[match]
+}
+
+// decompress wraps r in a reader decompressing the given format.
+func decompress(compression options.Compression, r io.Reader) (io.ReadCloser, error) {
+	switch compression {
+	case options.CompressionGzip:
+		return gzip.NewReader(r)
+	default:
+		return nil, fmt.Errorf("unsupported compression %q", compression)
+	}
+}
+
+// countingReader is the source a file is hashed from, counting the bytes
+// read from it.
+type countingReader interface {
+	io.Reader
+	bytesRead() int64
+}
+
+// contextReader fails reads once its context is done so hashing large
+// files can be interrupted. It counts the bytes read.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+	n   int64
+}
+
+func (cr *contextReader) Read(p []byte) (int, error) {
+	if err := cr.ctx.Err(); err != nil {
+		return 0, fmt.Errorf("serialization canceled: %w", err)
+	}
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
+
+func (cr *contextReader) bytesRead() int64 { return cr.n }
+
+// copyBuffers holds the buffers hashReader copies files through, so
+// hashing many small files does not allocate a buffer for each one.
+var copyBuffers = sync.Pool{
+	New: func() any {
+		buf := make([]byte, 32*1024)
+		return &buf
+	},
+}
+
+// hashReader reads r until EOF and returns its hex-encoded digests
+// computed with each of the algos, keyed by algorithm name, and the number
+// of bytes read.
+func (s *Serializer) hashReader(r io.Reader, algos ...intoto.HashAlgorithm) (map[string]string, int64, error) {
+	hashers := make([]hash.Hash, 0, len(algos))
+	writers := make([]io.Writer, 0, len(algos))
+	defer func() {
+		for i, h := range hashers {
+			s.releaseHash(algos[i], h)
+		}
+	}()
+	for _, algo := range algos {
+		h := s.newHash(algo)
+		if h == nil {
+			return nil, 0, fmt.Errorf("unsupported hash algorithm %q", algo)
+		}
+		hashers = append(hashers, h)
+		writers = append(writers, h)
+	}
+
+	buf := copyBuffers.Get().(*[]byte)
+	defer copyBuffers.Put(buf)
+
+	n, err := io.CopyBuffer(io.MultiWriter(writers...), r, *buf)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	digests := make(map[string]string, len(algos))
+	for i, algo := range algos {
+		digests[string(algo)] = hex.EncodeToString(hashers[i].Sum(nil))
+	}
+	return digests, n, nil
+}
@@ -0,0 +1,784 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package dir
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/carabiner-dev/model-signing/internal/serializer/options"
+	intoto "github.com/in-toto/attestation/go/v1"
+)
+
+func gzipData(t *testing.T, data []byte, level int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, level)
+	if err != nil {
+		t.Fatalf("Failed to create gzip writer: %v", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatalf("Failed to compress: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Failed to close gzip writer: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecompressExtensions(t *testing.T) {
+	content := bytes.Repeat([]byte("model weights "), 1000)
+	sum := sha256.Sum256(content)
+	expectedHash := hex.EncodeToString(sum[:])
+
+	opts := options.Default()
+	opts.DecompressExtensions = map[string]options.Compression{".gz": options.CompressionGzip}
+
+	var digests []string
+	for _, level := range []int{gzip.BestSpeed, gzip.BestCompression} {
+		tempDir, err := os.MkdirTemp("", "modeldigest-test-*")
+		if err != nil {
+			t.Fatalf("Failed to create temp dir: %v", err)
+		}
+		defer os.RemoveAll(tempDir)
+
+		if err := os.WriteFile(filepath.Join(tempDir, "model.bin.gz"), gzipData(t, content, level), 0644); err != nil {
+			t.Fatalf("Failed to create compressed file: %v", err)
+		}
+
+		manifest, err := New(opts).Serialize(tempDir)
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+
+		file := manifest.Files[0]
+		if file.Name != "model.bin.gz" {
+			t.Errorf("Expected model.bin.gz, got %s", file.Name)
+		}
+		if file.Digest["sha256"] != expectedHash {
+			t.Errorf("Expected hash of decompressed content %s, got %s", expectedHash, file.Digest["sha256"])
+		}
+		if got := file.Annotations.GetFields()[AnnotationDecompressed].GetStringValue(); got != "gzip" {
+			t.Errorf("Expected decompressed annotation gzip, got %q", got)
+		}
+
+		rootDigest, err := ComputeRootDigest(manifest)
+		if err != nil {
+			t.Fatalf("ComputeRootDigest failed: %v", err)
+		}
+		digests = append(digests, rootDigest)
+	}
+
+	if digests[0] != digests[1] {
+		t.Errorf("Digest depends on compression level: %s != %s", digests[0], digests[1])
+	}
+
+	t.Run("Corrupt", func(t *testing.T) {
+		tempDir, err := os.MkdirTemp("", "modeldigest-test-*")
+		if err != nil {
+			t.Fatalf("Failed to create temp dir: %v", err)
+		}
+		defer os.RemoveAll(tempDir)
+
+		compressed := gzipData(t, content, gzip.DefaultCompression)
+		for name, data := range map[string][]byte{
+			"header.gz":    []byte("not gzip at all"),
+			"truncated.gz": compressed[:len(compressed)/2],
+		} {
+			path := filepath.Join(tempDir, name)
+			if err := os.WriteFile(path, data, 0644); err != nil {
+				t.Fatalf("Failed to create %s: %v", name, err)
+			}
+			if _, err := New(opts).Serialize(tempDir); err == nil {
+				t.Errorf("Expected error for corrupt %s", name)
+			}
+			os.Remove(path)
+		}
+	})
+}
+
+func TestConcurrency(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "modeldigest-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// More files than the hasher library opens at once
+	for i := range 1500 {
+		name := filepath.Join(tempDir, fmt.Sprintf("shard-%05d.bin", i))
+		if err := os.WriteFile(name, []byte(name), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	var expected string
+	for _, concurrency := range []int{1, 4, 0, 64} {
+		opts := options.Default()
+		opts.Concurrency = concurrency
+
+		digest, err := ComputeDigest(tempDir, opts)
+		if err != nil {
+			t.Fatalf("ComputeDigest failed with concurrency %d: %v", concurrency, err)
+		}
+		if expected == "" {
+			expected = digest
+		} else if digest != expected {
+			t.Errorf("Digest with concurrency %d differs: %s != %s", concurrency, digest, expected)
+		}
+	}
+
+	t.Run("Error", func(t *testing.T) {
+		errBroken := errors.New("broken file")
+		names := make([]string, 100)
+		for i := range names {
+			names[i] = fmt.Sprintf("file-%d", i)
+		}
+
+		opts := options.Default()
+		opts.Concurrency = 8
+		_, err := New(opts).hashFiles(context.Background(), names, func(name string) (io.ReadCloser, error) {
+			if name == "file-42" {
+				return nil, errBroken
+			}
+			return io.NopCloser(strings.NewReader(name)), nil
+		})
+		if !errors.Is(err, errBroken) {
+			t.Errorf("Expected worker error to be returned, got %v", err)
+		}
+	})
+}
+
+func TestRecordSizes(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "modeldigest-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	compressed := gzipData(t, bytes.Repeat([]byte("vocab"), 100), gzip.BestCompression)
+	testFiles := map[string][]byte{
+		"model.bin": bytes.Repeat([]byte{1}, 4096),
+		"empty":     {},
+		"vocab.gz":  compressed,
+	}
+	for name, content := range testFiles {
+		if err := os.WriteFile(filepath.Join(tempDir, name), content, 0644); err != nil {
+			t.Fatalf("Failed to create test file %s: %v", name, err)
+		}
+	}
+
+	opts := options.Default()
+	opts.RecordSizes = true
+	opts.DecompressExtensions = map[string]options.Compression{".gz": options.CompressionGzip}
+
+	manifest, err := New(opts).Serialize(tempDir)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+
+	for _, file := range manifest.Files {
+		size := file.Annotations.GetFields()[AnnotationSize]
+		if size == nil {
+			t.Errorf("No size recorded for %s", file.Name)
+			continue
+		}
+		if int(size.GetNumberValue()) != len(testFiles[file.Name]) {
+			t.Errorf("Expected size %d for %s, got %v", len(testFiles[file.Name]), file.Name, size.GetNumberValue())
+		}
+	}
+
+	// Sizes are metadata, the root digest does not change
+	expected, err := ComputeDigest(tempDir, &options.Options{DecompressExtensions: opts.DecompressExtensions})
+	if err != nil {
+		t.Fatalf("ComputeDigest failed: %v", err)
+	}
+	digest, err := ComputeDigest(tempDir, opts)
+	if err != nil {
+		t.Fatalf("ComputeDigest failed: %v", err)
+	}
+	if digest != expected {
+		t.Errorf("Recording sizes changed the digest: %s != %s", digest, expected)
+	}
+}
+
+func TestResourceAnnotator(t *testing.T) {
+	tempDir, expected := newTestManifest(t)
+
+	opts := options.Default()
+	opts.RecordSizes = true
+	opts.ResourceAnnotator = func(path string, info os.FileInfo) map[string]any {
+		if info == nil || info.IsDir() {
+			t.Errorf("Expected the file info of %s, got %v", path, info)
+		}
+		annotations := map[string]any{"license": "apache-2.0", AnnotationSize: "overridden"}
+		if strings.HasSuffix(path, ".json") {
+			annotations["contentType"] = "application/json"
+		}
+		return annotations
+	}
+
+	manifest, err := New(opts).Serialize(tempDir)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	for _, file := range manifest.Files {
+		fields := file.GetAnnotations().GetFields()
+		if fields["license"].GetStringValue() != "apache-2.0" {
+			t.Errorf("Expected a license annotation on %s, got %v", file.Name, fields)
+		}
+		if _, ok := fields["contentType"]; ok != (file.Name == "config.json") {
+			t.Errorf("Unexpected contentType annotation on %s: %v", file.Name, fields)
+		}
+		if fields[AnnotationSize].GetNumberValue() == 0 {
+			t.Errorf("Expected the built-in size annotation to win on %s, got %v", file.Name, fields)
+		}
+	}
+
+	// Annotations travel into the statement, not into the digests
+	statement, err := manifest.ToStatement(PredicateType)
+	if err != nil {
+		t.Fatalf("ToStatement failed: %v", err)
+	}
+	if statement.Subject[1].GetAnnotations().GetFields()["license"] == nil {
+		t.Errorf("Expected the annotations in the statement, got %v", statement.Subject[1])
+	}
+	if diff := Compare(expected, manifest); !diff.Empty() {
+		t.Errorf("Annotations changed the digests: %v", diff)
+	}
+}
+
+func TestShardSize(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "modeldigest-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	const shardSize = 1024
+	large := make([]byte, shardSize*2+shardSize/2)
+	for i := range large {
+		large[i] = byte(i % 251)
+	}
+	testFiles := map[string][]byte{
+		"large.bin": large,
+		"exact.bin": large[:shardSize],
+		"small.bin": large[:10],
+		"aligned":   large[:shardSize*2],
+	}
+	for name, content := range testFiles {
+		if err := os.WriteFile(filepath.Join(tempDir, name), content, 0644); err != nil {
+			t.Fatalf("Failed to create test file %s: %v", name, err)
+		}
+	}
+
+	opts := options.Default()
+	opts.ShardSize = shardSize
+
+	manifest, err := New(opts).Serialize(tempDir)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+
+	expected := map[string][]byte{
+		"aligned:0:1024":      large[:1024],
+		"aligned:1024:2048":   large[1024:2048],
+		"exact.bin":           large[:1024],
+		"large.bin:0:1024":    large[:1024],
+		"large.bin:1024:2048": large[1024:2048],
+		"large.bin:2048:2560": large[2048:],
+		"small.bin":           large[:10],
+	}
+	if len(manifest.Files) != len(expected) {
+		for _, f := range manifest.Files {
+			t.Logf("  Found file: %s", f.Name)
+		}
+		t.Fatalf("Expected %d descriptors, got %d", len(expected), len(manifest.Files))
+	}
+
+	rootHasher := sha256.New()
+	for _, file := range manifest.Files {
+		content, ok := expected[file.Name]
+		if !ok {
+			t.Errorf("Unexpected descriptor %s", file.Name)
+			continue
+		}
+		sum := sha256.Sum256(content)
+		if file.Digest["sha256"] != hex.EncodeToString(sum[:]) {
+			t.Errorf("Wrong digest for %s", file.Name)
+		}
+		rootHasher.Write(sum[:])
+	}
+
+	rootDigest, err := ComputeRootDigest(manifest)
+	if err != nil {
+		t.Fatalf("ComputeRootDigest failed: %v", err)
+	}
+	if rootDigest != hex.EncodeToString(rootHasher.Sum(nil)) {
+		t.Errorf("Root digest does not follow the sorted shard concatenation")
+	}
+}
+
+func TestMethod(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "modeldigest-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	testFiles := map[string]string{
+		"model.bin":   "0123456789",
+		"config.json": "{}",
+		"empty.bin":   "",
+	}
+	for name, content := range testFiles {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test file %s: %v", name, err)
+		}
+	}
+
+	names := func(manifest *Manifest) []string {
+		var names []string
+		for _, file := range manifest.Files {
+			names = append(names, file.Name)
+		}
+		return names
+	}
+
+	for _, tc := range []struct {
+		name      string
+		method    options.Method
+		shardSize int64
+		expected  []string
+	}{
+		{"empty", "", 0, []string{"config.json", "empty.bin", "model.bin"}},
+		{"files", options.FilesMethod, 0, []string{"config.json", "empty.bin", "model.bin"}},
+		{"files sharding large files", options.FilesMethod, 4, []string{"config.json", "empty.bin", "model.bin:0:4", "model.bin:4:8", "model.bin:8:10"}},
+		{"shards", options.ShardsMethod, 0, []string{"config.json:0:2", "model.bin:0:10"}},
+		{"shards with size", options.ShardsMethod, 4, []string{"config.json:0:2", "model.bin:0:4", "model.bin:4:8", "model.bin:8:10"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			manifest, err := NewWithOptions(
+				options.WithMethod(tc.method), options.WithShardSize(tc.shardSize),
+			).Serialize(tempDir)
+			if err != nil {
+				t.Fatalf("Serialize failed: %v", err)
+			}
+			if got := names(manifest); !slices.Equal(got, tc.expected) {
+				t.Errorf("Expected descriptors %v, got %v", tc.expected, got)
+			}
+		})
+	}
+
+	// Shards hash their own bytes, single shards included
+	manifest, err := NewWithOptions(options.WithMethod(options.ShardsMethod)).Serialize(tempDir)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	sum := sha256.Sum256([]byte("0123456789"))
+	if got := manifest.Files[1].Digest["sha256"]; got != hex.EncodeToString(sum[:]) {
+		t.Errorf("Wrong digest for %s: %s", manifest.Files[1].Name, got)
+	}
+
+	if _, err := NewWithOptions(options.WithMethod("chunks")).Serialize(tempDir); err == nil {
+		t.Error("Expected an unknown method to fail")
+	}
+}
+
+func TestHashReader(t *testing.T) {
+	_, manifest := newTestManifest(t)
+
+	descriptor, err := HashReader("model.bin", strings.NewReader("weights"), nil)
+	if err != nil {
+		t.Fatalf("HashReader failed: %v", err)
+	}
+	if descriptor.Name != "model.bin" {
+		t.Errorf("Expected model.bin, got %s", descriptor.Name)
+	}
+	if descriptor.Digest["sha256"] != manifest.Files[1].Digest["sha256"] {
+		t.Errorf("HashReader digest %s does not match Serialize %s", descriptor.Digest["sha256"], manifest.Files[1].Digest["sha256"])
+	}
+
+	opts := options.Default()
+	opts.HashAlgorithm = intoto.AlgorithmSHA512
+	opts.ShardSize = 2
+	descriptor, err = HashReader("model.bin", strings.NewReader("weights"), opts)
+	if err != nil {
+		t.Fatalf("HashReader failed: %v", err)
+	}
+	if len(descriptor.Digest["sha512"]) != 128 || descriptor.Name != "model.bin" {
+		t.Errorf("Expected a single sha512 descriptor, got %v", descriptor)
+	}
+
+	opts = options.Default()
+	opts.HashAlgorithm = "mickey"
+	if _, err := HashReader("model.bin", strings.NewReader("weights"), opts); err == nil {
+		t.Error("Expected error for unsupported algorithm")
+	}
+}
+
+func TestEmptyFiles(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "modeldigest-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	for _, name := range []string{".keep", "model.bin", "sub/.keep"} {
+		path := filepath.Join(tempDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create dir for %s: %v", name, err)
+		}
+		content := ""
+		if name == "model.bin" {
+			content = "weights"
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test file %s: %v", name, err)
+		}
+	}
+
+	empty := sha256.Sum256(nil)
+	weights := sha256.Sum256([]byte("weights"))
+	expected := sha256.Sum256(append(append(append([]byte{}, empty[:]...), weights[:]...), empty[:]...))
+
+	for _, tc := range []struct {
+		name string
+		opts func(*options.Options)
+	}{
+		{"Default", func(*options.Options) {}},
+		{"ShardSize", func(o *options.Options) { o.ShardSize = 4 }},
+		{"RecordSizes", func(o *options.Options) { o.RecordSizes = true }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := options.Default()
+			tc.opts(opts)
+
+			manifest, err := New(opts).Serialize(tempDir)
+			if err != nil {
+				t.Fatalf("Serialize failed: %v", err)
+			}
+
+			var found int
+			for _, f := range manifest.Files {
+				if !strings.HasSuffix(f.Name, ".keep") {
+					continue
+				}
+				found++
+				if f.Digest["sha256"] != hex.EncodeToString(empty[:]) {
+					t.Errorf("Empty file %s has digest %s", f.Name, f.Digest["sha256"])
+				}
+				if opts.RecordSizes && f.GetAnnotations().AsMap()[AnnotationSize] != float64(0) {
+					t.Errorf("Empty file %s has size %v", f.Name, f.GetAnnotations().AsMap()[AnnotationSize])
+				}
+			}
+			if found != 2 {
+				t.Errorf("Expected 2 empty files, got %d", found)
+			}
+
+			if opts.ShardSize == 0 {
+				root, err := ComputeRootDigest(manifest)
+				if err != nil {
+					t.Fatalf("ComputeRootDigest failed: %v", err)
+				}
+				if root != hex.EncodeToString(expected[:]) {
+					t.Errorf("Root digest %s does not include the empty files", root)
+				}
+			}
+		})
+	}
+}
+
+func TestHashWalk(t *testing.T) {
+	open := func(name string) (io.ReadCloser, error) {
+		if name == "broken" {
+			return nil, errors.New("broken file")
+		}
+		return io.NopCloser(strings.NewReader(name)), nil
+	}
+	s := New(&options.Options{Concurrency: 2})
+
+	descriptors, walkErr, hashErr := s.hashWalk(context.Background(), func(yield func(string) error) error {
+		for _, name := range []string{"c", "a", "b"} {
+			if err := yield(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, open)
+	if walkErr != nil || hashErr != nil {
+		t.Fatalf("hashWalk failed: %v, %v", walkErr, hashErr)
+	}
+	for i, name := range []string{"c", "a", "b"} {
+		sum := sha256.Sum256([]byte(name))
+		if descriptors[i].Name != name || descriptors[i].Digest["sha256"] != hex.EncodeToString(sum[:]) {
+			t.Errorf("Descriptor %d: unexpected %v", i, descriptors[i])
+		}
+	}
+
+	// A hashing error stops the walk
+	yielded := 0
+	_, walkErr, hashErr = s.hashWalk(context.Background(), func(yield func(string) error) error {
+		for i := range 1000 {
+			name := fmt.Sprintf("file%d", i)
+			if i == 0 {
+				name = "broken"
+			}
+			if err := yield(name); err != nil {
+				return err
+			}
+			yielded++
+		}
+		return nil
+	}, open)
+	if walkErr != nil || hashErr == nil {
+		t.Errorf("Expected only a hashing error, got %v, %v", walkErr, hashErr)
+	}
+	if yielded == 1000 {
+		t.Error("Hashing error did not stop the walk")
+	}
+
+	// A walk error is reported as such
+	_, walkErr, hashErr = s.hashWalk(context.Background(), func(yield func(string) error) error {
+		if err := yield("a"); err != nil {
+			return err
+		}
+		return errors.New("walk failed")
+	}, open)
+	if walkErr == nil || hashErr != nil {
+		t.Errorf("Expected only a walk error, got %v, %v", walkErr, hashErr)
+	}
+}
+
+func TestUseMmap(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "modeldigest-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// A sparse file above the mapping threshold, with data at both ends.
+	f, err := os.Create(filepath.Join(tempDir, "model.bin"))
+	if err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	if _, err := f.WriteString("header"); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if _, err := f.WriteAt([]byte("trailer"), mmapThreshold+1000); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("Failed to close file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tempDir, "config.json"), []byte("{}"), 0o644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	for _, shardSize := range []int64{0, mmapThreshold / 3} {
+		opts := options.Default()
+		opts.RecordSizes = true
+		opts.ShardSize = shardSize
+		streamed, err := New(opts).Serialize(tempDir)
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+
+		opts.UseMmap = true
+		mapped, err := New(opts).Serialize(tempDir)
+		if err != nil {
+			t.Fatalf("Serialize with UseMmap failed: %v", err)
+		}
+
+		if diff := Compare(streamed, mapped); !diff.Empty() {
+			t.Errorf("Shard size %d: mapped manifest differs from streamed: %+v", shardSize, diff)
+		}
+		for i := range streamed.Files {
+			want := streamed.Files[i].GetAnnotations().GetFields()[AnnotationSize].GetNumberValue()
+			got := mapped.Files[i].GetAnnotations().GetFields()[AnnotationSize].GetNumberValue()
+			if got != want {
+				t.Errorf("%s: expected size %v, got %v", streamed.Files[i].Name, want, got)
+			}
+		}
+	}
+}
+
+func TestMappedReaderCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	mr := &mappedReader{ctx: ctx, data: make([]byte, 2*mmapChunk)}
+	if _, err := io.Copy(io.Discard, mr); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if _, err := mr.Read(make([]byte, 8)); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestConcurrentSerialize(t *testing.T) {
+	tempDir, manifest := newTestManifest(t)
+	expected, err := ComputeRootDigest(manifest)
+	if err != nil {
+		t.Fatalf("ComputeRootDigest failed: %v", err)
+	}
+
+	opts := options.Default()
+	opts.Algorithms = []intoto.HashAlgorithm{options.AlgorithmBLAKE3}
+	s := New(opts)
+
+	errs := make(chan error, 16)
+	for range cap(errs) {
+		go func() {
+			m, err := s.Serialize(tempDir)
+			if err != nil {
+				errs <- err
+				return
+			}
+			digest, err := ComputeRootDigest(m)
+			if err == nil && digest != expected {
+				err = fmt.Errorf("expected root digest %s, got %s", expected, digest)
+			}
+			errs <- err
+		}()
+	}
+	for range cap(errs) {
+		if err := <-errs; err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+// mockHasher delegates to the default hasher, counting the hashes made,
+// and adds a "crc32" algorithm.
+type mockHasher struct {
+	calls atomic.Int32
+}
+
+func (m *mockHasher) NewHash(algo intoto.HashAlgorithm) hash.Hash {
+	m.calls.Add(1)
+	if algo == "crc32" {
+		return crc32.NewIEEE()
+	}
+	return DefaultHasher().NewHash(algo)
+}
+
+func TestLargestFirst(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "modeldigest-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	sizes := map[string]int{"a.bin": 10, "b.bin": 300, "c.bin": 20, "d.bin": 300, "e.bin": 1000}
+	var names []string
+	for name, size := range sizes {
+		if err := os.WriteFile(filepath.Join(tempDir, name), bytes.Repeat([]byte("x"), size), 0644); err != nil {
+			t.Fatalf("Failed to create test file %s: %v", name, err)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	open := func(name string) (io.ReadCloser, error) {
+		return os.Open(filepath.Join(tempDir, name))
+	}
+
+	opts := options.Default()
+	opts.Concurrency = 4
+	opts.LargestFirst = true
+	s := New(opts)
+
+	// Files are scheduled by decreasing size, ties in the walk order
+	found, order, err := s.collectWalk(context.Background(), func(yield func(string) error) error {
+		for _, name := range names {
+			if err := yield(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, open, nil)
+	if err != nil {
+		t.Fatalf("collectWalk failed: %v", err)
+	}
+	var scheduled []string
+	for _, i := range order {
+		scheduled = append(scheduled, found[i])
+	}
+	if got := strings.Join(scheduled, ","); got != "e.bin,b.bin,d.bin,c.bin,a.bin" {
+		t.Errorf("Unexpected schedule %s", got)
+	}
+
+	// The descriptors keep the order of the names
+	descriptors, err := s.hashFiles(context.Background(), names, open)
+	if err != nil {
+		t.Fatalf("hashFiles failed: %v", err)
+	}
+	for i, descriptor := range descriptors {
+		if descriptor.Name != names[i] {
+			t.Errorf("Descriptor %d: expected %s, got %s", i, names[i], descriptor.Name)
+		}
+	}
+
+	expected, err := New(options.Default()).Serialize(tempDir)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	manifest, err := s.Serialize(tempDir)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	if diff := Compare(expected, manifest); !diff.Empty() {
+		t.Errorf("LargestFirst changed the manifest: %v", diff)
+	}
+
+	// A single worker hashes the files as found
+	opts.Concurrency = 1
+	if s.largestFirst() {
+		t.Error("Expected LargestFirst to be off with a single worker")
+	}
+}
+
+func TestCustomHasher(t *testing.T) {
+	tempDir, expected := newTestManifest(t)
+
+	mock := &mockHasher{}
+	opts := options.Default()
+	opts.Hasher = mock
+	opts.Algorithms = []intoto.HashAlgorithm{"crc32"}
+	manifest, err := New(opts).Serialize(tempDir)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	if mock.calls.Load() == 0 {
+		t.Error("Expected the custom hasher to be used")
+	}
+	if diff := Compare(expected, manifest); !diff.Empty() {
+		t.Errorf("Custom hasher manifest differs: %+v", diff)
+	}
+	sum := crc32.ChecksumIEEE([]byte("weights"))
+	if got := manifest.Files[1].Digest["crc32"]; got != fmt.Sprintf("%08x", sum) {
+		t.Errorf("Expected crc32 %08x, got %s", sum, got)
+	}
+
+	// The default hasher does not know the custom algorithm
+	opts.Hasher = nil
+	if _, err := New(opts).Serialize(tempDir); err == nil {
+		t.Error("Expected error for an algorithm only the custom hasher supports")
+	}
+}
@@ -0,0 +1,65 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package dir
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Hugging Face caches store every repository in a directory named after
+// its type and id ("models--org--name") holding the file contents in
+// blobs/ and one snapshots/<revision>/ directory per revision, whose files
+// are symlinks to the blobs.
+const (
+	snapshotsDir = "snapshots"
+	blobsDir     = "blobs"
+)
+
+// snapshotModelName returns the repository id ("org/name") of the Hugging
+// Face snapshot directory realRoot. It fails if realRoot is not a
+// snapshot of a cached repository.
+func snapshotModelName(realRoot string) (string, error) {
+	snapshots := filepath.Dir(realRoot)
+	repo := filepath.Dir(snapshots)
+	if filepath.Base(snapshots) != snapshotsDir {
+		return "", fmt.Errorf("%s is not a Hugging Face snapshot directory (expected <repo>/%s/<revision>)", realRoot, snapshotsDir)
+	}
+	if _, err := os.Stat(filepath.Join(repo, blobsDir)); err != nil {
+		return "", fmt.Errorf("%s is not a Hugging Face snapshot directory: %w", realRoot, err)
+	}
+
+	// models--org--name (or datasets--, spaces--) names org/name
+	parts := strings.Split(filepath.Base(repo), "--")
+	if len(parts) < 2 {
+		return filepath.Base(repo), nil
+	}
+	return strings.Join(parts[1:], "/"), nil
+}
+
+// snapshotBlob returns the file info of the blob the symlink at path of
+// the snapshot realRoot points to, or nil if it does not point to a
+// regular file in the blobs directory of the repository.
+func snapshotBlob(path, realRoot string) (fs.FileInfo, error) {
+	target, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return nil, fmt.Errorf("resolving symlink %s: %w", path, err)
+	}
+	blobs := filepath.Join(filepath.Dir(filepath.Dir(realRoot)), blobsDir)
+	if filepath.Dir(target) != blobs {
+		return nil, nil
+	}
+
+	info, err := os.Stat(target)
+	if err != nil {
+		return nil, fmt.Errorf("reading blob of %s: %w", path, err)
+	}
+	if !info.Mode().IsRegular() {
+		return nil, nil
+	}
+	return info, nil
+}
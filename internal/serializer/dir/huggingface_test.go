@@ -0,0 +1,89 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package dir
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/carabiner-dev/model-signing/internal/serializer/options"
+)
+
+func TestHuggingFaceSnapshot(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "modeldigest-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// A cache laid out as huggingface_hub does, and the same files as a
+	// plain model directory
+	repo := filepath.Join(tempDir, "models--org--name")
+	snapshot := filepath.Join(repo, "snapshots", "0123abcd")
+	plain := filepath.Join(tempDir, "plain")
+	for name, content := range map[string]string{
+		"config.json":               "{}",
+		"weights/model.safetensors": "weights",
+	} {
+		sum := sha256.Sum256([]byte(content))
+		blob := filepath.Join(repo, "blobs", hex.EncodeToString(sum[:]))
+		for _, path := range []string{blob, filepath.Join(plain, name)} {
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatalf("Failed to create dir for %s: %v", path, err)
+			}
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatalf("Failed to create test file %s: %v", path, err)
+			}
+		}
+
+		link := filepath.Join(snapshot, name)
+		if err := os.MkdirAll(filepath.Dir(link), 0755); err != nil {
+			t.Fatalf("Failed to create dir for %s: %v", link, err)
+		}
+		target, err := filepath.Rel(filepath.Dir(link), blob)
+		if err != nil {
+			t.Fatalf("Failed to compute link target: %v", err)
+		}
+		if err := os.Symlink(target, link); err != nil {
+			t.Skipf("Symlinks not supported: %v", err)
+		}
+	}
+
+	opts := options.Default()
+	if _, err := New(opts).Serialize(snapshot); err == nil {
+		t.Error("Expected the snapshot links to be rejected without HuggingFaceSnapshot")
+	}
+
+	opts.HuggingFaceSnapshot = true
+	manifest, err := New(opts).Serialize(snapshot)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	if manifest.ModelName != "org/name" {
+		t.Errorf("Expected model name org/name, got %s", manifest.ModelName)
+	}
+
+	expected, err := New(options.Default()).Serialize(plain)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	if len(manifest.Files) != len(expected.Files) {
+		t.Fatalf("Expected %d files, got %d", len(expected.Files), len(manifest.Files))
+	}
+	if diff := Compare(expected, manifest); !diff.Empty() {
+		t.Errorf("Snapshot manifest differs from the plain model: %+v", diff)
+	}
+
+	// Only snapshot directories are accepted
+	if _, err := New(opts).Serialize(plain); err == nil {
+		t.Error("Expected error for a directory that is not a snapshot")
+	}
+	opts.ConfineToRoot = true
+	if _, err := New(opts).Serialize(snapshot); err == nil {
+		t.Error("Expected error combining HuggingFaceSnapshot and ConfineToRoot")
+	}
+}
@@ -0,0 +1,192 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package dir
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"time"
+
+	intoto "github.com/in-toto/attestation/go/v1"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/carabiner-dev/model-signing/internal/serializer/options"
+)
+
+// previousKey is the context key of the previous manifest of an
+// incremental serialization.
+type previousKey struct{}
+
+// previousManifest is the manifest an incremental serialization copies
+// the digests of unchanged files from.
+type previousManifest struct {
+	manifest *Manifest
+
+	// since is when the previous serialization started. Files modified
+	// before it are trusted to be unchanged.
+	since time.Time
+}
+
+// previousFrom returns the previous manifest of ctx, nil if it has none.
+func previousFrom(ctx context.Context) *previousManifest {
+	p, _ := ctx.Value(previousKey{}).(*previousManifest)
+	return p
+}
+
+// ErrPreviousManifest is returned by SerializeIncremental for previous
+// manifests it cannot copy digests from: those of another model, and
+// those produced without RecordSizes.
+var ErrPreviousManifest = errors.New("unusable previous manifest")
+
+// SerializeIncremental serializes the model at modelPath like Serialize,
+// but copies the digests of the files unchanged since prev was produced
+// instead of hashing them again. A file is taken as unchanged when its
+// size is the one recorded in prev and it was last modified before the
+// serialization of prev started.
+//
+// This trades the guarantee of Serialize, that every byte signed was
+// read, for speed: it trusts the file system modification times. A file
+// rewritten with the same size and its modification time set back, by a
+// tool preserving times or by someone hiding a change, keeps its old
+// digest. Use it for local rebuilds of large models, and Serialize when
+// producing a manifest to sign from untrusted storage.
+//
+// prev must come from a Serialize call of this model, at the same path,
+// with RecordSizes set, as its ModelPath, sizes and SerializedAt are
+// needed; other manifests fail with ErrPreviousManifest. Manifests
+// decoded from JSON have no SerializedAt and every file is hashed. So is
+// every file when a custom Hasher or a ResourceAnnotator is set, and the
+// files that are symlinks, are decompressed, or whose digests in prev
+// lack one of the configured algorithms. RecordSizes and
+// RecordPermissions annotations are recomputed from the files on disk.
+func (s *Serializer) SerializeIncremental(modelPath string, prev *Manifest) (*Manifest, error) {
+	ctx := context.Background()
+	if prev != nil && !prev.SerializedAt.IsZero() {
+		if err := checkPrevious(modelPath, prev); err != nil {
+			return nil, err
+		}
+		if s.opts.Hasher == nil && s.opts.ResourceAnnotator == nil {
+			ctx = context.WithValue(ctx, previousKey{}, &previousManifest{
+				manifest: prev,
+				since:    prev.SerializedAt.Add(-prev.Stats.Duration),
+			})
+		}
+	}
+	return s.SerializeContext(ctx, modelPath)
+}
+
+// checkPrevious returns an error if prev is not a manifest of the model
+// at modelPath recording the sizes of its files.
+func checkPrevious(modelPath string, prev *Manifest) error {
+	absPath, err := filepath.Abs(modelPath)
+	if err != nil {
+		return fmt.Errorf("failed to resolve model path: %w", err)
+	}
+	if prev.ModelPath != absPath {
+		return fmt.Errorf("%w: produced for %q, not %s", ErrPreviousManifest, prev.ModelPath, absPath)
+	}
+	for _, file := range prev.Files {
+		if _, ok := file.GetAnnotations().GetFields()[AnnotationSize]; !ok {
+			return fmt.Errorf("%w: %s has no recorded size (use RecordSizes option)", ErrPreviousManifest, file.GetName())
+		}
+	}
+	return nil
+}
+
+// reusePrevious returns the descriptors of the model file name, opened as
+// f, copied from the previous manifest of ctx when the file is unchanged
+// since it was produced. It returns nil when the file must be hashed.
+func (s *Serializer) reusePrevious(ctx context.Context, f io.Reader, name string) []*intoto.ResourceDescriptor {
+	prev := previousFrom(ctx)
+	if prev == nil || s.compressionFor(name) != "" {
+		return nil
+	}
+	if _, isLink := f.(*linkFile); isLink {
+		return nil
+	}
+	st, ok := f.(interface{ Stat() (fs.FileInfo, error) })
+	if !ok {
+		return nil
+	}
+	info, err := st.Stat()
+	if err != nil || !info.Mode().IsRegular() || !info.ModTime().Before(prev.since) {
+		return nil
+	}
+
+	names := s.descriptorNames(name, info.Size())
+	if len(names) == 0 {
+		return nil
+	}
+	descriptors := make([]*intoto.ResourceDescriptor, 0, len(names))
+	for _, descriptorName := range names {
+		recorded, ok := prev.manifest.GetFile(descriptorName)
+		if !ok {
+			return nil
+		}
+		size, ok := recorded.GetAnnotations().GetFields()[AnnotationSize]
+		if !ok || int64(size.GetNumberValue()) != info.Size() {
+			return nil
+		}
+
+		// Fresh descriptors are hex-encoded until the manifest is finished
+		digests := make(map[string]string, len(s.algorithms()))
+		for _, algo := range s.algorithms() {
+			digest, ok := recorded.GetDigest()[string(algo)]
+			if !ok {
+				return nil
+			}
+			digests[string(algo)], err = transcodeDigest(prev.manifest.digestEncoding(), options.DigestEncodingHex, digest)
+			if err != nil {
+				return nil
+			}
+		}
+		descriptors = append(descriptors, &intoto.ResourceDescriptor{Name: descriptorName, Digest: digests})
+	}
+
+	annotations := map[string]any{}
+	if s.opts.RecordSizes {
+		annotations[AnnotationSize] = info.Size()
+	}
+	if s.opts.RecordPermissions {
+		annotations[AnnotationMode] = unixMode(info.Mode())
+	}
+	if len(annotations) > 0 {
+		for _, descriptor := range descriptors {
+			descriptor.Annotations, err = structpb.NewStruct(annotations)
+			if err != nil {
+				return nil
+			}
+		}
+	}
+	return descriptors
+}
+
+// descriptorNames returns the names of the descriptors hashFile records
+// for the model file name of size bytes: the file name, or its shard
+// names when it is split.
+func (s *Serializer) descriptorNames(name string, size int64) []string {
+	shardSize := s.shardSize()
+	sharded := s.opts.Method == options.ShardsMethod
+	if shardSize <= 0 || size <= shardSize {
+		switch {
+		case !sharded:
+			return []string{name}
+		case size == 0:
+			// Empty files have no shards in the Python shards method
+			return nil
+		default:
+			return []string{ShardName(name, 0, size)}
+		}
+	}
+
+	names := make([]string, 0, (size+shardSize-1)/shardSize)
+	for start := int64(0); start < size; start += shardSize {
+		names = append(names, ShardName(name, start, min(start+shardSize, size)))
+	}
+	return names
+}
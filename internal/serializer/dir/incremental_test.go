@@ -0,0 +1,178 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package dir
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/carabiner-dev/model-signing/internal/serializer/options"
+)
+
+func TestSerializeIncremental(t *testing.T) {
+	// model.bin holds 7 bytes, layer.bin 5 and config.json 2
+	tempDir, _ := newTestManifest(t)
+	past := time.Now().Add(-time.Hour)
+	for _, name := range []string{"model.bin", "config.json", "subdir/layer.bin"} {
+		if err := os.Chtimes(filepath.Join(tempDir, filepath.FromSlash(name)), past, past); err != nil {
+			t.Fatalf("Failed to set times of %s: %v", name, err)
+		}
+	}
+	write := func(t *testing.T, name, content string, mtime time.Time) {
+		t.Helper()
+		path := filepath.Join(tempDir, filepath.FromSlash(name))
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatalf("Failed to set times of %s: %v", name, err)
+		}
+	}
+
+	for _, tc := range []struct {
+		name string
+		opts []options.Option
+	}{
+		{"files", nil},
+		{"shards", []options.Option{options.WithShardSize(3)}},
+		{"shards method", []options.Option{options.WithMethod(options.ShardsMethod), options.WithShardSize(4)}},
+		{"base64 and permissions", []options.Option{options.WithDigestEncoding(options.DigestEncodingBase64), options.WithRecordPermissions(true)}},
+		{"extra algorithm", []options.Option{options.WithAlgorithms("sha512")}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := NewWithOptions(append([]options.Option{options.WithRecordSizes(true)}, tc.opts...)...)
+			write(t, "model.bin", "weights", past)
+			prev, err := s.Serialize(tempDir)
+			if err != nil {
+				t.Fatalf("Serialize failed: %v", err)
+			}
+
+			// Nothing changed, nothing is hashed
+			manifest, err := s.SerializeIncremental(tempDir, prev)
+			if err != nil {
+				t.Fatalf("SerializeIncremental failed: %v", err)
+			}
+			if manifest.Stats.BytesHashed != 0 || manifest.Stats.FileCount != 3 {
+				t.Errorf("Expected 3 files and no bytes hashed, got %+v", manifest.Stats)
+			}
+			if diff := Compare(prev, manifest); !diff.Empty() {
+				t.Errorf("Unexpected differences: %+v", diff)
+			}
+
+			// Only the modified file is hashed
+			write(t, "model.bin", "new weights", time.Now())
+			manifest, err = s.SerializeIncremental(tempDir, prev)
+			if err != nil {
+				t.Fatalf("SerializeIncremental failed: %v", err)
+			}
+			if manifest.Stats.BytesHashed != 11 {
+				t.Errorf("Expected 11 bytes hashed, got %d", manifest.Stats.BytesHashed)
+			}
+			full, err := s.Serialize(tempDir)
+			if err != nil {
+				t.Fatalf("Serialize failed: %v", err)
+			}
+			if diff := Compare(full, manifest); !diff.Empty() {
+				t.Errorf("Unexpected differences with a full serialization: %+v", diff)
+			}
+		})
+	}
+
+	s := NewWithOptions(options.WithRecordSizes(true))
+	write(t, "model.bin", "weights", past)
+	prev, err := s.Serialize(tempDir)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+
+	t.Run("same size, newer", func(t *testing.T) {
+		write(t, "model.bin", "WEIGHTS", time.Now())
+		manifest, err := s.SerializeIncremental(tempDir, prev)
+		if err != nil {
+			t.Fatalf("SerializeIncremental failed: %v", err)
+		}
+		if manifest.Stats.BytesHashed != 7 {
+			t.Errorf("Expected 7 bytes hashed, got %d", manifest.Stats.BytesHashed)
+		}
+		if diff := Compare(prev, manifest); len(diff.Modified) != 1 {
+			t.Errorf("Expected model.bin modified, got %+v", diff)
+		}
+	})
+
+	t.Run("mtime trusted", func(t *testing.T) {
+		// A change hidden by setting the time back goes unnoticed
+		write(t, "model.bin", "WEIGHTS", past)
+		manifest, err := s.SerializeIncremental(tempDir, prev)
+		if err != nil {
+			t.Fatalf("SerializeIncremental failed: %v", err)
+		}
+		if diff := Compare(prev, manifest); !diff.Empty() {
+			t.Errorf("Expected the previous digests, got %+v", diff)
+		}
+		write(t, "model.bin", "weights", past)
+	})
+
+	t.Run("new file", func(t *testing.T) {
+		write(t, "extra.bin", "extra", past)
+		defer os.Remove(filepath.Join(tempDir, "extra.bin")) //nolint:errcheck
+		manifest, err := s.SerializeIncremental(tempDir, prev)
+		if err != nil {
+			t.Fatalf("SerializeIncremental failed: %v", err)
+		}
+		if manifest.Stats.BytesHashed != 5 || len(manifest.Files) != 4 {
+			t.Errorf("Expected extra.bin hashed, got %+v with %d files", manifest.Stats, len(manifest.Files))
+		}
+	})
+
+	t.Run("unusable previous manifest", func(t *testing.T) {
+		decoded := *prev
+		decoded.SerializedAt = time.Time{}
+		for _, prev := range []*Manifest{nil, &decoded} {
+			manifest, err := s.SerializeIncremental(tempDir, prev)
+			if err != nil {
+				t.Fatalf("SerializeIncremental failed: %v", err)
+			}
+			if manifest.Stats.BytesHashed != 14 {
+				t.Errorf("Expected every file hashed, got %d bytes", manifest.Stats.BytesHashed)
+			}
+		}
+	})
+
+	t.Run("mismatched previous manifest", func(t *testing.T) {
+		noSizes, err := New(options.Default()).Serialize(tempDir)
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+		otherDir, _ := newTestManifest(t)
+		other, err := s.Serialize(otherDir)
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+		fromFS, err := s.SerializeFS(os.DirFS(tempDir), ".")
+		if err != nil {
+			t.Fatalf("SerializeFS failed: %v", err)
+		}
+		for name, prev := range map[string]*Manifest{"no sizes": noSizes, "other model": other, "file system": fromFS} {
+			if _, err := s.SerializeIncremental(tempDir, prev); !errors.Is(err, ErrPreviousManifest) {
+				t.Errorf("%s: expected ErrPreviousManifest, got %v", name, err)
+			}
+		}
+
+		// Relative paths name the same model
+		wd, err := os.Getwd()
+		if err != nil {
+			t.Fatalf("Getwd failed: %v", err)
+		}
+		rel, err := filepath.Rel(wd, tempDir)
+		if err != nil {
+			t.Skipf("Temp dir not reachable from the working directory: %v", err)
+		}
+		if _, err := s.SerializeIncremental(rel, prev); err != nil {
+			t.Errorf("SerializeIncremental failed: %v", err)
+		}
+	})
+}
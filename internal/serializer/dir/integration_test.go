@@ -13,6 +13,7 @@ import (
 	"testing"
 
 	"github.com/carabiner-dev/model-signing/internal/serializer/options"
+	intoto "github.com/in-toto/attestation/go/v1"
 )
 
 // TestIntegration_ComplexDirectory tests serialization of a complex directory
@@ -178,21 +179,31 @@ func TestIntegration_SingleFile(t *testing.T) {
 	}
 	tempFile.Close()
 
-	// Note: Serializer expects a directory, but we test it with a file parent
-	// to ensure proper handling
-	tempDir := filepath.Dir(tempFile.Name())
 	fileName := filepath.Base(tempFile.Name())
 
 	opts := options.Default()
 	opts.IgnoreGitPaths = false // Don't filter anything
 	serializer := New(opts)
 
+	// A file path is serialized as a single-file model
 	manifest, err := serializer.Serialize(tempFile.Name())
-	if err == nil {
-		t.Logf("Serializing a single file path produced manifest with %d files", len(manifest.Files))
+	if err != nil {
+		t.Fatalf("Serialize of a file failed: %v", err)
+	}
+
+	if manifest.ModelName != fileName {
+		t.Errorf("Expected model name %s, got %s", fileName, manifest.ModelName)
+	}
+
+	if len(manifest.Files) != 1 || manifest.Files[0].Name != fileName {
+		t.Fatalf("Expected a single %s descriptor, got %v", fileName, manifest.Files)
+	}
+
+	if err := serializer.Verify(tempFile.Name(), manifest); err != nil {
+		t.Errorf("Verify of a file failed: %v", err)
 	}
 
-	// Better approach: create a dir with just one file
+	// The same file in a directory has the same digest
 	tempDir2, err := os.MkdirTemp("", "single-file-*")
 	if err != nil {
 		t.Fatalf("Failed to create temp dir: %v", err)
@@ -200,23 +211,86 @@ func TestIntegration_SingleFile(t *testing.T) {
 	defer os.RemoveAll(tempDir2)
 
 	testFile := filepath.Join(tempDir2, "model.bin")
-	if err := os.WriteFile(testFile, []byte("single model"), 0644); err != nil {
+	if err := os.WriteFile(testFile, []byte("model data"), 0644); err != nil {
 		t.Fatalf("Failed to write test file: %v", err)
 	}
 
-	manifest, err = serializer.Serialize(tempDir2)
+	dirManifest, err := serializer.Serialize(tempDir2)
 	if err != nil {
 		t.Fatalf("Serialize failed: %v", err)
 	}
 
-	if len(manifest.Files) != 1 {
-		t.Errorf("Expected 1 file, got %d", len(manifest.Files))
+	if len(dirManifest.Files) != 1 {
+		t.Errorf("Expected 1 file, got %d", len(dirManifest.Files))
+	}
+
+	if dirManifest.Files[0].Name != "model.bin" {
+		t.Errorf("Expected model.bin, got %s", dirManifest.Files[0].Name)
+	}
+
+	fileDigest, err := ComputeRootDigest(manifest)
+	if err != nil {
+		t.Fatalf("ComputeRootDigest failed: %v", err)
+	}
+	dirDigest, err := ComputeRootDigest(dirManifest)
+	if err != nil {
+		t.Fatalf("ComputeRootDigest failed: %v", err)
+	}
+	if fileDigest != dirDigest {
+		t.Errorf("Single-file digest %s does not match directory digest %s", fileDigest, dirDigest)
 	}
+}
 
-	if manifest.Files[0].Name != "model.bin" {
-		t.Errorf("Expected model.bin, got %s", manifest.Files[0].Name)
+// TestIntegration_GoldenDigests checks the root digests of the golden
+// fixture, recomputed with coreutils (see testdata/golden/README.md), so
+// ordering or concatenation changes are caught.
+func TestIntegration_GoldenDigests(t *testing.T) {
+	modelPath := filepath.Join("testdata", "golden", "model")
+
+	for algo, expected := range map[string]string{
+		"sha256": "sha256:6db19b6ade6c38e51de492d5bab5a36857fe1409c9e22c37797074671a3d23f1",
+		"sha512": "sha512:3dbd544cf7c286c859abbc6c48d92db86742fb2499a7bcc049dfa7fbbdebfa87279ec63934e81f1c4087e6a7a8a8f540904ef0faaf33c2022192dc0332918f0b",
+	} {
+		for _, confine := range []bool{false, true} {
+			opts := options.Default()
+			opts.HashAlgorithm = intoto.HashAlgorithm(algo)
+			opts.ConfineToRoot = confine
+
+			digest, err := ComputeDigest(modelPath, opts)
+			if err != nil {
+				t.Fatalf("ComputeDigest failed: %v", err)
+			}
+			if digest != expected {
+				t.Errorf("%s (confined: %v): expected %s, got %s", algo, confine, expected, digest)
+			}
+		}
 	}
 
-	_ = tempDir
-	_ = fileName
+	manifest, err := New(options.Default()).Serialize(modelPath)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	expectedNames := []string{
+		"README.md",
+		"config.json",
+		"empty.bin",
+		"tokenizer/merges.txt",
+		"tokenizer/vocab.txt",
+		"weights/model-00001-of-00002.safetensors",
+		"weights/model-00002-of-00002.safetensors",
+	}
+	if len(manifest.Files) != len(expectedNames) {
+		t.Fatalf("Expected %d files, got %d", len(expectedNames), len(manifest.Files))
+	}
+	for i, file := range manifest.Files {
+		if file.Name != expectedNames[i] {
+			t.Errorf("File %d: expected %s, got %s", i, expectedNames[i], file.Name)
+		}
+	}
+	if manifest.ModelName != "model" {
+		t.Errorf("Expected model name model, got %s", manifest.ModelName)
+	}
+	if digest := manifest.Files[2].Digest["sha256"]; digest != "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855" {
+		t.Errorf("Expected the digest of empty contents for empty.bin, got %s", digest)
+	}
 }
@@ -0,0 +1,159 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package dir
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/carabiner-dev/model-signing/internal/serializer/options"
+	intoto "github.com/in-toto/attestation/go/v1"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// jsonManifest is the stable JSON schema of a Manifest.
+type jsonManifest struct {
+	ModelName        string     `json:"modelName"`
+	HashAlgorithm    string     `json:"hashAlgorithm"`
+	RootDigestMode   string     `json:"rootDigestMode,omitempty"`
+	DigestEncoding   string     `json:"digestEncoding,omitempty"`
+	MetadataInDigest bool       `json:"metadataInDigest,omitempty"`
+	Files            []jsonFile `json:"files"`
+}
+
+// jsonFile is the JSON schema of a manifest file descriptor.
+type jsonFile struct {
+	Name        string            `json:"name"`
+	Digest      map[string]string `json:"digest"`
+	Annotations map[string]any    `json:"annotations,omitempty"`
+}
+
+// jsonlHeader is the first line of the JSON lines encoding of a Manifest.
+type jsonlHeader struct {
+	ModelName        string `json:"modelName"`
+	HashAlgorithm    string `json:"hashAlgorithm"`
+	RootDigestMode   string `json:"rootDigestMode,omitempty"`
+	DigestEncoding   string `json:"digestEncoding,omitempty"`
+	MetadataInDigest bool   `json:"metadataInDigest,omitempty"`
+}
+
+// jsonlFooter is the last line of the JSON lines encoding of a Manifest.
+type jsonlFooter struct {
+	RootDigest string `json:"rootDigest"`
+	FileCount  int    `json:"fileCount"`
+}
+
+// MarshalJSON encodes the manifest with its model name, hash algorithm,
+// root digest mode, digest encoding and MetadataInDigest (when not the
+// defaults) and the name, digests and annotations of every file.
+// Excluded files are not part of the encoding.
+func (m *Manifest) MarshalJSON() ([]byte, error) {
+	out := jsonManifest{
+		ModelName:        m.ModelName,
+		HashAlgorithm:    string(m.algorithm()),
+		MetadataInDigest: m.MetadataInDigest,
+		Files:            make([]jsonFile, 0, len(m.Files)),
+	}
+	if mode := m.rootDigestMode(); mode != options.RootDigestConcat {
+		out.RootDigestMode = string(mode)
+	}
+	if enc := m.digestEncoding(); enc != options.DigestEncodingHex {
+		out.DigestEncoding = string(enc)
+	}
+	for _, file := range m.Files {
+		out.Files = append(out.Files, jsonFile{
+			Name:        file.GetName(),
+			Digest:      file.GetDigest(),
+			Annotations: file.GetAnnotations().AsMap(),
+		})
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON decodes a manifest produced by MarshalJSON. File names
+// that are absolute or hold ".." components fail with ErrUnsafePath.
+func (m *Manifest) UnmarshalJSON(data []byte) error {
+	var in jsonManifest
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+
+	files := make([]*intoto.ResourceDescriptor, 0, len(in.Files))
+	for _, file := range in.Files {
+		if err := checkSafeName(file.Name); err != nil {
+			return err
+		}
+		descriptor := &intoto.ResourceDescriptor{
+			Name:   file.Name,
+			Digest: file.Digest,
+		}
+		if len(file.Annotations) > 0 {
+			annotations, err := structpb.NewStruct(file.Annotations)
+			if err != nil {
+				return fmt.Errorf("decoding annotations of %s: %w", file.Name, err)
+			}
+			descriptor.Annotations = annotations
+		}
+		files = append(files, descriptor)
+	}
+
+	*m = Manifest{
+		ModelName:        in.ModelName,
+		Files:            files,
+		HashAlgorithm:    intoto.HashAlgorithm(in.HashAlgorithm),
+		RootDigestMode:   options.RootDigestMode(in.RootDigestMode),
+		DigestEncoding:   options.DigestEncoding(in.DigestEncoding),
+		MetadataInDigest: in.MetadataInDigest,
+	}
+	return nil
+}
+
+// WriteJSONL writes the manifest to w as JSON lines, one object per line,
+// so tools can process huge manifests without decoding them at once. The
+// first line is the header, with the model name, hash algorithm, root
+// digest mode, digest encoding and MetadataInDigest of MarshalJSON and no
+// files. Every file follows on its own line, encoded as in the "files" of
+// MarshalJSON, in manifest order. The last line holds the root digest in
+// algorithm:hash format and the number of files. The manifest is checked
+// by computing its root digest before anything is written.
+func (m *Manifest) WriteJSONL(w io.Writer) error {
+	rootDigest, err := ComputeRootDigest(m)
+	if err != nil {
+		return fmt.Errorf("computing root digest: %w", err)
+	}
+
+	header := jsonlHeader{
+		ModelName:        m.ModelName,
+		HashAlgorithm:    string(m.algorithm()),
+		MetadataInDigest: m.MetadataInDigest,
+	}
+	if mode := m.rootDigestMode(); mode != options.RootDigestConcat {
+		header.RootDigestMode = string(mode)
+	}
+	if enc := m.digestEncoding(); enc != options.DigestEncodingHex {
+		header.DigestEncoding = string(enc)
+	}
+
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(header); err != nil {
+		return fmt.Errorf("writing header: %w", err)
+	}
+	for _, file := range m.Files {
+		if err := enc.Encode(jsonFile{
+			Name:        file.GetName(),
+			Digest:      file.GetDigest(),
+			Annotations: file.GetAnnotations().AsMap(),
+		}); err != nil {
+			return fmt.Errorf("writing %s: %w", file.GetName(), err)
+		}
+	}
+	if err := enc.Encode(jsonlFooter{
+		RootDigest: string(m.algorithm()) + ":" + rootDigest,
+		FileCount:  len(m.Files),
+	}); err != nil {
+		return fmt.Errorf("writing footer: %w", err)
+	}
+	return nil
+}
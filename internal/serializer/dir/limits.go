@@ -0,0 +1,111 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package dir
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+)
+
+// ErrLimitExceeded is returned when a model holds more files or bytes than
+// the MaxFiles and MaxTotalBytes options allow.
+var ErrLimitExceeded = errors.New("model exceeds the serialization limits")
+
+// limits accounts the files found in a model against MaxFiles and
+// MaxTotalBytes.
+type limits struct {
+	maxFiles int
+	maxBytes int64
+	files    int
+	bytes    int64
+}
+
+// newLimits returns the limits of the serializer options, nil when none
+// is set.
+func (s *Serializer) newLimits() *limits {
+	if s.opts.MaxFiles <= 0 && s.opts.MaxTotalBytes <= 0 {
+		return nil
+	}
+	return &limits{maxFiles: s.opts.MaxFiles, maxBytes: s.opts.MaxTotalBytes}
+}
+
+// add accounts a file of size bytes, failing once a limit is exceeded.
+func (l *limits) add(name string, size int64) error {
+	l.files++
+	l.bytes += size
+	if l.maxFiles > 0 && l.files > l.maxFiles {
+		return fmt.Errorf("%w: more than %d files (MaxFiles) at %s", ErrLimitExceeded, l.maxFiles, name)
+	}
+	if l.maxBytes > 0 && l.bytes > l.maxBytes {
+		return fmt.Errorf("%w: more than %d bytes (MaxTotalBytes) at %s", ErrLimitExceeded, l.maxBytes, name)
+	}
+	return nil
+}
+
+// collectWalk runs walk to the end without hashing and returns the names
+// it found, with the order to hash them in: the order they were found,
+// or largest first under LargestFirst. Every file found is checked
+// against l when it is not nil. When a limit is exceeded the walk stops
+// there and nothing gets hashed.
+func (s *Serializer) collectWalk(ctx context.Context, walk walkFunc, open openFunc, l *limits) (names []string, order []int, err error) {
+	largestFirst := s.largestFirst()
+	var sizes []int64
+	err = walk(func(name string) error {
+		names = append(names, name)
+		if isDirMarker(name) {
+			sizes = append(sizes, 0)
+			return nil
+		}
+		size, err := fileSize(name, open)
+		if err != nil {
+			// Files that cannot be opened are left out, as they would fail hashing
+			if s.skipFailure(ctx, &HashError{Name: name, Err: err}) {
+				names = names[:len(names)-1]
+				return nil
+			}
+			return err
+		}
+		sizes = append(sizes, size)
+		if l == nil {
+			return nil
+		}
+		return l.add(name, size)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	order = make([]int, len(names))
+	for i := range order {
+		order[i] = i
+	}
+	if largestFirst {
+		sort.SliceStable(order, func(i, j int) bool {
+			return sizes[order[i]] > sizes[order[j]]
+		})
+	}
+	return names, order, nil
+}
+
+// fileSize opens the file name and returns its size.
+func fileSize(name string, open openFunc) (int64, error) {
+	f, err := open(name)
+	if err != nil {
+		return 0, fmt.Errorf("opening file: %w", err)
+	}
+	defer f.Close() //nolint:errcheck
+
+	st, ok := f.(interface{ Stat() (fs.FileInfo, error) })
+	if !ok {
+		return 0, fmt.Errorf("cannot read the size of %s", name)
+	}
+	info, err := st.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("reading size of %s: %w", name, err)
+	}
+	return info.Size(), nil
+}
@@ -0,0 +1,155 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package dir
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/carabiner-dev/model-signing/internal/serializer/options"
+)
+
+func TestLimits(t *testing.T) {
+	// newTestManifest holds 3 files of 13 bytes in total
+	tempDir, _ := newTestManifest(t)
+
+	for _, tc := range []struct {
+		name     string
+		files    int
+		bytes    int64
+		exceeded bool
+	}{
+		{"NoLimits", 0, 0, false},
+		{"FilesAtLimit", 3, 0, false},
+		{"FilesExceeded", 2, 0, true},
+		{"BytesAtLimit", 0, 14, false},
+		{"BytesExceeded", 0, 13, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := options.Default()
+			opts.MaxFiles = tc.files
+			opts.MaxTotalBytes = tc.bytes
+
+			for _, confine := range []bool{false, true} {
+				opts.ConfineToRoot = confine
+				_, err := New(opts).Serialize(tempDir)
+				if tc.exceeded != errors.Is(err, ErrLimitExceeded) {
+					t.Errorf("ConfineToRoot %v: expected exceeded %v, got %v", confine, tc.exceeded, err)
+				}
+				if tc.exceeded && !errors.Is(err, ErrWalkFailed) {
+					t.Errorf("ConfineToRoot %v: expected ErrWalkFailed, got %v", confine, err)
+				}
+			}
+
+			archive := buildTar(t, []tarEntry{
+				{name: "model.bin", content: "weights", typeflag: tar.TypeReg},
+				{name: "config.json", content: "{}", typeflag: tar.TypeReg},
+				{name: "subdir/layer.bin", content: "layer", typeflag: tar.TypeReg},
+			})
+			_, err := SerializeTar(bytes.NewReader(archive), opts)
+			if tc.exceeded != errors.Is(err, ErrLimitExceeded) {
+				t.Errorf("SerializeTar: expected exceeded %v, got %v", tc.exceeded, err)
+			}
+		})
+	}
+}
+
+func TestLimitsBeforeHashing(t *testing.T) {
+	opts := options.Default()
+	opts.MaxFiles = 2
+
+	var reads atomic.Int32
+	_, walkErr, hashErr := New(opts).hashWalk(context.Background(), func(yield func(string) error) error {
+		for _, name := range []string{"a", "b", "c"} {
+			if err := yield(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, func(string) (io.ReadCloser, error) {
+		return &tarFile{Reader: readCounter{&reads, strings.NewReader("data")}, hdr: &tar.Header{Size: 4}}, nil
+	})
+	if !errors.Is(walkErr, ErrLimitExceeded) || hashErr != nil {
+		t.Fatalf("Expected ErrLimitExceeded, got %v, %v", walkErr, hashErr)
+	}
+	if n := reads.Load(); n != 0 {
+		t.Errorf("Expected no file to be read, got %d reads", n)
+	}
+}
+
+// readCounter counts the reads of the wrapped reader.
+type readCounter struct {
+	n *atomic.Int32
+	r io.Reader
+}
+
+func (rc readCounter) Read(p []byte) (int, error) {
+	rc.n.Add(1)
+	return rc.r.Read(p)
+}
+
+// manifestNames returns the names of the manifest files, in order.
+func manifestNames(manifest *Manifest) []string {
+	names := make([]string, 0, len(manifest.Files))
+	for _, file := range manifest.Files {
+		names = append(names, file.Name)
+	}
+	return names
+}
+
+func TestMaxFileSize(t *testing.T) {
+	// model.bin holds 7 bytes, layer.bin 5 and config.json 2
+	tempDir, _ := newTestManifest(t)
+	expected := []string{"config.json", "subdir/layer.bin"}
+
+	for _, confine := range []bool{false, true} {
+		opts := options.Default().Apply(options.WithMaxFileSize(5))
+		opts.ConfineToRoot = confine
+
+		manifest, err := New(opts).Serialize(tempDir)
+		if err != nil {
+			t.Fatalf("ConfineToRoot %v: Serialize failed: %v", confine, err)
+		}
+		if names := manifestNames(manifest); !slices.Equal(names, expected) {
+			t.Errorf("ConfineToRoot %v: expected %v, got %v", confine, expected, names)
+		}
+
+		included, ignored, err := New(opts).DryRun(tempDir)
+		if err != nil {
+			t.Fatalf("ConfineToRoot %v: DryRun failed: %v", confine, err)
+		}
+		if !slices.Equal(included, expected) || !slices.Equal(ignored, []string{"model.bin"}) {
+			t.Errorf("ConfineToRoot %v: unexpected dry run %v, ignored %v", confine, included, ignored)
+		}
+	}
+
+	archive := buildTar(t, []tarEntry{
+		{name: "model.bin", content: "weights", typeflag: tar.TypeReg},
+		{name: "config.json", content: "{}", typeflag: tar.TypeReg},
+		{name: "subdir/layer.bin", content: "layer", typeflag: tar.TypeReg},
+	})
+	manifest, err := SerializeTar(bytes.NewReader(archive), options.Default().Apply(options.WithMaxFileSize(5)))
+	if err != nil {
+		t.Fatalf("SerializeTar failed: %v", err)
+	}
+	if names := manifestNames(manifest); !slices.Equal(names, expected) {
+		t.Errorf("SerializeTar: expected %v, got %v", expected, names)
+	}
+
+	// Not set, nothing is left out
+	manifest, err = New(options.Default()).Serialize(tempDir)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	if len(manifest.Files) != 3 {
+		t.Errorf("Expected 3 files without MaxFileSize, got %v", manifestNames(manifest))
+	}
+}
@@ -0,0 +1,95 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package dir
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/carabiner-dev/model-signing/internal/serializer/options"
+)
+
+// AnnotationLinkTarget is the descriptor annotation recording the target
+// of a symlink recorded with the SymlinkRecordLink policy.
+const AnnotationLinkTarget = "linkTarget"
+
+// recordedLinks collects the symlinks recorded with SymlinkRecordLink.
+// The walk adds the links it finds and the hashing opens them as their
+// target path instead of following them.
+type recordedLinks struct {
+	// readLink returns the target of the symlink at the model path name.
+	readLink func(name string) (string, error)
+
+	targets sync.Map
+}
+
+// recordedLinks returns the links to record with readLink under the
+// SymlinkRecordLink policy, nil under any other policy.
+func (s *Serializer) recordedLinks(readLink func(name string) (string, error)) *recordedLinks {
+	if s.symlinkPolicy() != options.SymlinkRecordLink {
+		return nil
+	}
+	return &recordedLinks{readLink: readLink}
+}
+
+// add reads the target of the symlink name, with the file info info, and
+// records it. The target keeps slash separators on every platform.
+func (l *recordedLinks) add(name string, info fs.FileInfo) error {
+	if l == nil || l.readLink == nil {
+		return fmt.Errorf("cannot read the target of symlink %s", name)
+	}
+	target, err := l.readLink(name)
+	if err != nil {
+		return fmt.Errorf("reading symlink %s: %w", name, err)
+	}
+	l.targets.Store(name, &linkFile{target: filepath.ToSlash(target), info: info})
+	return nil
+}
+
+// opener returns open with the recorded links opening as their targets.
+func (l *recordedLinks) opener(open openFunc) openFunc {
+	if l == nil {
+		return open
+	}
+	return func(name string) (io.ReadCloser, error) {
+		if link, ok := l.targets.Load(name); ok {
+			return newLinkFile(link.(*linkFile).target, link.(*linkFile).info), nil
+		}
+		return open(name)
+	}
+}
+
+// linkFile reads the target path of a recorded symlink.
+type linkFile struct {
+	io.Reader
+	target string
+	info   fs.FileInfo
+}
+
+// newLinkFile returns a linkFile reading target.
+func newLinkFile(target string, info fs.FileInfo) *linkFile {
+	return &linkFile{Reader: strings.NewReader(target), target: target, info: info}
+}
+
+func (f *linkFile) Close() error               { return nil }
+func (f *linkFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+
+// readLinkFS is implemented by the file systems able to read symlinks,
+// like os.DirFS in recent Go releases.
+type readLinkFS interface {
+	ReadLink(name string) (string, error)
+}
+
+// fsReadLink returns the function reading the symlinks of fsys, nil if it
+// cannot read them.
+func fsReadLink(fsys fs.FS) func(name string) (string, error) {
+	if rl, ok := fsys.(readLinkFS); ok {
+		return rl.ReadLink
+	}
+	return nil
+}
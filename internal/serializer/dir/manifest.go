@@ -0,0 +1,159 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package dir
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/carabiner-dev/model-signing/internal/serializer/options"
+	intoto "github.com/in-toto/attestation/go/v1"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Manifest represents the serialized model with all file hashes.
+type Manifest struct {
+	ModelName string
+	Files     []*intoto.ResourceDescriptor
+
+	// HashAlgorithm is the algorithm of the file digests and of the root
+	// digest. Manifests leaving it empty are treated as SHA256.
+	HashAlgorithm intoto.HashAlgorithm
+
+	// RootDigestMode is how the root digest combines the file hashes.
+	// Manifests leaving it empty use RootDigestConcat.
+	RootDigestMode options.RootDigestMode
+
+	// DigestEncoding is the text encoding of the file digests and of the
+	// root digest. Manifests leaving it empty are hex-encoded.
+	DigestEncoding options.DigestEncoding
+
+	// MetadataInDigest folds the file annotations into the root digest,
+	// as set by the IncludeMetadataInDigest option.
+	MetadataInDigest bool
+
+	// Excluded lists the names of the files hashed but left out of the
+	// manifest by the PostHash option.
+	Excluded []string
+
+	// Stats describes the serialization that produced the manifest. It is
+	// not part of the manifest digests nor of its encodings.
+	Stats Stats
+
+	// SerializedAt is when the serialization producing the manifest
+	// finished, for provenance records. It is not part of the root digest
+	// (ComputeRootDigest only covers the files) nor of the encodings, so
+	// serializing the same model at different times gives the same
+	// digests and signatures. Manifests decoded from JSON leave it zero.
+	SerializedAt time.Time
+
+	// ModelPath is the absolute path of the model serialized from disk,
+	// checked by SerializeIncremental. Like SerializedAt, it is not part
+	// of the digests nor of the encodings. Models read from a file
+	// system, an archive or several roots, and manifests decoded from
+	// JSON, leave it empty.
+	ModelPath string
+}
+
+// GetFile returns the descriptor of the file name, and whether the
+// manifest lists it. The files of the manifests produced by the
+// serializer are sorted by name, so they are binary searched; names not
+// found that way are looked for one by one, in case Files was built or
+// modified out of order.
+func (m *Manifest) GetFile(name string) (*intoto.ResourceDescriptor, bool) {
+	i, found := slices.BinarySearchFunc(m.Files, name, func(file *intoto.ResourceDescriptor, name string) int {
+		return strings.Compare(file.GetName(), name)
+	})
+	if found {
+		return m.Files[i], true
+	}
+	for _, file := range m.Files {
+		if file.GetName() == name {
+			return file, true
+		}
+	}
+	return nil, false
+}
+
+// algorithm returns the hash algorithm of the manifest, defaulting to
+// SHA256.
+func (m *Manifest) algorithm() intoto.HashAlgorithm {
+	if m.HashAlgorithm == "" {
+		return intoto.AlgorithmSHA256
+	}
+	return m.HashAlgorithm
+}
+
+// rootDigestMode returns the root digest mode of the manifest, defaulting
+// to RootDigestConcat.
+func (m *Manifest) rootDigestMode() options.RootDigestMode {
+	if m.RootDigestMode == "" {
+		return options.RootDigestConcat
+	}
+	return m.RootDigestMode
+}
+
+// ToStatement returns the manifest as an in-toto v1 statement of the
+// given predicate type. Every file becomes a subject, preceded by a
+// subject named after the model carrying its root digest. The model name
+// is recorded in the predicate under "modelName", the root digest mode
+// under "rootDigestMode" unless it is the default, and MetadataInDigest
+// under "metadataInDigest" when set. Digests are always
+// hex-encoded in the statement, as in-toto requires, whatever the
+// DigestEncoding of the manifest.
+func (m *Manifest) ToStatement(predicateType string) (*intoto.Statement, error) {
+	rootDigest, err := ComputeRootDigest(m)
+	if err != nil {
+		return nil, fmt.Errorf("computing root digest: %w", err)
+	}
+	rootDigest, err = transcodeDigest(m.digestEncoding(), options.DigestEncodingHex, rootDigest)
+	if err != nil {
+		return nil, fmt.Errorf("decoding root digest: %w", err)
+	}
+
+	subjects := make([]*intoto.ResourceDescriptor, 0, len(m.Files)+1)
+	subjects = append(subjects, &intoto.ResourceDescriptor{
+		Name: m.ModelName,
+		Digest: map[string]string{
+			string(m.algorithm()): rootDigest,
+		},
+	})
+	for _, file := range m.Files {
+		subject := proto.Clone(file).(*intoto.ResourceDescriptor)
+		subject.Digest, err = transcodeDigests(m.digestEncoding(), options.DigestEncodingHex, file.Digest)
+		if err != nil {
+			return nil, fmt.Errorf("decoding digests of %s: %w", file.Name, err)
+		}
+		subjects = append(subjects, subject)
+	}
+
+	fields := map[string]any{
+		"modelName": m.ModelName,
+	}
+	if mode := m.rootDigestMode(); mode != options.RootDigestConcat {
+		fields["rootDigestMode"] = string(mode)
+	}
+	if m.MetadataInDigest {
+		fields["metadataInDigest"] = true
+	}
+	predicate, err := structpb.NewStruct(fields)
+	if err != nil {
+		return nil, fmt.Errorf("building predicate: %w", err)
+	}
+
+	statement := &intoto.Statement{
+		Type:          intoto.StatementTypeUri,
+		Subject:       subjects,
+		PredicateType: predicateType,
+		Predicate:     predicate,
+	}
+	if err := statement.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid statement: %w", err)
+	}
+
+	return statement, nil
+}
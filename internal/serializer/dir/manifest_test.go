@@ -0,0 +1,306 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package dir
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/carabiner-dev/model-signing/internal/serializer/options"
+	"google.golang.org/protobuf/proto"
+)
+
+// newTestManifest serializes a small model directory for the manifest
+// tests. The directory is removed when the test ends.
+func newTestManifest(t *testing.T) (string, *Manifest) {
+	t.Helper()
+	tempDir, err := os.MkdirTemp("", "modeldigest-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(tempDir) })
+
+	for name, content := range map[string]string{
+		"model.bin":        "weights",
+		"config.json":      "{}",
+		"subdir/layer.bin": "layer",
+	} {
+		path := filepath.Join(tempDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create dir for %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test file %s: %v", name, err)
+		}
+	}
+
+	manifest, err := New(options.Default()).Serialize(tempDir)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	return tempDir, manifest
+}
+
+func TestToStatement(t *testing.T) {
+	_, manifest := newTestManifest(t)
+
+	statement, err := manifest.ToStatement("https://model_signing/signature/v1.0")
+	if err != nil {
+		t.Fatalf("ToStatement failed: %v", err)
+	}
+
+	if statement.GetPredicateType() != "https://model_signing/signature/v1.0" {
+		t.Errorf("Unexpected predicate type %s", statement.GetPredicateType())
+	}
+
+	if len(statement.GetSubject()) != len(manifest.Files)+1 {
+		t.Fatalf("Expected %d subjects, got %d", len(manifest.Files)+1, len(statement.GetSubject()))
+	}
+
+	rootDigest, err := ComputeRootDigest(manifest)
+	if err != nil {
+		t.Fatalf("ComputeRootDigest failed: %v", err)
+	}
+	root := statement.GetSubject()[0]
+	if root.GetName() != manifest.ModelName || root.GetDigest()["sha256"] != rootDigest {
+		t.Errorf("Unexpected root subject %s: %v", root.GetName(), root.GetDigest())
+	}
+
+	for i, file := range manifest.Files {
+		subject := statement.GetSubject()[i+1]
+		if subject.GetName() != file.Name || subject.GetDigest()["sha256"] != file.Digest["sha256"] {
+			t.Errorf("Subject %d does not match file %s", i+1, file.Name)
+		}
+	}
+
+	if got := statement.GetPredicate().GetFields()["modelName"].GetStringValue(); got != manifest.ModelName {
+		t.Errorf("Expected modelName %s in predicate, got %q", manifest.ModelName, got)
+	}
+
+	if _, err := manifest.ToStatement(""); err == nil {
+		t.Error("Expected error for empty predicate type")
+	}
+}
+
+func TestModelName(t *testing.T) {
+	tempDir, manifest := newTestManifest(t)
+	if manifest.ModelName != filepath.Base(tempDir) {
+		t.Errorf("Expected model name %s, got %s", filepath.Base(tempDir), manifest.ModelName)
+	}
+
+	named, err := NewWithOptions(options.WithModelName("org/model")).Serialize(tempDir)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	if named.ModelName != "org/model" {
+		t.Errorf("Expected model name org/model, got %s", named.ModelName)
+	}
+
+	// Archives get the name too
+	archive := buildTar(t, []tarEntry{{name: "model.bin", typeflag: tar.TypeReg, content: "weights"}})
+	fromTar, err := SerializeTar(bytes.NewReader(archive), options.Default().Apply(options.WithModelName("org/model")))
+	if err != nil {
+		t.Fatalf("SerializeTar failed: %v", err)
+	}
+	if fromTar.ModelName != "org/model" {
+		t.Errorf("Expected model name org/model for the archive, got %q", fromTar.ModelName)
+	}
+
+	// The name is not part of the root digest
+	expected, err := ComputeRootDigest(manifest)
+	if err != nil {
+		t.Fatalf("ComputeRootDigest failed: %v", err)
+	}
+	if digest, err := ComputeRootDigest(named); err != nil || digest != expected {
+		t.Errorf("Model name changed the root digest: %s != %s (%v)", digest, expected, err)
+	}
+
+	statement, err := named.ToStatement("https://model_signing/signature/v1.0")
+	if err != nil {
+		t.Fatalf("ToStatement failed: %v", err)
+	}
+	if got := statement.GetSubject()[0].GetName(); got != "org/model" {
+		t.Errorf("Expected the model subject to be org/model, got %s", got)
+	}
+}
+
+func TestManifestJSON(t *testing.T) {
+	tempDir, manifest := newTestManifest(t)
+
+	opts := options.Default()
+	opts.DecompressExtensions = map[string]options.Compression{".gz": options.CompressionGzip}
+	if err := os.WriteFile(filepath.Join(tempDir, "vocab.gz"), gzipData(t, []byte("vocab"), 1), 0644); err != nil {
+		t.Fatalf("Failed to create compressed file: %v", err)
+	}
+	annotated, err := New(opts).Serialize(tempDir)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+
+	for _, m := range []*Manifest{manifest, annotated} {
+		data, err := json.Marshal(m)
+		if err != nil {
+			t.Fatalf("Marshal failed: %v", err)
+		}
+
+		var loaded Manifest
+		if err := json.Unmarshal(data, &loaded); err != nil {
+			t.Fatalf("Unmarshal failed: %v", err)
+		}
+
+		if loaded.ModelName != m.ModelName || loaded.HashAlgorithm != m.HashAlgorithm {
+			t.Errorf("Round trip changed model name or algorithm: %+v", loaded)
+		}
+		if len(loaded.Files) != len(m.Files) {
+			t.Fatalf("Expected %d files, got %d", len(m.Files), len(loaded.Files))
+		}
+		for i := range m.Files {
+			if !proto.Equal(loaded.Files[i], m.Files[i]) {
+				t.Errorf("File %d changed in round trip: %v != %v", i, loaded.Files[i], m.Files[i])
+			}
+		}
+
+		expected, err := ComputeRootDigest(m)
+		if err != nil {
+			t.Fatalf("ComputeRootDigest failed: %v", err)
+		}
+		got, err := ComputeRootDigest(&loaded)
+		if err != nil {
+			t.Fatalf("ComputeRootDigest failed: %v", err)
+		}
+		if got != expected {
+			t.Errorf("Root digest changed in round trip: %s != %s", got, expected)
+		}
+
+		// The encoding is stable
+		again, err := json.Marshal(&loaded)
+		if err != nil {
+			t.Fatalf("Marshal failed: %v", err)
+		}
+		if string(again) != string(data) {
+			t.Errorf("Encoding is not stable:\n%s\n%s", data, again)
+		}
+	}
+}
+
+func TestWriteJSONL(t *testing.T) {
+	_, manifest := newTestManifest(t)
+
+	var buf bytes.Buffer
+	if err := manifest.WriteJSONL(&buf); err != nil {
+		t.Fatalf("WriteJSONL failed: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != len(manifest.Files)+2 {
+		t.Fatalf("Expected %d lines, got %d:\n%s", len(manifest.Files)+2, len(lines), buf.String())
+	}
+	if expected := `{"modelName":"` + manifest.ModelName + `","hashAlgorithm":"sha256"}`; lines[0] != expected {
+		t.Errorf("Expected header %s, got %s", expected, lines[0])
+	}
+
+	// The file lines decode as the files of the JSON encoding
+	var loaded Manifest
+	files := "[" + strings.Join(lines[1:len(lines)-1], ",") + "]"
+	if err := json.Unmarshal([]byte(`{"hashAlgorithm":"sha256","files":`+files+`}`), &loaded); err != nil {
+		t.Fatalf("Failed to decode file lines: %v", err)
+	}
+	for i := range manifest.Files {
+		if !proto.Equal(loaded.Files[i], manifest.Files[i]) {
+			t.Errorf("File %d: expected %v, got %v", i, manifest.Files[i], loaded.Files[i])
+		}
+	}
+
+	rootDigest, err := ComputeRootDigest(manifest)
+	if err != nil {
+		t.Fatalf("ComputeRootDigest failed: %v", err)
+	}
+	var footer struct {
+		RootDigest string `json:"rootDigest"`
+		FileCount  int    `json:"fileCount"`
+	}
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &footer); err != nil {
+		t.Fatalf("Failed to decode footer: %v", err)
+	}
+	if footer.RootDigest != "sha256:"+rootDigest || footer.FileCount != len(manifest.Files) {
+		t.Errorf("Unexpected footer %+v", footer)
+	}
+
+	// Invalid manifests write nothing
+	buf.Reset()
+	manifest.Files[0].Digest = nil
+	if err := manifest.WriteJSONL(&buf); err == nil || buf.Len() > 0 {
+		t.Errorf("Expected an error and no output, got %v and %q", err, buf.String())
+	}
+}
+
+func TestSerializedAt(t *testing.T) {
+	before := time.Now()
+	tempDir, manifest := newTestManifest(t)
+	if manifest.SerializedAt.Before(before) || manifest.SerializedAt.After(time.Now()) {
+		t.Errorf("Unexpected serialization time %v", manifest.SerializedAt)
+	}
+
+	expected, err := ComputeRootDigest(manifest)
+	if err != nil {
+		t.Fatalf("ComputeRootDigest failed: %v", err)
+	}
+	canonical, err := manifest.Canonicalize()
+	if err != nil {
+		t.Fatalf("Canonicalize failed: %v", err)
+	}
+
+	// The time is not part of the digests nor of the encoding
+	again, err := New(options.Default()).Serialize(tempDir)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	for _, at := range []time.Time{{}, manifest.SerializedAt.Add(time.Hour), again.SerializedAt} {
+		m := *again
+		m.SerializedAt = at
+		digest, err := ComputeRootDigest(&m)
+		if err != nil {
+			t.Fatalf("ComputeRootDigest failed: %v", err)
+		}
+		if digest != expected {
+			t.Errorf("SerializedAt %v changed the root digest: %s != %s", at, digest, expected)
+		}
+		encoded, err := m.Canonicalize()
+		if err != nil {
+			t.Fatalf("Canonicalize failed: %v", err)
+		}
+		if !bytes.Equal(encoded, canonical) {
+			t.Errorf("SerializedAt %v changed the encoding", at)
+		}
+	}
+}
+
+func TestGetFile(t *testing.T) {
+	_, manifest := newTestManifest(t)
+
+	for _, file := range manifest.Files {
+		got, ok := manifest.GetFile(file.Name)
+		if !ok || got != file {
+			t.Errorf("GetFile(%q) = %v, %v; expected %v", file.Name, got, ok, file)
+		}
+	}
+	if got, ok := manifest.GetFile("missing.bin"); ok || got != nil {
+		t.Errorf("Expected missing.bin not to be found, got %v", got)
+	}
+
+	// Files out of order are still found
+	slices.Reverse(manifest.Files)
+	for _, name := range []string{"config.json", "model.bin", "subdir/layer.bin"} {
+		if got, ok := manifest.GetFile(name); !ok || got.Name != name {
+			t.Errorf("GetFile(%q) on unsorted files = %v, %v", name, got, ok)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package dir
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	intoto "github.com/in-toto/attestation/go/v1"
+)
+
+// Node prefixes of the Merkle tree, as in RFC 6962, so a leaf can never
+// be taken for an interior node.
+const (
+	merkleLeafPrefix     = 0x00
+	merkleInteriorPrefix = 0x01
+)
+
+// ComputeMerkleRoot computes a Merkle tree root over the file hashes of
+// the manifest, as an alternative to the flat ComputeRootDigest.
+//
+// The tree is built as follows, using the manifest HashAlgorithm as H:
+//
+//   - Leaves are H(0x00 || hash) over the raw file hashes, in manifest
+//     order (sorted by name).
+//   - Each parent is H(0x01 || left || right) over the raw bytes of its
+//     children.
+//   - A level with an odd number of nodes moves its last node up to the
+//     next level unchanged.
+//   - The root of a single-file manifest is its leaf and the root of an
+//     empty manifest is H of empty input.
+//
+// The prefixes keep leaves and interior nodes apart, and the shape of
+// the tree depends on the number of files, so the root binds both the
+// ordered file contents and their number. The root is hex-encoded, as
+// are inclusion proofs, whatever the DigestEncoding of the manifest.
+func ComputeMerkleRoot(manifest *Manifest) (string, error) {
+	algo := manifest.algorithm()
+	level, err := merkleLeaves(manifest, algo)
+	if err != nil {
+		return "", err
+	}
+
+	if len(level) == 0 {
+		h := newHasher(algo)
+		return hex.EncodeToString(h.Sum(nil)), nil
+	}
+
+	for len(level) > 1 {
+		level = merkleLevel(algo, level)
+	}
+	return hex.EncodeToString(level[0]), nil
+}
+
+// merkleLeaves returns the leaves of the manifest Merkle tree, hashing
+// the decoded algo hashes of its files.
+func merkleLeaves(manifest *Manifest, algo intoto.HashAlgorithm) ([][]byte, error) {
+	if newHasher(algo) == nil {
+		return nil, fmt.Errorf("unsupported hash algorithm %q", algo)
+	}
+
+	leaves := make([][]byte, 0, len(manifest.Files))
+	for _, file := range manifest.Files {
+		hashValue, ok := file.Digest[string(algo)]
+		if !ok {
+			return nil, fmt.Errorf("%s digest not found for %s", algo, file.Name)
+		}
+		leaf, err := decodeDigest(manifest.digestEncoding(), hashValue)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode hash for %s: %w", file.Name, err)
+		}
+		leaves = append(leaves, merkleLeaf(algo, leaf))
+	}
+	return leaves, nil
+}
+
+// merkleLeaf returns H(0x00 || hash), the leaf of the file hash.
+func merkleLeaf(algo intoto.HashAlgorithm, hash []byte) []byte {
+	h := newHasher(algo)
+	h.Write([]byte{merkleLeafPrefix})
+	h.Write(hash)
+	return h.Sum(nil)
+}
+
+// merkleLevel hashes the nodes of a tree level in pairs, moving the last
+// one up on odd levels, and returns the level above.
+func merkleLevel(algo intoto.HashAlgorithm, level [][]byte) [][]byte {
+	parents := make([][]byte, 0, (len(level)+1)/2)
+	for i := 0; i < len(level); i += 2 {
+		if i+1 == len(level) {
+			parents = append(parents, level[i])
+			break
+		}
+		parents = append(parents, merkleParent(algo, level[i], level[i+1]))
+	}
+	return parents
+}
+
+// merkleParent returns H(0x01 || left || right).
+func merkleParent(algo intoto.HashAlgorithm, left, right []byte) []byte {
+	h := newHasher(algo)
+	h.Write([]byte{merkleInteriorPrefix})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+// InclusionProof proves that a file is a leaf of a manifest Merkle tree
+// (see ComputeMerkleRoot) without revealing the other files.
+type InclusionProof struct {
+	// Name is the name of the proven file.
+	Name string
+
+	// Index is the position of the file leaf in the tree.
+	Index int
+
+	// LeafCount is the number of leaves of the tree, the number of files
+	// of the manifest. It tells the levels where the node of the file has
+	// no sibling.
+	LeafCount int
+
+	// HashAlgorithm is the algorithm used to build the tree.
+	HashAlgorithm intoto.HashAlgorithm
+
+	// Path lists the hex-encoded sibling hashes from the leaf up to the
+	// root, skipping the levels where the node moves up unchanged.
+	Path []string
+}
+
+// ProveInclusion returns the audit path proving that fileName is part of
+// the manifest Merkle tree.
+func ProveInclusion(manifest *Manifest, fileName string) (*InclusionProof, error) {
+	algo := manifest.algorithm()
+	level, err := merkleLeaves(manifest, algo)
+	if err != nil {
+		return nil, err
+	}
+
+	index := -1
+	for i, file := range manifest.Files {
+		if file.Name == fileName {
+			index = i
+			break
+		}
+	}
+	if index == -1 {
+		return nil, fmt.Errorf("file %q not found in manifest", fileName)
+	}
+
+	proof := &InclusionProof{
+		Name:          fileName,
+		Index:         index,
+		LeafCount:     len(level),
+		HashAlgorithm: algo,
+		Path:          []string{},
+	}
+	for i := index; len(level) > 1; i /= 2 {
+		if sibling := i ^ 1; sibling < len(level) {
+			proof.Path = append(proof.Path, hex.EncodeToString(level[sibling]))
+		}
+		level = merkleLevel(algo, level)
+	}
+
+	return proof, nil
+}
+
+// VerifyInclusion returns true if the proof shows that a file with the
+// hex-encoded fileDigest is a leaf of the Merkle tree with the
+// hex-encoded root. The path must hold exactly one sibling per level of a
+// tree of LeafCount leaves where the node of the file has one.
+func VerifyInclusion(root string, proof *InclusionProof, fileDigest string) bool {
+	if proof == nil || proof.Index < 0 || proof.Index >= proof.LeafCount {
+		return false
+	}
+
+	algo := proof.HashAlgorithm
+	if algo == "" {
+		algo = intoto.AlgorithmSHA256
+	}
+	if newHasher(algo) == nil {
+		return false
+	}
+
+	hash, err := hex.DecodeString(fileDigest)
+	if err != nil {
+		return false
+	}
+	node := merkleLeaf(algo, hash)
+
+	path := proof.Path
+	for index, size := proof.Index, proof.LeafCount; size > 1; index, size = index/2, (size+1)/2 {
+		// The last node of an odd level moves up unchanged
+		if index^1 >= size {
+			continue
+		}
+		if len(path) == 0 {
+			return false
+		}
+		sibling, err := hex.DecodeString(path[0])
+		if err != nil || len(sibling) != len(node) {
+			return false
+		}
+		path = path[1:]
+		if index%2 == 0 {
+			node = merkleParent(algo, node, sibling)
+		} else {
+			node = merkleParent(algo, sibling, node)
+		}
+	}
+
+	return len(path) == 0 && hex.EncodeToString(node) == root
+}
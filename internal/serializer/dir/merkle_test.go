@@ -0,0 +1,190 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package dir
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	intoto "github.com/in-toto/attestation/go/v1"
+)
+
+// testLeaf returns the sha256 of the file number i used in the Merkle
+// tree tests.
+func testLeaf(i int) []byte {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("file-%d", i)))
+	return sum[:]
+}
+
+// testMerkleManifest returns a manifest with n files hashing to testLeaf.
+func testMerkleManifest(n int) *Manifest {
+	manifest := &Manifest{ModelName: "model"}
+	for i := range n {
+		manifest.Files = append(manifest.Files, &intoto.ResourceDescriptor{
+			Name:   fmt.Sprintf("file-%02d", i),
+			Digest: map[string]string{"sha256": hex.EncodeToString(testLeaf(i))},
+		})
+	}
+	return manifest
+}
+
+func TestComputeMerkleRoot(t *testing.T) {
+	leaf := func(i int) []byte {
+		sum := sha256.Sum256(append([]byte{0x00}, testLeaf(i)...))
+		return sum[:]
+	}
+	node := func(left, right []byte) []byte {
+		sum := sha256.Sum256(append(append([]byte{0x01}, left...), right...))
+		return sum[:]
+	}
+
+	empty := sha256.Sum256(nil)
+	for _, tc := range []struct {
+		files  int
+		expect []byte
+	}{
+		{0, empty[:]},
+		{1, leaf(0)},
+		{2, node(leaf(0), leaf(1))},
+		{3, node(node(leaf(0), leaf(1)), leaf(2))},
+		{5, node(node(node(leaf(0), leaf(1)), node(leaf(2), leaf(3))), leaf(4))},
+		{6, node(node(node(leaf(0), leaf(1)), node(leaf(2), leaf(3))), node(leaf(4), leaf(5)))},
+	} {
+		t.Run(fmt.Sprintf("%d-files", tc.files), func(t *testing.T) {
+			root, err := ComputeMerkleRoot(testMerkleManifest(tc.files))
+			if err != nil {
+				t.Fatalf("ComputeMerkleRoot failed: %v", err)
+			}
+			if root != hex.EncodeToString(tc.expect) {
+				t.Errorf("Expected root %x, got %s", tc.expect, root)
+			}
+		})
+	}
+
+	// The flat digest is unaffected
+	manifest := testMerkleManifest(3)
+	flat, err := ComputeRootDigest(manifest)
+	if err != nil {
+		t.Fatalf("ComputeRootDigest failed: %v", err)
+	}
+	merkle, err := ComputeMerkleRoot(manifest)
+	if err != nil {
+		t.Fatalf("ComputeMerkleRoot failed: %v", err)
+	}
+	if flat == merkle {
+		t.Error("Merkle root should differ from the flat digest")
+	}
+
+	// Repeating the last file changes the root
+	repeated := testMerkleManifest(3)
+	repeated.Files = append(repeated.Files, &intoto.ResourceDescriptor{Name: "file-03", Digest: repeated.Files[2].Digest})
+	if other, err := ComputeMerkleRoot(repeated); err != nil || other == merkle {
+		t.Errorf("Expected a different root with the last file repeated, got %s (%v)", other, err)
+	}
+
+	// A single file is not its own root
+	single := testMerkleManifest(1)
+	if root, err := ComputeMerkleRoot(single); err != nil || root == single.Files[0].Digest["sha256"] {
+		t.Errorf("Expected the root of a single file to differ from its hash, got %s (%v)", root, err)
+	}
+
+	manifest.Files[1].Digest = map[string]string{"sha512": "00"}
+	if _, err := ComputeMerkleRoot(manifest); err == nil {
+		t.Error("Expected error for missing digest")
+	}
+}
+
+func TestInclusionProof(t *testing.T) {
+	for _, n := range []int{1, 2, 3, 5, 8, 13} {
+		manifest := testMerkleManifest(n)
+		root, err := ComputeMerkleRoot(manifest)
+		if err != nil {
+			t.Fatalf("ComputeMerkleRoot failed: %v", err)
+		}
+
+		for i, file := range manifest.Files {
+			proof, err := ProveInclusion(manifest, file.Name)
+			if err != nil {
+				t.Fatalf("ProveInclusion failed for %s: %v", file.Name, err)
+			}
+			if proof.Index != i {
+				t.Errorf("Expected index %d, got %d", i, proof.Index)
+			}
+
+			if !VerifyInclusion(root, proof, file.Digest["sha256"]) {
+				t.Errorf("Proof for %s in %d-file tree does not verify", file.Name, n)
+			}
+
+			// A different file digest must not verify
+			other := hex.EncodeToString(testLeaf(n + 1))
+			if VerifyInclusion(root, proof, other) {
+				t.Errorf("Proof for %s verifies a foreign digest", file.Name)
+			}
+
+			// Neither must the proof when the leaf swaps sides
+			if n > 1 && i+1 < n {
+				moved := *proof
+				moved.Index = i ^ 1
+				if VerifyInclusion(root, &moved, file.Digest["sha256"]) {
+					t.Errorf("Proof for %s verifies at index %d", file.Name, moved.Index)
+				}
+			}
+		}
+	}
+
+	if _, err := ProveInclusion(testMerkleManifest(3), "missing"); err == nil {
+		t.Error("Expected error for a file not in the manifest")
+	}
+	if VerifyInclusion("", nil, "") {
+		t.Error("A nil proof must not verify")
+	}
+}
+
+func TestInclusionProofForgery(t *testing.T) {
+	manifest := testMerkleManifest(4)
+	root, err := ComputeMerkleRoot(manifest)
+	if err != nil {
+		t.Fatalf("ComputeMerkleRoot failed: %v", err)
+	}
+	leaves, err := merkleLeaves(manifest, intoto.AlgorithmSHA256)
+	if err != nil {
+		t.Fatalf("merkleLeaves failed: %v", err)
+	}
+	left := merkleParent(intoto.AlgorithmSHA256, leaves[0], leaves[1])
+	right := hex.EncodeToString(merkleParent(intoto.AlgorithmSHA256, leaves[2], leaves[3]))
+
+	// A file whose content is the two leaves below the left node, claimed
+	// to be a leaf one level up
+	forged := sha256.Sum256(append(append([]byte{}, leaves[0]...), leaves[1]...))
+	for _, digest := range []string{hex.EncodeToString(forged[:]), hex.EncodeToString(left)} {
+		for _, leafCount := range []int{2, 4} {
+			proof := &InclusionProof{Index: 0, LeafCount: leafCount, HashAlgorithm: intoto.AlgorithmSHA256, Path: []string{right}}
+			if VerifyInclusion(root, proof, digest) {
+				t.Errorf("Forged proof with %d leaves verifies digest %s", leafCount, digest)
+			}
+		}
+	}
+
+	valid, err := ProveInclusion(manifest, manifest.Files[1].Name)
+	if err != nil {
+		t.Fatalf("ProveInclusion failed: %v", err)
+	}
+	for name, tamper := range map[string]func(proof *InclusionProof){
+		"extra sibling":   func(proof *InclusionProof) { proof.Path = append(proof.Path, right) },
+		"missing sibling": func(proof *InclusionProof) { proof.Path = proof.Path[:1] },
+		"short sibling":   func(proof *InclusionProof) { proof.Path = []string{proof.Path[0][:32], proof.Path[1]} },
+		"wrong count":     func(proof *InclusionProof) { proof.LeafCount = 5 },
+		"out of range":    func(proof *InclusionProof) { proof.Index, proof.LeafCount = 4, 4 },
+		"no count":        func(proof *InclusionProof) { proof.LeafCount = 0 },
+	} {
+		proof := *valid
+		proof.Path = append([]string{}, valid.Path...)
+		tamper(&proof)
+		if VerifyInclusion(root, &proof, manifest.Files[1].Digest["sha256"]) {
+			t.Errorf("Proof with %s verifies", name)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package dir
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+)
+
+// mmapThreshold is the size from which files are memory-mapped when the
+// UseMmap option is set. Mapping smaller files costs more than reading
+// them.
+const mmapThreshold = 64 << 20
+
+// mmapChunk is the amount of mapped memory hashed between checks of the
+// context.
+const mmapChunk = 4 << 20
+
+// mapFileReader memory-maps f when it is a file of at least mmapThreshold
+// bytes and returns a reader over the mapping and the function releasing
+// it. It returns a nil reader when the file is not mapped, either because
+// it is too small or mmap is not available, so it is streamed instead.
+func mapFileReader(ctx context.Context, f io.Reader) (*mappedReader, func() error) {
+	file, ok := f.(*os.File)
+	if !ok {
+		return nil, nil
+	}
+	info, err := file.Stat()
+	if err != nil || !info.Mode().IsRegular() || info.Size() < mmapThreshold {
+		return nil, nil
+	}
+
+	data, unmap, err := mmapFile(file, info.Size())
+	if err != nil {
+		return nil, nil
+	}
+	return &mappedReader{ctx: ctx, data: data}, unmap
+}
+
+// mappedReader reads a memory-mapped file. Hashers get the mapped memory
+// written to them directly through WriteTo, without copying it. Like a
+// contextReader, it fails once its context is done.
+type mappedReader struct {
+	ctx  context.Context
+	data []byte
+	off  int
+}
+
+func (mr *mappedReader) Read(p []byte) (int, error) {
+	if err := mr.ctx.Err(); err != nil {
+		return 0, fmt.Errorf("serialization canceled: %w", err)
+	}
+	if mr.off >= len(mr.data) {
+		return 0, io.EOF
+	}
+	n := copy(p, mr.data[mr.off:])
+	mr.off += n
+	return n, nil
+}
+
+// WriteTo writes the rest of the mapping to w in chunks of mmapChunk
+// bytes, checking the context between them.
+func (mr *mappedReader) WriteTo(w io.Writer) (int64, error) {
+	var written int64
+	for mr.off < len(mr.data) {
+		if err := mr.ctx.Err(); err != nil {
+			return written, fmt.Errorf("serialization canceled: %w", err)
+		}
+		end := min(mr.off+mmapChunk, len(mr.data))
+		n, err := w.Write(mr.data[mr.off:end])
+		mr.off += n
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+func (mr *mappedReader) bytesRead() int64 { return int64(mr.off) }
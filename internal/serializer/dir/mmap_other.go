@@ -0,0 +1,16 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !unix
+
+package dir
+
+import (
+	"errors"
+	"os"
+)
+
+// mmapFile is not available on this platform, files are always streamed.
+func mmapFile(*os.File, int64) ([]byte, func() error, error) {
+	return nil, nil, errors.ErrUnsupported
+}
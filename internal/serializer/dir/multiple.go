@@ -0,0 +1,114 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package dir
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+
+	intoto "github.com/in-toto/attestation/go/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// SerializeMultiple serializes several models into a single manifest, so
+// one root digest (and one signature) covers all of them. The keys of
+// roots are slash-separated prefixes and the values the model paths; the
+// files of every model are recorded under its prefix, as in
+// "<prefix>/<name>". Prefixes must be valid relative paths, using forward
+// slashes on every platform, and none can be nested in another. The
+// manifest has no model name. ExternalFiles and PostHash apply once, to
+// the combined manifest.
+func (s *Serializer) SerializeMultiple(roots map[string]string) (*Manifest, error) {
+	ctx := withStats(context.Background())
+
+	prefixes := make([]string, 0, len(roots))
+	for prefix := range roots {
+		if prefix == "." || !fs.ValidPath(prefix) {
+			return nil, fmt.Errorf("invalid model prefix %q", prefix)
+		}
+		if strings.Contains(prefix, "\\") {
+			return nil, fmt.Errorf("model prefix %q must use forward slashes", prefix)
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+	for i := 1; i < len(prefixes); i++ {
+		for _, previous := range prefixes[:i] {
+			if strings.HasPrefix(prefixes[i], previous+"/") {
+				return nil, fmt.Errorf("model prefix %q is nested in %q", prefixes[i], previous)
+			}
+		}
+	}
+
+	var fileDescriptors []*intoto.ResourceDescriptor
+	for _, prefix := range prefixes {
+		manifest, err := s.serializeModel(ctx, roots[prefix])
+		if err != nil {
+			return nil, fmt.Errorf("serializing %s: %w", prefix, err)
+		}
+		for _, file := range manifest.Files {
+			file.Name = prefix + "/" + file.Name
+			fileDescriptors = append(fileDescriptors, file)
+		}
+	}
+
+	manifest, err := s.newManifest("", fileDescriptors)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.finishManifest(ctx, manifest); err != nil {
+		return nil, err
+	}
+	return manifest, statsFrom(ctx).partialErr()
+}
+
+// MergeManifests combines manifests produced by separate serializations
+// of the parts of a model (its weights and its tokenizer, for example)
+// into a single manifest named name, whose root digest is the one of the
+// model serialized at once. File names are kept as recorded, so the parts
+// must use the names the whole model would. The manifests must share
+// their hash algorithm, root digest mode, digest encoding and
+// MetadataInDigest; a file name found in more than one of them fails
+// with ErrDuplicateName. Files are copied, the merged manifest does not
+// share them with its parts, and the Excluded lists are concatenated.
+func MergeManifests(name string, manifests ...*Manifest) (*Manifest, error) {
+	merged := &Manifest{ModelName: name}
+	if len(manifests) > 0 {
+		merged.HashAlgorithm = manifests[0].HashAlgorithm
+		merged.RootDigestMode = manifests[0].RootDigestMode
+		merged.DigestEncoding = manifests[0].DigestEncoding
+		merged.MetadataInDigest = manifests[0].MetadataInDigest
+	}
+
+	origins := map[string]int{}
+	for i, manifest := range manifests {
+		switch {
+		case manifest.algorithm() != merged.algorithm():
+			return nil, fmt.Errorf("manifest %d uses hash algorithm %s, expected %s", i, manifest.algorithm(), merged.algorithm())
+		case manifest.rootDigestMode() != merged.rootDigestMode():
+			return nil, fmt.Errorf("manifest %d uses root digest mode %s, expected %s", i, manifest.rootDigestMode(), merged.rootDigestMode())
+		case manifest.digestEncoding() != merged.digestEncoding():
+			return nil, fmt.Errorf("manifest %d uses digest encoding %s, expected %s", i, manifest.digestEncoding(), merged.digestEncoding())
+		case manifest.MetadataInDigest != merged.MetadataInDigest:
+			return nil, fmt.Errorf("manifest %d has MetadataInDigest %t, expected %t", i, manifest.MetadataInDigest, merged.MetadataInDigest)
+		}
+
+		for _, file := range manifest.Files {
+			if previous, ok := origins[file.GetName()]; ok {
+				return nil, fmt.Errorf("%w: %q is in manifests %d and %d", ErrDuplicateName, file.GetName(), previous, i)
+			}
+			origins[file.GetName()] = i
+			merged.Files = append(merged.Files, proto.Clone(file).(*intoto.ResourceDescriptor))
+		}
+		merged.Excluded = append(merged.Excluded, manifest.Excluded...)
+	}
+
+	sort.Slice(merged.Files, func(i, j int) bool {
+		return merged.Files[i].Name < merged.Files[j].Name
+	})
+	return merged, nil
+}
@@ -0,0 +1,141 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package dir
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/carabiner-dev/model-signing/internal/serializer/options"
+)
+
+func TestSerializeMultiple(t *testing.T) {
+	encoderDir, encoder := newTestManifest(t)
+	decoderDir, _ := newTestManifest(t)
+	if err := os.WriteFile(filepath.Join(decoderDir, "decoder.bin"), []byte("decoder"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	s := New(options.Default())
+	decoder, err := s.Serialize(decoderDir)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+
+	manifest, err := s.SerializeMultiple(map[string]string{
+		"models/encoder": encoderDir,
+		"models/decoder": decoderDir,
+	})
+	if err != nil {
+		t.Fatalf("SerializeMultiple failed: %v", err)
+	}
+
+	if manifest.ModelName != "" {
+		t.Errorf("Expected no model name, got %s", manifest.ModelName)
+	}
+	if len(manifest.Files) != len(encoder.Files)+len(decoder.Files) {
+		t.Fatalf("Expected %d files, got %d", len(encoder.Files)+len(decoder.Files), len(manifest.Files))
+	}
+	for i, file := range decoder.Files {
+		got := manifest.Files[i]
+		if got.Name != "models/decoder/"+file.Name || got.Digest["sha256"] != file.Digest["sha256"] {
+			t.Errorf("Expected models/decoder/%s, got %v", file.Name, got)
+		}
+	}
+	for i, file := range encoder.Files {
+		got := manifest.Files[len(decoder.Files)+i]
+		if got.Name != "models/encoder/"+file.Name || got.Digest["sha256"] != file.Digest["sha256"] {
+			t.Errorf("Expected models/encoder/%s, got %v", file.Name, got)
+		}
+	}
+
+	// The root digest spans both models
+	digest, err := ComputeRootDigest(manifest)
+	if err != nil {
+		t.Fatalf("ComputeRootDigest failed: %v", err)
+	}
+	single, err := ComputeRootDigest(encoder)
+	if err != nil {
+		t.Fatalf("ComputeRootDigest failed: %v", err)
+	}
+	if digest == single {
+		t.Error("Expected the bundle root digest to differ from a single model")
+	}
+
+	for _, roots := range []map[string]string{
+		{"a": encoderDir, "a/b": decoderDir},
+		{"../a": encoderDir},
+		{"": encoderDir},
+		{"a": filepath.Join(encoderDir, "missing")},
+	} {
+		if _, err := s.SerializeMultiple(roots); err == nil {
+			t.Errorf("Expected error for roots %v", roots)
+		}
+	}
+
+	if _, err := s.SerializeMultiple(map[string]string{"a": encoderDir, "a-b": decoderDir}); err != nil {
+		t.Errorf("Expected sibling prefixes to be accepted, got %v", err)
+	}
+}
+
+func TestMergeManifests(t *testing.T) {
+	tempDir, whole := newTestManifest(t)
+
+	// The model serialized in two parts
+	weights, err := NewWithOptions(options.WithIncludePatterns("*.bin")).Serialize(tempDir)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	config, err := NewWithOptions(options.WithIgnoreExtensions(".bin")).Serialize(tempDir)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+
+	merged, err := MergeManifests("model", config, weights)
+	if err != nil {
+		t.Fatalf("MergeManifests failed: %v", err)
+	}
+	if merged.ModelName != "model" {
+		t.Errorf("Expected model name model, got %s", merged.ModelName)
+	}
+	if diff := Compare(whole, merged); !diff.Empty() {
+		t.Errorf("Merged manifest differs from the whole model: %+v", diff)
+	}
+	for i, file := range merged.Files {
+		if file.Name != whole.Files[i].Name {
+			t.Errorf("File %d: expected %s, got %s", i, whole.Files[i].Name, file.Name)
+		}
+	}
+	wholeDigest, err := ComputeRootDigest(whole)
+	if err != nil {
+		t.Fatalf("ComputeRootDigest failed: %v", err)
+	}
+	mergedDigest, err := ComputeRootDigest(merged)
+	if err != nil {
+		t.Fatalf("ComputeRootDigest failed: %v", err)
+	}
+	if mergedDigest != wholeDigest {
+		t.Errorf("Expected root digest %s, got %s", wholeDigest, mergedDigest)
+	}
+
+	// The parts are not modified
+	merged.Files[0].Name = "renamed"
+	if config.Files[0].Name != "config.json" {
+		t.Errorf("Merging shared the files of the parts")
+	}
+
+	if _, err := MergeManifests("model", whole, weights); !errors.Is(err, ErrDuplicateName) {
+		t.Errorf("Expected ErrDuplicateName, got %v", err)
+	}
+
+	sha512, err := NewWithOptions(options.WithHashAlgorithm("sha512"), options.WithIgnoreExtensions(".bin")).Serialize(tempDir)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	if _, err := MergeManifests("model", weights, sha512); err == nil {
+		t.Error("Expected manifests of different algorithms not to merge")
+	}
+}
@@ -0,0 +1,291 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package dir
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/carabiner-dev/model-signing/internal/serializer/options"
+	intoto "github.com/in-toto/attestation/go/v1"
+)
+
+const (
+	// mediaTypeOCIManifest is the media type of OCI image manifests.
+	mediaTypeOCIManifest = "application/vnd.oci.image.manifest.v1+json"
+
+	// annotationRefName is the OCI annotation naming a manifest of an
+	// image layout index.
+	annotationRefName = "org.opencontainers.image.ref.name"
+
+	// annotationTitle is the OCI annotation ORAS records the file name of
+	// a layer under.
+	annotationTitle = "org.opencontainers.image.title"
+
+	// annotationUnpack is the ORAS annotation marking layers holding a
+	// packed directory.
+	annotationUnpack = "io.deis.oras.content.unpack"
+
+	// whiteoutPrefix marks the tar entries of a layer deleting a file of
+	// the layers below.
+	whiteoutPrefix = ".wh."
+
+	// whiteoutOpaque is the tar entry of a layer hiding the whole contents
+	// of its directory in the layers below.
+	whiteoutOpaque = ".wh..wh..opq"
+)
+
+// ociDescriptor is the OCI content descriptor.
+type ociDescriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// ociIndex is the OCI image index, as found in the index.json file of an
+// image layout.
+type ociIndex struct {
+	Manifests []ociDescriptor `json:"manifests"`
+}
+
+// ociManifest is the OCI image manifest.
+type ociManifest struct {
+	MediaType string          `json:"mediaType"`
+	Layers    []ociDescriptor `json:"layers"`
+}
+
+// SerializeOCILayout serializes the model packaged as the OCI artifact
+// ref of the OCI image layout at layoutPath, without extracting it. ref
+// is the name the manifest has in the layout index or its digest, and
+// may be empty when the index holds a single manifest.
+//
+// The layers are read in order as the model tree they extract to, so the
+// manifest is the one Serialize produces for that tree. Layers named with
+// a title annotation, as ORAS pushes files, are files of that name.
+// Other layers, and the directories ORAS packs, are tar archives,
+// optionally gzip-compressed, applied as SerializeTar reads them, their
+// whiteout entries deleting the files of the layers below. Every blob is
+// checked against its digest. ModelName is left empty.
+func SerializeOCILayout(layoutPath, ref string, opts *options.Options) (*Manifest, error) {
+	s := New(opts)
+	ctx := withStats(context.Background())
+
+	if _, err := os.Stat(filepath.Join(layoutPath, "oci-layout")); err != nil {
+		return nil, fmt.Errorf("%s is not an OCI image layout: %w", layoutPath, err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(layoutPath, "index.json"))
+	if err != nil {
+		return nil, fmt.Errorf("reading image index: %w", err)
+	}
+	var index ociIndex
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("decoding image index: %w", err)
+	}
+
+	desc, err := index.resolve(ref)
+	if err != nil {
+		return nil, err
+	}
+	if desc.MediaType != mediaTypeOCIManifest {
+		return nil, fmt.Errorf("unsupported media type %q of manifest %s", desc.MediaType, desc.Digest)
+	}
+
+	data, err = readBlob(layoutPath, desc)
+	if err != nil {
+		return nil, err
+	}
+	var manifest ociManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("decoding manifest %s: %w", desc.Digest, err)
+	}
+
+	model, err := s.newTarModel(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i, layer := range manifest.Layers {
+		if err := model.readLayer(layoutPath, layer, i); err != nil {
+			return nil, fmt.Errorf("reading layer %s: %w", layer.Digest, err)
+		}
+	}
+	return model.manifest()
+}
+
+// resolve returns the descriptor of the manifest ref of the index.
+func (index *ociIndex) resolve(ref string) (ociDescriptor, error) {
+	if ref == "" {
+		if len(index.Manifests) != 1 {
+			return ociDescriptor{}, fmt.Errorf("image index holds %d manifests, a reference is needed", len(index.Manifests))
+		}
+		return index.Manifests[0], nil
+	}
+	for _, desc := range index.Manifests {
+		if desc.Annotations[annotationRefName] == ref || desc.Digest == ref {
+			return desc, nil
+		}
+	}
+	return ociDescriptor{}, fmt.Errorf("reference %q not found in image index", ref)
+}
+
+// readLayer reads the blob of the layer into the model.
+func (m *tarModel) readLayer(layoutPath string, layer ociDescriptor, index int) error {
+	blob, err := openBlob(layoutPath, layer)
+	if err != nil {
+		return err
+	}
+	defer blob.Close() //nolint:errcheck
+
+	if title := layer.Annotations[annotationTitle]; title != "" && layer.Annotations[annotationUnpack] != "true" {
+		name, err := tarEntryName(title)
+		if err != nil {
+			return err
+		}
+		hdr := &tar.Header{Name: name, Typeflag: tar.TypeReg, Size: layer.Size, Mode: 0o644}
+		if err := m.addEntry(name, hdr, blob, index); err != nil {
+			return err
+		}
+		return blob.verify()
+	}
+
+	br := bufio.NewReader(blob)
+	var r io.Reader = br
+	magic, err := br.Peek(4)
+	switch {
+	case len(magic) >= 2 && magic[0] == 0x1f && magic[1] == 0x8b:
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return fmt.Errorf("reading gzip stream: %w", err)
+		}
+		defer zr.Close() //nolint:errcheck
+		r = zr
+	case bytes.Equal(magic, []byte{0x28, 0xb5, 0x2f, 0xfd}):
+		return fmt.Errorf("unsupported zstd compression of media type %q", layer.MediaType)
+	case err != nil && err != io.EOF:
+		return err
+	}
+
+	if err := m.readTar(tar.NewReader(r), index, true); err != nil {
+		return err
+	}
+	return blob.verify()
+}
+
+// whiteout applies the whiteout entry name of the given layer, removing
+// the entries it hides from the layers below.
+func (m *tarModel) whiteout(name string, layer int) {
+	dir, base := path.Dir(name), path.Base(name)
+	target := path.Join(dir, strings.TrimPrefix(base, whiteoutPrefix))
+	hidden := func(entry string) bool {
+		if m.layers[entry] >= layer {
+			return false
+		}
+		if base == whiteoutOpaque {
+			return dir == "." || strings.HasPrefix(entry, dir+"/")
+		}
+		return entry == target || strings.HasPrefix(entry, target+"/")
+	}
+	for entry := range m.special {
+		if hidden(entry) {
+			delete(m.special, entry)
+		}
+	}
+	for entry := range m.symlinks {
+		if hidden(entry) {
+			delete(m.symlinks, entry)
+		}
+	}
+	for entry := range m.files {
+		if hidden(entry) {
+			delete(m.files, entry)
+			delete(m.infos, entry)
+			delete(m.ignoreFiles, entry)
+		}
+	}
+}
+
+// ociBlob reads a blob of an image layout, hashing it to check it
+// against its descriptor once read.
+type ociBlob struct {
+	io.Reader
+	f    *os.File
+	h    hash.Hash
+	desc ociDescriptor
+}
+
+// ociDigestAlgorithms are the digest algorithms registered by the OCI
+// image spec, the only ones blobs can be addressed with.
+var ociDigestAlgorithms = []intoto.HashAlgorithm{intoto.AlgorithmSHA256, intoto.AlgorithmSHA512}
+
+// openBlob opens the blob of desc in the image layout at layoutPath.
+func openBlob(layoutPath string, desc ociDescriptor) (*ociBlob, error) {
+	algo, encoded, ok := strings.Cut(desc.Digest, ":")
+	if !ok || !slices.Contains(ociDigestAlgorithms, intoto.HashAlgorithm(algo)) {
+		return nil, fmt.Errorf("unsupported blob digest %q", desc.Digest)
+	}
+	h := newHasher(intoto.HashAlgorithm(algo))
+	if len(encoded) != hex.EncodedLen(h.Size()) || strings.ToLower(encoded) != encoded {
+		return nil, fmt.Errorf("unsupported blob digest %q", desc.Digest)
+	}
+	if _, err := hex.DecodeString(encoded); err != nil {
+		return nil, fmt.Errorf("unsupported blob digest %q", desc.Digest)
+	}
+
+	f, err := os.Open(filepath.Join(layoutPath, "blobs", algo, encoded))
+	if err != nil {
+		return nil, fmt.Errorf("opening blob: %w", err)
+	}
+	return &ociBlob{Reader: io.TeeReader(f, h), f: f, h: h, desc: desc}, nil
+}
+
+// readBlob returns the contents of the blob of desc in the image layout
+// at layoutPath, checked against its digest.
+func readBlob(layoutPath string, desc ociDescriptor) ([]byte, error) {
+	blob, err := openBlob(layoutPath, desc)
+	if err != nil {
+		return nil, err
+	}
+	defer blob.Close() //nolint:errcheck
+
+	data, err := io.ReadAll(blob)
+	if err != nil {
+		return nil, fmt.Errorf("reading blob %s: %w", desc.Digest, err)
+	}
+	if err := blob.verify(); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// verify reads the rest of the blob and checks its size and digest.
+func (b *ociBlob) verify() error {
+	if _, err := io.Copy(io.Discard, b); err != nil {
+		return fmt.Errorf("reading blob %s: %w", b.desc.Digest, err)
+	}
+	info, err := b.f.Stat()
+	if err != nil {
+		return fmt.Errorf("reading blob %s: %w", b.desc.Digest, err)
+	}
+	_, encoded, _ := strings.Cut(b.desc.Digest, ":")
+	if info.Size() != b.desc.Size || hex.EncodeToString(b.h.Sum(nil)) != encoded {
+		return fmt.Errorf("blob %s does not match its descriptor", b.desc.Digest)
+	}
+	return nil
+}
+
+func (b *ociBlob) Close() error { return b.f.Close() }
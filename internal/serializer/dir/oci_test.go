@@ -0,0 +1,144 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package dir
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/carabiner-dev/model-signing/internal/serializer/options"
+	intoto "github.com/in-toto/attestation/go/v1"
+)
+
+// writeBlob stores data as a blob of the image layout at layoutPath and
+// returns its descriptor.
+func writeBlob(t *testing.T, layoutPath, mediaType string, data []byte, annotations map[string]string) ociDescriptor {
+	t.Helper()
+	sum := sha256.Sum256(data)
+	encoded := hex.EncodeToString(sum[:])
+	dir := filepath.Join(layoutPath, "blobs", "sha256")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("Failed to create blobs dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, encoded), data, 0644); err != nil {
+		t.Fatalf("Failed to write blob: %v", err)
+	}
+	return ociDescriptor{MediaType: mediaType, Digest: "sha256:" + encoded, Size: int64(len(data)), Annotations: annotations}
+}
+
+// writeLayout writes an image layout at layoutPath holding a manifest of
+// the given layers, named ref.
+func writeLayout(t *testing.T, layoutPath, ref string, layers []ociDescriptor) ociDescriptor {
+	t.Helper()
+	data, err := json.Marshal(ociManifest{MediaType: mediaTypeOCIManifest, Layers: layers})
+	if err != nil {
+		t.Fatalf("Failed to encode manifest: %v", err)
+	}
+	manifest := writeBlob(t, layoutPath, mediaTypeOCIManifest, data, map[string]string{annotationRefName: ref})
+
+	data, err = json.Marshal(ociIndex{Manifests: []ociDescriptor{manifest}})
+	if err != nil {
+		t.Fatalf("Failed to encode index: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(layoutPath, "index.json"), data, 0644); err != nil {
+		t.Fatalf("Failed to write index: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(layoutPath, "oci-layout"), []byte(`{"imageLayoutVersion":"1.0.0"}`), 0644); err != nil {
+		t.Fatalf("Failed to write oci-layout: %v", err)
+	}
+	return manifest
+}
+
+func TestSerializeOCILayout(t *testing.T) {
+	_, expected := newTestManifest(t)
+	expected.ModelName = ""
+
+	layoutPath, err := os.MkdirTemp("", "modeldigest-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(layoutPath)
+
+	// A file pushed by ORAS, a compressed base layer and a layer replacing
+	// config.json and deleting a stale file with whiteouts
+	layers := []ociDescriptor{
+		writeBlob(t, layoutPath, "application/vnd.oci.image.layer.v1.tar", []byte("weights"), map[string]string{annotationTitle: "model.bin"}),
+		writeBlob(t, layoutPath, "application/vnd.oci.image.layer.v1.tar+gzip", gzipData(t, buildTar(t, []tarEntry{
+			{name: "subdir/", typeflag: tar.TypeDir},
+			{name: "subdir/layer.bin", content: "layer", typeflag: tar.TypeReg},
+			{name: "config.json", content: `{"old": true}`, typeflag: tar.TypeReg},
+			{name: "stale/old.bin", content: "old", typeflag: tar.TypeReg},
+		}), gzip.DefaultCompression), nil),
+		writeBlob(t, layoutPath, "application/vnd.oci.image.layer.v1.tar", buildTar(t, []tarEntry{
+			{name: ".wh.config.json", typeflag: tar.TypeReg},
+			{name: "config.json", content: "{}", typeflag: tar.TypeReg},
+			{name: "stale/" + whiteoutOpaque, typeflag: tar.TypeReg},
+		}), nil),
+	}
+	manifest := writeLayout(t, layoutPath, "v1", layers)
+
+	for _, ref := range []string{"", "v1", manifest.Digest} {
+		got, err := SerializeOCILayout(layoutPath, ref, options.Default())
+		if err != nil {
+			t.Fatalf("SerializeOCILayout(%q) failed: %v", ref, err)
+		}
+		if diff := Compare(expected, got); !diff.Empty() {
+			t.Errorf("SerializeOCILayout(%q) differs from the extracted tree: %v", ref, diff)
+		}
+	}
+
+	if _, err := SerializeOCILayout(layoutPath, "v2", options.Default()); err == nil {
+		t.Error("Expected error for a missing reference")
+	}
+
+	// Tampered blobs are detected
+	blob := filepath.Join(layoutPath, "blobs", "sha256", layers[0].Digest[len("sha256:"):])
+	if err := os.WriteFile(blob, []byte("WEIGHTS"), 0644); err != nil {
+		t.Fatalf("Failed to tamper with blob: %v", err)
+	}
+	if _, err := SerializeOCILayout(layoutPath, "v1", options.Default()); err == nil {
+		t.Error("Expected error for a tampered blob")
+	}
+}
+
+func TestOCIBlobDigests(t *testing.T) {
+	layoutPath := t.TempDir()
+	data := []byte("weights")
+
+	// Blobs stored under the name of every algorithm, hashed with it
+	for _, algo := range []string{"sha256", "sha512", "sha384", "sha3_384", "blake3"} {
+		h := newHasher(intoto.HashAlgorithm(algo))
+		h.Write(data)
+		encoded := hex.EncodeToString(h.Sum(nil))
+		dir := filepath.Join(layoutPath, "blobs", algo)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("Failed to create blobs dir: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, encoded), data, 0644); err != nil {
+			t.Fatalf("Failed to write blob: %v", err)
+		}
+
+		got, err := readBlob(layoutPath, ociDescriptor{Digest: algo + ":" + encoded, Size: int64(len(data))})
+		registered := algo == "sha256" || algo == "sha512"
+		switch {
+		case registered && err != nil:
+			t.Errorf("readBlob with %s failed: %v", algo, err)
+		case registered && string(got) != string(data):
+			t.Errorf("readBlob with %s returned %q", algo, got)
+		case !registered && err == nil:
+			t.Errorf("Expected an error for a %s blob digest", algo)
+		}
+	}
+
+	// A blob digest in a broken algorithm the hasher does not support
+	if _, err := readBlob(layoutPath, ociDescriptor{Digest: "md5:5ae8ab9d8f71a8fab3d4a0a4a2b0e4f4"}); err == nil {
+		t.Error("Expected an error for an md5 blob digest")
+	}
+}
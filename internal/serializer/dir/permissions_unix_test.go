@@ -0,0 +1,87 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build unix
+
+package dir
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/carabiner-dev/model-signing/internal/serializer/options"
+)
+
+func TestRecordPermissions(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "modeldigest-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	script := filepath.Join(tempDir, "convert.sh")
+	weights := filepath.Join(tempDir, "model.bin")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\n"), 0644); err != nil {
+		t.Fatalf("Failed to create script: %v", err)
+	}
+	if err := os.WriteFile(weights, []byte("weights"), 0644); err != nil {
+		t.Fatalf("Failed to create weights: %v", err)
+	}
+	if err := os.Chmod(script, 0755); err != nil {
+		t.Fatalf("Failed to chmod script: %v", err)
+	}
+	if err := os.Chmod(weights, 0640); err != nil {
+		t.Fatalf("Failed to chmod weights: %v", err)
+	}
+
+	opts := options.Default()
+	opts.RecordPermissions = true
+
+	manifest, err := New(opts).Serialize(tempDir)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+
+	for i, mode := range []string{"0755", "0640"} {
+		file := manifest.Files[i]
+		if got := file.Annotations.GetFields()[AnnotationMode].GetStringValue(); got != mode {
+			t.Errorf("Expected mode %s for %s, got %q", mode, file.Name, got)
+		}
+	}
+
+	// Modes are not part of the root digest
+	expected, err := ComputeDigest(tempDir, options.Default())
+	if err != nil {
+		t.Fatalf("ComputeDigest failed: %v", err)
+	}
+	digest, err := ComputeDigest(tempDir, opts)
+	if err != nil {
+		t.Fatalf("ComputeDigest failed: %v", err)
+	}
+	if digest != expected {
+		t.Errorf("Recording permissions changed the digest: %s != %s", digest, expected)
+	}
+
+	// Verify picks up the recorded modes even without the option set
+	if err := New(options.Default()).Verify(tempDir, manifest); err != nil {
+		t.Fatalf("Verify failed on unchanged model: %v", err)
+	}
+
+	if err := os.Chmod(weights, 0750); err != nil {
+		t.Fatalf("Failed to chmod weights: %v", err)
+	}
+
+	err = New(options.Default()).Verify(tempDir, manifest)
+	var verr *VerificationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("Expected VerificationError, got %v", err)
+	}
+	if len(verr.Modified) != 0 {
+		t.Errorf("Expected no content changes, got %v", verr.Modified)
+	}
+	if len(verr.Permissions) != 1 || verr.Permissions[0] != (FileMismatch{Name: "model.bin", Expected: "0640", Actual: "0750"}) {
+		t.Errorf("Expected model.bin permission change, got %v", verr.Permissions)
+	}
+}
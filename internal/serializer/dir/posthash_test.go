@@ -0,0 +1,115 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package dir
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/carabiner-dev/model-signing/internal/serializer/options"
+)
+
+func TestPostHash(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "modeldigest-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	for name, content := range map[string]string{
+		"model.bin":   "weights",
+		"config.json": "{}",
+		"extra.bin":   "untrusted",
+	} {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test file %s: %v", name, err)
+		}
+	}
+
+	reference, err := New(options.Default()).Serialize(tempDir)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	allowed := map[string]bool{}
+	for _, f := range reference.Files {
+		if f.Name != "extra.bin" {
+			allowed[f.Digest["sha256"]] = true
+		}
+	}
+
+	t.Run("Exclude", func(t *testing.T) {
+		seen := 0
+		opts := options.Default()
+		opts.PostHash = func(name, digest string) (bool, error) {
+			seen++
+			return allowed[digest], nil
+		}
+
+		manifest, err := New(opts).Serialize(tempDir)
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+
+		if seen != 3 {
+			t.Errorf("Expected PostHash to be called 3 times, got %d", seen)
+		}
+		if len(manifest.Files) != 2 {
+			t.Errorf("Expected 2 files, got %d", len(manifest.Files))
+		}
+		if len(manifest.Excluded) != 1 || manifest.Excluded[0] != "extra.bin" {
+			t.Errorf("Expected extra.bin to be reported as excluded, got %v", manifest.Excluded)
+		}
+	})
+
+	t.Run("Shards", func(t *testing.T) {
+		// extra.bin holds 9 bytes, split in shards of 4
+		for _, opts := range []*options.Options{
+			options.Default().Apply(options.WithShardSize(4)),
+			options.Default().Apply(options.WithMethod(options.ShardsMethod), options.WithShardSize(4)),
+		} {
+			var seen []string
+			opts.PostHash = func(name, digest string) (bool, error) {
+				seen = append(seen, name)
+				return name != ShardName("extra.bin", 4, 8), nil
+			}
+
+			manifest, err := New(opts).Serialize(tempDir)
+			if err != nil {
+				t.Fatalf("Serialize failed: %v", err)
+			}
+
+			for _, file := range manifest.Files {
+				if strings.HasPrefix(file.Name, "extra.bin") {
+					t.Errorf("Expected every shard of extra.bin to be excluded, found %s", file.Name)
+				}
+			}
+			if len(manifest.Files) < 2 {
+				t.Errorf("Expected the other files to be kept, got %v", manifestNames(manifest))
+			}
+			if len(manifest.Excluded) != 1 || manifest.Excluded[0] != "extra.bin" {
+				t.Errorf("Expected extra.bin to be reported as excluded once, got %v", manifest.Excluded)
+			}
+			// Shards after the rejected one are not checked
+			if slices.Contains(seen, ShardName("extra.bin", 8, 9)) {
+				t.Errorf("Unexpected PostHash call for the shards of a rejected file: %v", seen)
+			}
+		}
+	})
+
+	t.Run("Abort", func(t *testing.T) {
+		errDenied := errors.New("denied")
+		opts := options.Default()
+		opts.PostHash = func(name, digest string) (bool, error) {
+			return false, errDenied
+		}
+
+		if _, err := New(opts).Serialize(tempDir); !errors.Is(err, errDenied) {
+			t.Errorf("Expected PostHash error to abort, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,68 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build unix
+
+package dir
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/carabiner-dev/model-signing/internal/serializer/options"
+)
+
+// TestDigestInvariantToUmask writes the same tree under different umasks
+// and checks the content-only digest does not change.
+func TestDigestInvariantToUmask(t *testing.T) {
+	testFiles := map[string]string{
+		"model.bin":        "model weights",
+		"config.json":      `{"version": "1.0"}`,
+		"subdir/layer.bin": "layer data",
+		"scripts/convert":  "#!/bin/sh\n",
+	}
+
+	var digests []string
+	for _, umask := range []int{0o022, 0o077, 0o002} {
+		tempDir, err := os.MkdirTemp("", "umask-model-*")
+		if err != nil {
+			t.Fatalf("Failed to create temp dir: %v", err)
+		}
+		defer os.RemoveAll(tempDir)
+
+		old := syscall.Umask(umask)
+		for path, content := range testFiles {
+			fullPath := filepath.Join(tempDir, path)
+			if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+				syscall.Umask(old)
+				t.Fatalf("Failed to create dir for %s: %v", path, err)
+			}
+			if err := os.WriteFile(fullPath, []byte(content), 0666); err != nil {
+				syscall.Umask(old)
+				t.Fatalf("Failed to write %s: %v", path, err)
+			}
+		}
+		syscall.Umask(old)
+
+		// Permission bits beyond the umask must not matter either
+		if umask == 0o002 {
+			if err := os.Chmod(filepath.Join(tempDir, "scripts", "convert"), 0755); err != nil {
+				t.Fatalf("Failed to chmod: %v", err)
+			}
+		}
+
+		digest, err := ComputeDigest(tempDir, options.Default())
+		if err != nil {
+			t.Fatalf("ComputeDigest failed with umask %o: %v", umask, err)
+		}
+		digests = append(digests, digest)
+	}
+
+	for i := 1; i < len(digests); i++ {
+		if digests[i] != digests[0] {
+			t.Errorf("Digest %d differs: %s != %s", i, digests[i], digests[0])
+		}
+	}
+}
@@ -0,0 +1,334 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package dir
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/carabiner-dev/model-signing/internal/serializer/ignore"
+)
+
+// ignoreRules decides which paths of the model are skipped.
+type ignoreRules struct {
+	// paths matches the IgnorePaths option and the git paths.
+	paths *ignore.Matcher
+
+	// gitignore accumulates the patterns of the .gitignore files found
+	// during the walk. It is nil unless RespectGitignore is set.
+	gitignore *ignore.Matcher
+
+	// ignoreFile holds the patterns of the IgnoreFileName file of the
+	// model root. It is nil unless RespectIgnoreFile is set.
+	ignoreFile *ignore.Matcher
+
+	// skipHidden ignores every path with a component starting with a dot.
+	skipHidden bool
+
+	// extensions are the file name suffixes ignored, lowercase when
+	// ignoreCase is set.
+	extensions []string
+	ignoreCase bool
+
+	// include matches the IncludePatterns, nil when there are none.
+	include *ignore.Matcher
+
+	// filter is the FilterFunc option.
+	filter func(path string, info fs.FileInfo) (bool, error)
+
+	// maxFileSize ignores the regular files larger than it when positive.
+	maxFileSize int64
+
+	// onIgnore, when set, is called with every path ignored.
+	onIgnore func(name string, isDir bool)
+}
+
+// newIgnoreRules builds the ignore rules of the model at modelPath from
+// the IgnorePaths and, if IgnoreGitPaths is set, the VCS paths.
+func (s *Serializer) newIgnoreRules(modelPath string) (*ignoreRules, error) {
+	// Build complete ignore list
+	ignorePaths := make([]string, len(s.opts.IgnorePaths))
+	copy(ignorePaths, s.opts.IgnorePaths)
+
+	if s.opts.IgnoreGitPaths {
+		ignorePaths = append(ignorePaths, s.vcsPaths()...)
+	}
+
+	matcher, err := s.ignoreMatcher(modelPath, ignorePaths)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := &ignoreRules{
+		paths:       matcher,
+		skipHidden:  s.opts.SkipHidden,
+		ignoreCase:  s.opts.CaseInsensitiveIgnores,
+		filter:      s.opts.FilterFunc,
+		maxFileSize: s.opts.MaxFileSize,
+	}
+	if len(s.opts.IncludePatterns) > 0 {
+		rules.include = &ignore.Matcher{IgnoreCase: s.opts.CaseInsensitiveIgnores}
+		patterns := make([]string, 0, len(s.opts.IncludePatterns))
+		for _, pattern := range s.opts.IncludePatterns {
+			patterns = append(patterns, filepath.ToSlash(pattern))
+		}
+		if err := rules.include.Add("", patterns); err != nil {
+			return nil, fmt.Errorf("parsing include patterns: %w", err)
+		}
+	}
+	for _, ext := range s.opts.IgnoreExtensions {
+		if ext == "" {
+			continue
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		if rules.ignoreCase {
+			ext = strings.ToLower(ext)
+		}
+		rules.extensions = append(rules.extensions, ext)
+	}
+	if s.opts.RespectGitignore {
+		rules.gitignore = &ignore.Matcher{IgnoreCase: s.opts.CaseInsensitiveIgnores}
+	}
+	if s.opts.RespectIgnoreFile {
+		rules.ignoreFile = &ignore.Matcher{IgnoreCase: s.opts.CaseInsensitiveIgnores}
+	}
+	return rules, nil
+}
+
+// match returns true if the slash-separated path relative to the model
+// root is ignored. The ignore paths and the ignore file always win over
+// .gitignore files: a negated .gitignore pattern cannot re-include an
+// ignored path, and neither can re-include a hidden path when SkipHidden
+// is set.
+func (r *ignoreRules) match(name string, isDir bool) bool {
+	ignored := (r.skipHidden && isHidden(name)) || (!isDir && r.matchExtension(name)) ||
+		r.paths.Match(name, isDir) || r.ignoreFile.Match(name, isDir) || r.gitignore.Match(name, isDir)
+	if ignored && r.onIgnore != nil {
+		r.onIgnore(name, isDir)
+	}
+	return ignored
+}
+
+// keep applies the MaxFileSize and FilterFunc options to the entry name
+// the ignore rules kept, stat returning its file info. It returns true
+// when neither is set, and for the model root.
+func (r *ignoreRules) keep(name string, stat func() (fs.FileInfo, error)) (bool, error) {
+	if (r.filter == nil && r.maxFileSize <= 0) || name == "." {
+		return true, nil
+	}
+	info, err := stat()
+	if err != nil {
+		return false, err
+	}
+	keep := r.maxFileSize <= 0 || !info.Mode().IsRegular() || info.Size() <= r.maxFileSize
+	if keep && r.filter != nil {
+		keep, err = r.filter(name, info)
+		if err != nil {
+			return false, fmt.Errorf("filtering %s: %w", name, err)
+		}
+	}
+	if !keep && r.onIgnore != nil {
+		r.onIgnore(name, info.IsDir())
+	}
+	return keep, nil
+}
+
+// included returns true if the file name matches the IncludePatterns, or
+// if there are none. Directory markers are always included.
+func (r *ignoreRules) included(name string) bool {
+	if r.include == nil || isDirMarker(name) || r.include.Match(name, false) {
+		return true
+	}
+	if r.onIgnore != nil {
+		r.onIgnore(name, false)
+	}
+	return false
+}
+
+// yieldIncluded wraps the yield function of a walk to drop the files not
+// matching the IncludePatterns. Including runs after the ignore rules
+// were applied, so a file must be included and not ignored.
+func (r *ignoreRules) yieldIncluded(yield func(name string) error) func(name string) error {
+	if r.include == nil {
+		return yield
+	}
+	return func(name string) error {
+		if !r.included(name) {
+			return nil
+		}
+		return yield(name)
+	}
+}
+
+// matchExtension returns true if the file name ends in one of the ignored
+// extensions.
+func (r *ignoreRules) matchExtension(name string) bool {
+	if r.ignoreCase {
+		name = strings.ToLower(name)
+	}
+	for _, ext := range r.extensions {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// isHidden returns true if a component of the slash-separated path starts
+// with a dot.
+func isHidden(name string) bool {
+	for component := range strings.SplitSeq(name, "/") {
+		if strings.HasPrefix(component, ".") && component != "." && component != ".." {
+			return true
+		}
+	}
+	return false
+}
+
+// IgnoreFileName is the name of the ignore file read from the model root
+// with the RespectIgnoreFile option.
+const IgnoreFileName = ".modelsigningignore"
+
+// loadIgnoreFiles reads the ignore files of the directory dir (relative to
+// the model root) through readFile: its .gitignore file, scoped to it,
+// when RespectGitignore is set and, for the model root, the
+// IgnoreFileName file when RespectIgnoreFile is set. Missing files are
+// skipped.
+func (r *ignoreRules) loadIgnoreFiles(dir string, readFile func(name string) ([]byte, error)) error {
+	if dir == "." {
+		if err := loadPatterns(r.ignoreFile, dir, IgnoreFileName, readFile); err != nil {
+			return err
+		}
+	}
+	return loadPatterns(r.gitignore, dir, ".gitignore", readFile)
+}
+
+// loadPatterns reads the file of the directory dir through readFile and
+// adds its patterns to the matcher, scoped to dir. It is a no-op when the
+// matcher is nil or the file does not exist.
+func loadPatterns(matcher *ignore.Matcher, dir, file string, readFile func(name string) ([]byte, error)) error {
+	if matcher == nil {
+		return nil
+	}
+
+	name := path.Join(dir, file)
+	data, err := readFile(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", name, err)
+	}
+
+	base := dir
+	if base == "." {
+		base = ""
+	}
+	if err := matcher.Add(base, strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")); err != nil {
+		return fmt.Errorf("parsing %s: %w", name, err)
+	}
+	return nil
+}
+
+// gitPaths returns the default git-related paths to ignore, as patterns
+// matching them at any depth so the .git directories of submodules and
+// nested checkouts are ignored too.
+func gitPaths() []string {
+	return []string{"**/.git", "**/.gitignore", "**/.gitattributes", "**/.github"}
+}
+
+// vcsPaths returns the version control paths ignored with IgnoreGitPaths:
+// the VCSIgnorePaths option if set, the git paths otherwise.
+func (s *Serializer) vcsPaths() []string {
+	if s.opts.VCSIgnorePaths != nil {
+		return s.opts.VCSIgnorePaths
+	}
+	return gitPaths()
+}
+
+// ignoreMatcher compiles the ignore paths into a matcher for the model at
+// modelPath. Relative entries are always relative to the model root, and
+// absolute ones are made relative to it. Absolute entries and those
+// without wildcards are plain paths anchored at the root, matching the
+// file or directory of that name and everything below it, component by
+// component and character for character; a trailing slash restricts them
+// to directories. A leading "./" is dropped, anchoring wildcard entries at
+// the root too. Entries naming the root itself are an error, and so are
+// entries pointing outside of the model unless AllowExternalIgnorePaths
+// is set and they are skipped.
+func (s *Serializer) ignoreMatcher(modelPath string, ignorePaths []string) (*ignore.Matcher, error) {
+	patterns := make([]string, 0, len(ignorePaths))
+	for _, entry := range ignorePaths {
+		pattern := entry
+		negate := strings.HasPrefix(pattern, "!")
+		pattern = strings.TrimPrefix(pattern, "!")
+
+		// Absolute paths are plain paths made relative to the root
+		absolute := filepath.IsAbs(pattern)
+		dirOnly, anchored, external := false, false, false
+		if absolute {
+			// Models without a path, like file systems and archives,
+			// cannot hold absolute paths
+			relPath, err := filepath.Rel(modelPath, pattern)
+			external = err != nil
+			pattern, anchored = filepath.ToSlash(relPath), true
+		} else {
+			pattern = filepath.ToSlash(pattern)
+			dirOnly = strings.HasSuffix(pattern, "/")
+			for strings.HasPrefix(pattern, "./") {
+				pattern, anchored = strings.TrimLeft(pattern[2:], "/"), true
+			}
+		}
+
+		clean := path.Clean(pattern)
+		if external || isExternal(clean) {
+			if s.opts.AllowExternalIgnorePaths {
+				continue
+			}
+			return nil, fmt.Errorf("ignore path %q is outside of the model directory (use AllowExternalIgnorePaths option)", entry)
+		}
+		if clean == "." {
+			return nil, fmt.Errorf("ignore path %q is the model root", entry)
+		}
+
+		switch {
+		case absolute || !isPattern(pattern):
+			pattern = "/" + ignore.Escape(clean)
+			if dirOnly {
+				pattern += "/"
+			}
+		case anchored:
+			pattern = "/" + pattern
+		}
+
+		if negate {
+			pattern = "!" + pattern
+		}
+		patterns = append(patterns, pattern)
+	}
+
+	matcher := &ignore.Matcher{IgnoreCase: s.opts.CaseInsensitiveIgnores}
+	if err := matcher.Add("", patterns); err != nil {
+		return nil, fmt.Errorf("parsing ignore paths: %w", err)
+	}
+	return matcher, nil
+}
+
+// isExternal returns true if the clean, slash-separated relative path
+// points outside of the model root.
+func isExternal(rel string) bool {
+	return rel == ".." || strings.HasPrefix(rel, "../")
+}
+
+// isPattern returns true if the ignore entry uses wildcards rather than
+// being a plain path.
+func isPattern(entry string) bool {
+	return strings.ContainsAny(entry, "*?[")
+}
@@ -0,0 +1,68 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package dir
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/carabiner-dev/model-signing/internal/serializer/options"
+)
+
+// FuzzIgnorePaths checks that a plain ignore path matches itself and the
+// paths below it, component by component, and nothing else. With a
+// trailing slash it only matches itself as a directory.
+func FuzzIgnorePaths(f *testing.F) {
+	for _, seed := range []struct {
+		entry, name string
+		isDir       bool
+	}{
+		{"foo", "foo", false},
+		{"foo", "foobar", false},
+		{"foo", "foo/bar", false},
+		{"foo/bar", "foo/barbaz", true},
+		{"data", "database.bin", false},
+		{"data", "data-extra", true},
+		{"./data/", "data", true},
+		{"./data/", "data", false},
+		{"data/", "data/file", false},
+		{".//data", "data", false},
+		{"a//b", "a/b/c", false},
+		{"a/../b", "b", false},
+		{`back\slash`, `back\slash`, false},
+		{"space ", "space ", false},
+		{"#hash", "#hash", false},
+	} {
+		f.Add(seed.entry, seed.name, seed.isDir)
+	}
+
+	modelPath := filepath.Join(f.TempDir(), "model")
+	f.Fuzz(func(t *testing.T, entry, name string, isDir bool) {
+		// Only plain paths, inside the model, with a file-system-safe name
+		clean := path.Clean(entry)
+		if !utf8.ValidString(entry) || !utf8.ValidString(name) ||
+			entry == "" || isPattern(entry) || strings.HasPrefix(entry, "!") ||
+			strings.ContainsAny(entry+name, "\x00\r\n") ||
+			path.IsAbs(entry) || clean == "." || isExternal(clean) ||
+			name == "" || name != path.Clean(name) || path.IsAbs(name) || isExternal(name) || name == "." {
+			t.Skip()
+		}
+
+		s := NewWithOptions(options.WithIgnorePaths(entry), options.WithIgnoreGitPaths(false))
+		rules, err := s.newIgnoreRules(modelPath)
+		if err != nil {
+			t.Fatalf("newIgnoreRules(%q) failed: %v", entry, err)
+		}
+		ignored := rules.match(name, isDir)
+
+		dirOnly := strings.HasSuffix(entry, "/")
+		expected := (name == clean && (isDir || !dirOnly)) || strings.HasPrefix(name, clean+"/")
+		if ignored != expected {
+			t.Errorf("ignore path %q: expected %q ignored to be %v, got %v", entry, name, expected, ignored)
+		}
+	})
+}
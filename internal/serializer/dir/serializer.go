@@ -8,28 +8,40 @@
 package dir
 
 import (
-	"crypto/sha256"
-	"encoding/hex"
+	"context"
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
+	"time"
 
-	"github.com/carabiner-dev/hasher"
 	"github.com/carabiner-dev/model-signing/internal/serializer/options"
 	intoto "github.com/in-toto/attestation/go/v1"
+	"golang.org/x/text/unicode/norm"
 )
 
-// Manifest represents the serialized model with all file hashes.
-type Manifest struct {
-	ModelName string
-	Files     []*intoto.ResourceDescriptor
-}
+// ErrDuplicateName is returned when two files of a model are recorded
+// under the same name, which happens when their names only differ in
+// their Unicode normalization form.
+var ErrDuplicateName = errors.New("duplicate file name")
 
 // Serializer serializes a model directory and computes digests.
+//
+// A Serializer is safe for concurrent use: any number of Serialize (and
+// other) calls can share one, as long as its options are not modified
+// meanwhile. Callbacks in the options, like PostHash, may then be called
+// concurrently. Hashers and copy buffers are pooled across calls, so a
+// long-lived Serializer can be reused for many models.
 type Serializer struct {
-	opts *options.Options
+	opts  *options.Options
+	cache *digestCache
 }
 
 // New creates a new Serializer with the given options.
@@ -37,148 +49,430 @@ func New(opts *options.Options) *Serializer {
 	if opts == nil {
 		opts = options.Default()
 	}
-	return &Serializer{opts: opts}
+	return &Serializer{opts: opts, cache: newDigestCache(opts.CachePath)}
 }
 
-// gitPaths returns the default git-related paths to ignore.
-func gitPaths() []string {
-	return []string{".git", ".gitignore", ".gitattributes", ".github"}
+// NewWithOptions creates a new Serializer with the default options
+// modified by opts, e.g.
+//
+//	NewWithOptions(options.WithIgnoreGitPaths(false), options.WithAllowSymlinks(true))
+func NewWithOptions(opts ...options.Option) *Serializer {
+	return New(options.Default().Apply(opts...))
 }
 
-// shouldIgnore determines if a path should be ignored based on ignore rules.
-func (s *Serializer) shouldIgnore(path string, modelPath string, ignorePaths []string) (bool, error) {
-	// Get relative path from model root
-	relPath, err := filepath.Rel(modelPath, path)
-	if err != nil {
-		return false, err
+// algorithm returns the configured hash algorithm, defaulting to SHA256.
+func (s *Serializer) algorithm() intoto.HashAlgorithm {
+	if s.opts.HashAlgorithm == "" {
+		return intoto.AlgorithmSHA256
 	}
+	return s.opts.HashAlgorithm
+}
 
-	// Normalize path separators
-	relPath = filepath.ToSlash(relPath)
+// algorithms returns every algorithm recorded in the file descriptors:
+// the configured HashAlgorithm followed by the extra Algorithms, without
+// duplicates.
+func (s *Serializer) algorithms() []intoto.HashAlgorithm {
+	algos := []intoto.HashAlgorithm{s.algorithm()}
+	for _, algo := range s.opts.Algorithms {
+		if !slices.Contains(algos, algo) {
+			algos = append(algos, algo)
+		}
+	}
+	return algos
+}
 
-	// Check each ignore pattern
-	for _, ignore := range ignorePaths {
-		ignore = filepath.ToSlash(ignore)
+// Serialize traverses the model directory and creates a manifest with file hashes.
+// A modelPath pointing to a regular file serializes a single-file model:
+// the manifest lists that file, named after its base name. Under
+// ContinueOnError, files that cannot be hashed are left out and the
+// partial manifest is returned with an ErrPartial error.
+func (s *Serializer) Serialize(modelPath string) (*Manifest, error) {
+	return s.SerializeContext(context.Background(), modelPath)
+}
 
-		// If ignore path is relative, match against relative path
-		// If ignore path is absolute, resolve it to relative
-		var checkPath string
-		if filepath.IsAbs(ignore) {
-			checkRelPath, err := filepath.Rel(modelPath, ignore)
-			if err != nil || strings.HasPrefix(checkRelPath, "..") {
-				// Ignore path is outside model directory
-				continue
-			}
-			checkPath = filepath.ToSlash(checkRelPath)
-		} else {
-			checkPath = ignore
-		}
+// SerializeContext is like Serialize but stops walking and hashing the
+// model as soon as ctx is done, returning the context error wrapped.
+func (s *Serializer) SerializeContext(ctx context.Context, modelPath string) (*Manifest, error) {
+	ctx = withStats(ctx)
+	manifest, err := s.serializeModel(ctx, modelPath)
+	if err != nil {
+		return nil, err
+	}
 
-		// Check if path is under the ignore path
-		if relPath == checkPath || strings.HasPrefix(relPath, checkPath+"/") {
-			return true, nil
-		}
+	if err := s.finishManifest(ctx, manifest); err != nil {
+		return nil, err
 	}
 
-	return false, nil
+	return manifest, statsFrom(ctx).partialErr()
 }
 
-// Serialize traverses the model directory and creates a manifest with file hashes.
-func (s *Serializer) Serialize(modelPath string) (*Manifest, error) {
+// serializeModel returns the manifest of the model file or directory at
+// modelPath, before the external files and PostHash are applied.
+func (s *Serializer) serializeModel(ctx context.Context, modelPath string) (*Manifest, error) {
 	// Resolve absolute path
 	absPath, err := filepath.Abs(modelPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to resolve model path: %w", err)
 	}
 
-	// Build complete ignore list
-	ignorePaths := make([]string, len(s.opts.IgnorePaths))
-	copy(ignorePaths, s.opts.IgnorePaths)
+	if err := s.validateAlgorithms(); err != nil {
+		return nil, err
+	}
+	if err := s.validateSymlinkPolicy(); err != nil {
+		return nil, err
+	}
+	if err := s.validateMethod(); err != nil {
+		return nil, err
+	}
+	if err := s.validateDigestEncoding(); err != nil {
+		return nil, err
+	}
 
-	if s.opts.IgnoreGitPaths {
-		for _, gitPath := range gitPaths() {
-			ignorePaths = append(ignorePaths, filepath.Join(absPath, gitPath))
+	rules, err := s.newIgnoreRules(absPath)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read model path: %w", err)
+	}
+
+	var manifest *Manifest
+	switch {
+	case info.Mode().IsRegular():
+		manifest, err = s.serializeFile(ctx, absPath)
+	case !info.IsDir():
+		return nil, fmt.Errorf("model path %s is not a directory or a regular file", modelPath)
+	case s.opts.ConfineToRoot && s.opts.HuggingFaceSnapshot:
+		return nil, errors.New("HuggingFaceSnapshot cannot be combined with ConfineToRoot, snapshot blobs are outside of the snapshot directory")
+	case s.opts.Strict && s.opts.HuggingFaceSnapshot:
+		return nil, errors.New("HuggingFaceSnapshot cannot be combined with Strict, snapshot files are symlinks")
+	case s.opts.ConfineToRoot:
+		manifest, err = s.serializeConfined(ctx, absPath, rules)
+	default:
+		manifest, err = s.serializeDir(ctx, absPath, rules)
+	}
+	if err != nil {
+		return nil, err
+	}
+	manifest.ModelPath = absPath
+	return manifest, nil
+}
+
+// validateAlgorithms returns an error if any of the configured algorithms
+// is not supported.
+func (s *Serializer) validateAlgorithms() error {
+	for _, algo := range s.algorithms() {
+		h := s.newHash(algo)
+		if h == nil {
+			return fmt.Errorf("unsupported hash algorithm %q", algo)
 		}
+		s.releaseHash(algo, h)
 	}
+	return nil
+}
 
-	// Collect all files to hash
-	var filesToHash []string
+// finishManifest adds the external files to a freshly walked manifest,
+// saves the digest cache, runs the PostHash option over it, encodes its
+// digests, applies the ModelName option and records the stats and time of
+// the serialization.
+func (s *Serializer) finishManifest(ctx context.Context, manifest *Manifest) error {
+	if err := s.addExternalFiles(ctx, manifest); err != nil {
+		return err
+	}
+	if err := s.cache.save(); err != nil {
+		return err
+	}
+	if err := s.applyPostHash(manifest); err != nil {
+		return err
+	}
+	if err := s.encodeDigests(manifest); err != nil {
+		return err
+	}
+	if s.opts.ModelName != "" {
+		manifest.ModelName = s.opts.ModelName
+	}
+	manifest.Stats = statsFrom(ctx).stats()
+	manifest.SerializedAt = time.Now()
+	return nil
+}
 
-	err = filepath.Walk(absPath, func(path string, info os.FileInfo, err error) error {
+// serializeDir walks and hashes the model directory at absPath.
+func (s *Serializer) serializeDir(ctx context.Context, absPath string, rules *ignoreRules) (*Manifest, error) {
+	realRoot, err := filepath.EvalSymlinks(absPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve model path: %w", err)
+	}
+
+	modelName := filepath.Base(absPath)
+	if s.opts.HuggingFaceSnapshot {
+		modelName, err = snapshotModelName(realRoot)
 		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Hash the files as the walk finds them
+	links := s.recordedLinks(func(name string) (string, error) {
+		return os.Readlink(filepath.Join(absPath, filepath.FromSlash(name)))
+	})
+	fileDescriptors, walkErr, hashErr := s.hashWalk(ctx, func(yield func(string) error) error {
+		return s.walkDir(ctx, absPath, realRoot, realRoot, "", rules, links, rules.yieldIncluded(yield))
+	}, links.opener(func(name string) (io.ReadCloser, error) {
+		return os.Open(filepath.Join(absPath, filepath.FromSlash(name)))
+	}))
+	if walkErr != nil {
+		return nil, fmt.Errorf("%w: %w", ErrWalkFailed, walkErr)
+	}
+	if hashErr != nil {
+		return nil, fmt.Errorf("%w: %w", ErrHashFailed, hashErr)
+	}
+
+	return s.newManifest(modelName, fileDescriptors)
+}
+
+// walkError handles the error found by the walk on the entry name, d
+// when the walk got its directory entry, with the OnWalkError option:
+// it returns nil, or fs.SkipDir for a directory, when the entry is to be
+// skipped.
+func (s *Serializer) walkError(name string, d fs.DirEntry, err error) error {
+	if s.opts.OnWalkError == nil {
+		return err
+	}
+	if err := s.opts.OnWalkError(name, err); err != nil {
+		return err
+	}
+	if d != nil && d.IsDir() {
+		return fs.SkipDir
+	}
+	return nil
+}
+
+// walkDir walks dir, the directory found at prefix (slash-separated and
+// relative to the model root at absPath, empty for the root itself), and
+// calls yield with the name of every file to hash. Directory symlinks are
+// followed by walking their target under the name of the link. Entries
+// are typed from their directory listing and only stat'ed when their
+// file information is needed, by a FilterFunc or to record a link.
+func (s *Serializer) walkDir(ctx context.Context, absPath, realRoot, dir, prefix string, rules *ignoreRules, links *recordedLinks, yield func(name string) error) error {
+	return filepath.WalkDir(dir, func(realPath string, d fs.DirEntry, walkErr error) error {
+		if err := ctx.Err(); err != nil {
 			return err
 		}
 
+		relPath, err := filepath.Rel(dir, realPath)
+		if err != nil {
+			return fmt.Errorf("failed to get relative path: %w", err)
+		}
+		name := manifestName(prefix, relPath, os.PathSeparator)
+		if walkErr != nil {
+			return s.walkError(name, d, walkErr)
+		}
+		path := filepath.Join(absPath, filepath.FromSlash(name))
+
+		typ, stat := d.Type(), d.Info
+
+		// Snapshot links to their blobs are hashed as regular files
+		if typ&fs.ModeSymlink != 0 && s.opts.HuggingFaceSnapshot {
+			blob, err := snapshotBlob(realPath, realRoot)
+			if err != nil {
+				return err
+			}
+			if blob != nil {
+				typ, stat = blob.Mode().Type(), staticInfo(blob)
+			}
+		}
+
 		// Check if it's a symlink
-		if info.Mode()&os.ModeSymlink != 0 {
-			if !s.opts.AllowSymlinks {
-				return fmt.Errorf("symlink not allowed: %s (use AllowSymlinks option)", path)
+		if typ&fs.ModeSymlink != 0 {
+			policy := s.symlinkPolicy()
+			if policy == options.SymlinkReject {
+				return &SymlinkError{Path: path}
+			}
+
+			if rules.match(name, false) {
+				return nil
+			}
+
+			if policy == options.SymlinkRecordLink {
+				if keep, err := rules.keep(name, stat); err != nil || !keep {
+					return err
+				}
+				info, err := stat()
+				if err != nil {
+					return err
+				}
+				if err := links.add(name, info); err != nil {
+					return err
+				}
+				return yield(name)
+			}
+
+			followAll := policy == options.SymlinkFollowAll
+			targetPath, target, internal, err := resolveSymlink(realPath, realRoot, followAll)
+			if err != nil {
+				return err
 			}
+			if !internal && !followAll {
+				return s.externalSymlink(path)
+			}
+			if target.IsDir() {
+				if err := checkSymlinkLoop(name, target, func(name string) (fs.FileInfo, error) {
+					return os.Stat(filepath.Join(absPath, filepath.FromSlash(name)))
+				}); err != nil {
+					return err
+				}
+				return s.walkDir(ctx, absPath, realRoot, targetPath, name, rules, links, yield)
+			}
+			typ, stat = target.Mode().Type(), staticInfo(target)
 		}
 
 		// Skip directories
-		if info.IsDir() {
+		if typ.IsDir() {
 			// Check if directory should be ignored
-			ignore, err := s.shouldIgnore(path, absPath, ignorePaths)
-			if err != nil {
-				return err
+			if rules.match(name, true) {
+				return filepath.SkipDir
 			}
-			if ignore {
+			if keep, err := rules.keep(name, stat); err != nil {
+				return err
+			} else if !keep {
 				return filepath.SkipDir
 			}
-			return nil
+			if s.opts.RecordEmptyDirs && name != "." {
+				if err := yield(name + "/"); err != nil {
+					return err
+				}
+			}
+
+			return rules.loadIgnoreFiles(name, func(name string) ([]byte, error) {
+				return os.ReadFile(filepath.Join(absPath, filepath.FromSlash(name)))
+			})
 		}
 
 		// Check if file should be ignored
-		ignore, err := s.shouldIgnore(path, absPath, ignorePaths)
-		if err != nil {
-			return err
-		}
-		if ignore {
+		if rules.match(name, false) {
 			return nil
 		}
 
+		if keep, err := rules.keep(name, stat); err != nil || !keep {
+			return err
+		}
+
 		// Add regular files
-		if info.Mode().IsRegular() {
-			filesToHash = append(filesToHash, path)
+		if typ.IsRegular() {
+			return yield(name)
 		}
 
-		return nil
+		return s.specialFile(path, typ)
 	})
+}
 
-	if err != nil {
-		return nil, fmt.Errorf("failed to walk directory: %w", err)
+// specialFile returns an ErrUnsupportedFileType error for the device,
+// named pipe or socket of type typ found at path, or nil under the
+// SkipSpecialFiles option to skip it.
+func (s *Serializer) specialFile(path string, typ fs.FileMode) error {
+	if s.opts.SkipSpecialFiles && !s.opts.Strict {
+		return nil
+	}
+
+	kind := "special file"
+	switch {
+	case typ&fs.ModeNamedPipe != 0:
+		kind = "named pipe"
+	case typ&fs.ModeSocket != 0:
+		kind = "socket"
+	case typ&fs.ModeCharDevice != 0:
+		kind = "character device"
+	case typ&fs.ModeDevice != 0:
+		kind = "device"
 	}
+	return fmt.Errorf("%w: %s is a %s (use SkipSpecialFiles option)", ErrUnsupportedFileType, path, kind)
+}
+
+// manifestName returns the name recorded in the manifest for the entry at
+// rel, a path relative to the directory found at prefix using separator
+// sep. Names always use forward slashes (POSIX style), as the Python
+// library records them, so a model gets the same names and root digest
+// on every platform.
+func manifestName(prefix, rel string, sep rune) string {
+	if sep != '/' {
+		rel = strings.ReplaceAll(rel, string(sep), "/")
+	}
+	return path.Join(prefix, rel)
+}
 
-	// Hash all files using the hasher library
-	h := hasher.New()
-	h.Options.Algorithms = []intoto.HashAlgorithm{intoto.AlgorithmSHA256}
+// staticInfo returns a stat function of the keep rules returning info.
+func staticInfo(info fs.FileInfo) func() (fs.FileInfo, error) {
+	return func() (fs.FileInfo, error) { return info, nil }
+}
 
-	fileHashes, err := h.HashFiles(filesToHash)
+// serializeFile hashes a model made of the single file at absPath. The
+// manifest lists the file under its base name, which is also the model
+// name. Ignore rules do not apply to it.
+func (s *Serializer) serializeFile(ctx context.Context, absPath string) (*Manifest, error) {
+	name := filepath.Base(absPath)
+	fileDescriptors, err := s.hashFiles(ctx, []string{name}, func(string) (io.ReadCloser, error) {
+		return os.Open(absPath)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to hash files: %w", err)
+		return nil, fmt.Errorf("%w: %w", ErrHashFailed, err)
 	}
 
-	// Build manifest with relative paths
-	var fileDescriptors []*intoto.ResourceDescriptor
-	for _, filePath := range filesToHash {
-		relPath, err := filepath.Rel(absPath, filePath)
-		if err != nil {
-			return nil, fmt.Errorf("failed to get relative path: %w", err)
-		}
+	return s.newManifest(name, fileDescriptors)
+}
 
-		// Normalize to forward slashes (POSIX style) for compatibility
-		relPath = filepath.ToSlash(relPath)
+// serializeConfined is the ConfineToRoot variant of Serialize: every read
+// of the model directory goes through an os.Root opened at absPath.
+func (s *Serializer) serializeConfined(ctx context.Context, absPath string, rules *ignoreRules) (*Manifest, error) {
+	root, err := os.OpenRoot(absPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open model root: %w", err)
+	}
+	defer root.Close() //nolint:errcheck
+
+	links := s.recordedLinks(confinedReadLink(root, absPath))
+	fileDescriptors, walkErr, hashErr := s.hashWalk(ctx, func(yield func(string) error) error {
+		return s.walkFS(ctx, root.FS(), absPath, ".", rules, links, rules.yieldIncluded(yield))
+	}, links.opener(confinedOpener(root)))
+	if walkErr != nil {
+		return nil, fmt.Errorf("%w: %w", ErrWalkFailed, walkErr)
+	}
+	if hashErr != nil {
+		return nil, fmt.Errorf("%w: %w", ErrHashFailed, hashErr)
+	}
 
-		hashSet := (*fileHashes)[filePath]
-		hashValue := hashSet[intoto.AlgorithmSHA256]
+	return s.newManifest(filepath.Base(absPath), fileDescriptors)
+}
 
-		fileDescriptors = append(fileDescriptors, &intoto.ResourceDescriptor{
-			Name: relPath,
-			Digest: map[string]string{
-				"sha256": hashValue,
-			},
-		})
+// newManifest assembles the manifest of the model named modelName,
+// normalizing the names of the file descriptors and sorting them. Two
+// files ending up with the same name fail with ErrDuplicateName, names
+// holding control characters with ErrUnsafePath under RejectControlChars.
+// Under Strict, names only differing by their normalization fail too.
+func (s *Serializer) newManifest(modelName string, fileDescriptors []*intoto.ResourceDescriptor) (*Manifest, error) {
+	originals := make(map[string]string, len(fileDescriptors))
+	composed := map[string]string{}
+	for _, descriptor := range fileDescriptors {
+		original := descriptor.Name
+		if s.rejectControlChars() {
+			if err := checkControlChars(original); err != nil {
+				return nil, err
+			}
+		}
+		descriptor.Name = s.normalizeName(original)
+		if previous, ok := originals[descriptor.Name]; ok {
+			return nil, fmt.Errorf("%w: %q and %q are both recorded as %q", ErrDuplicateName, previous, original, descriptor.Name)
+		}
+		originals[descriptor.Name] = original
+
+		if s.opts.Strict {
+			nfc := norm.NFC.String(original)
+			if previous, ok := composed[nfc]; ok {
+				return nil, fmt.Errorf("%w: %q and %q only differ by their Unicode normalization", ErrDuplicateName, previous, original)
+			}
+			composed[nfc] = original
+		}
 	}
 
 	// Sort by path for deterministic ordering
@@ -186,44 +480,198 @@ func (s *Serializer) Serialize(modelPath string) (*Manifest, error) {
 		return fileDescriptors[i].Name < fileDescriptors[j].Name
 	})
 
-	modelName := filepath.Base(absPath)
-
 	return &Manifest{
-		ModelName: modelName,
-		Files:     fileDescriptors,
+		ModelName:        modelName,
+		Files:            pruneDirMarkers(fileDescriptors),
+		HashAlgorithm:    s.algorithm(),
+		RootDigestMode:   s.opts.RootDigestMode,
+		MetadataInDigest: s.opts.IncludeMetadataInDigest,
 	}, nil
 }
 
+// isDirMarker returns true if name is the marker of a directory recorded
+// with RecordEmptyDirs.
+func isDirMarker(name string) bool {
+	return strings.HasSuffix(name, "/")
+}
+
+// pruneDirMarkers drops from the sorted descriptors the directory markers
+// of directories holding other entries, keeping only the empty ones. The
+// entries below a directory sort right after its marker.
+func pruneDirMarkers(descriptors []*intoto.ResourceDescriptor) []*intoto.ResourceDescriptor {
+	pruned := descriptors[:0]
+	for i, descriptor := range descriptors {
+		if isDirMarker(descriptor.Name) && i+1 < len(descriptors) &&
+			strings.HasPrefix(descriptors[i+1].Name, descriptor.Name) {
+			continue
+		}
+		pruned = append(pruned, descriptor)
+	}
+	return pruned
+}
+
+// rejectControlChars returns true if names holding control characters
+// fail the serialization, with RejectControlChars or Strict.
+func (s *Serializer) rejectControlChars() bool {
+	return s.opts.RejectControlChars || s.opts.Strict
+}
+
+// normalizeName applies the NameNormalization option to a file name.
+func (s *Serializer) normalizeName(name string) string {
+	switch s.opts.NameNormalization {
+	case options.NormalizationNone:
+		return name
+	case options.NormalizationNFD:
+		return norm.NFD.String(name)
+	default:
+		return norm.NFC.String(name)
+	}
+}
+
+// applyPostHash runs the PostHash option over the hashed files, dropping
+// the ones it rejects from the manifest. Rejecting a shard drops every
+// shard of its file, which is listed once in Excluded.
+func (s *Serializer) applyPostHash(manifest *Manifest) error {
+	if s.opts.PostHash == nil {
+		return nil
+	}
+
+	rejected := map[string]bool{}
+	for _, file := range manifest.Files {
+		name := s.shardedFile(file.Name)
+		if rejected[name] {
+			continue
+		}
+		include, err := s.opts.PostHash(file.Name, file.Digest[string(s.algorithm())])
+		if err != nil {
+			return fmt.Errorf("post-hash check failed for %s: %w", file.Name, err)
+		}
+		if !include {
+			rejected[name] = true
+			manifest.Excluded = append(manifest.Excluded, name)
+		}
+	}
+	if len(rejected) == 0 {
+		return nil
+	}
+
+	kept := make([]*intoto.ResourceDescriptor, 0, len(manifest.Files))
+	for _, file := range manifest.Files {
+		if !rejected[s.shardedFile(file.Name)] {
+			kept = append(kept, file)
+		}
+	}
+	manifest.Files = kept
+
+	return nil
+}
+
 // ComputeRootDigest computes the root digest from a manifest.
 // This is the same digest that appears in signatures: SHA256(hash1 + hash2 + ... + hashN)
-// where hashes are raw bytes concatenated in sorted order.
+// where hashes are raw bytes concatenated in sorted order, with the default
+// RootDigestConcat mode. Manifests using
+// another HashAlgorithm replace SHA256 with it, both for the file hashes
+// and for the root. The manifest is checked with Validate first, so all
+// of its problems are reported at once.
 func ComputeRootDigest(manifest *Manifest) (string, error) {
-	hasher := sha256.New()
+	if err := manifest.Validate(); err != nil {
+		return "", err
+	}
+	return ComputeRootDigestWithAlgorithm(manifest, manifest.algorithm())
+}
+
+// RootDigestPreimageFormat describes the bytes hashed into the root
+// digest in the default RootDigestConcat mode, the one of the Python
+// library: the raw (not hex-encoded) file hashes concatenated in the
+// order of the file names, sorted bytewise, with no separator. The root
+// digest is the manifest HashAlgorithm (SHA256 by default) over them.
+const RootDigestPreimageFormat = "H(raw(hash_1) || raw(hash_2) || ... || raw(hash_N)), files sorted by name"
+
+// ComputeRootDigestWithAlgorithm computes the root digest from the algo
+// digests of the manifest files, hashing them with algo. It allows using
+// any of the extra Algorithms recorded in a manifest. The hashes are
+// combined as the manifest RootDigestMode says. The root digest is encoded
+// as the manifest DigestEncoding says.
+func ComputeRootDigestWithAlgorithm(manifest *Manifest, algo intoto.HashAlgorithm) (string, error) {
+	h := newHasher(algo)
+	if h == nil {
+		return "", fmt.Errorf("unsupported hash algorithm %q", algo)
+	}
+
+	preimage, err := rootDigestPreimage(manifest, algo)
+	if err != nil {
+		return "", err
+	}
+	h.Write(preimage)
+
+	return encodeDigest(manifest.digestEncoding(), h.Sum(nil))
+}
+
+// RootDigestPreimage returns the exact bytes ComputeRootDigest hashes
+// into the root digest of the manifest, so other implementations can
+// check their own against it. In the default RootDigestConcat mode they
+// follow RootDigestPreimageFormat. With MetadataInDigest, the raw hash of
+// every file is followed by uint64be(len(meta)) || meta, meta being the
+// canonical JSON encoding of its annotations (see Canonicalize), "{}"
+// when it has none. The manifest is checked with Validate first.
+func RootDigestPreimage(manifest *Manifest) ([]byte, error) {
+	if err := manifest.Validate(); err != nil {
+		return nil, err
+	}
+	return rootDigestPreimage(manifest, manifest.algorithm())
+}
+
+// rootDigestPreimage returns the bytes hashed into the root digest of the
+// manifest from the algo digests of its files, combined as its
+// RootDigestMode says, followed by their annotations with
+// MetadataInDigest.
+func rootDigestPreimage(manifest *Manifest, algo intoto.HashAlgorithm) ([]byte, error) {
+	mode := manifest.rootDigestMode()
+	if mode != options.RootDigestConcat && mode != options.RootDigestNameAndLength {
+		return nil, fmt.Errorf("unsupported root digest mode %q", mode)
+	}
+
+	var preimage []byte
 
 	// Files are already sorted by path in the manifest
 	for _, file := range manifest.Files {
-		// Get the sha256 hash from the digest map
-		hashValue, ok := file.Digest["sha256"]
+		// Get the hash from the digest map
+		hashValue, ok := file.Digest[string(algo)]
 		if !ok {
-			return "", fmt.Errorf("sha256 digest not found for %s", file.Name)
+			return nil, fmt.Errorf("%s digest not found for %s", algo, file.Name)
 		}
 
-		// Decode hex hash to bytes
-		hashBytes, err := hex.DecodeString(hashValue)
+		// Decode the hash to bytes
+		hashBytes, err := decodeDigest(manifest.digestEncoding(), hashValue)
 		if err != nil {
-			return "", fmt.Errorf("failed to decode hash for %s: %w", file.Name, err)
+			return nil, fmt.Errorf("failed to decode hash for %s: %w", file.Name, err)
+		}
+
+		if mode == options.RootDigestNameAndLength {
+			preimage = binary.BigEndian.AppendUint64(preimage, uint64(len(file.Name)))
+			preimage = append(preimage, file.Name...)
+			preimage = binary.BigEndian.AppendUint64(preimage, uint64(len(hashBytes)))
 		}
 
-		// Write raw hash bytes to the hasher
-		hasher.Write(hashBytes)
+		// Append the raw hash bytes
+		preimage = append(preimage, hashBytes...)
+
+		if manifest.MetadataInDigest {
+			meta, err := canonicalAnnotations(file)
+			if err != nil {
+				return nil, fmt.Errorf("encoding annotations of %s: %w", file.Name, err)
+			}
+			preimage = binary.BigEndian.AppendUint64(preimage, uint64(len(meta)))
+			preimage = append(preimage, meta...)
+		}
 	}
 
-	rootHash := hasher.Sum(nil)
-	return hex.EncodeToString(rootHash), nil
+	return preimage, nil
 }
 
 // ComputeDigest is a convenience function that serializes a model directory
-// and returns the root digest in algorithm:hash format.
+// and returns the root digest in algorithm:hash format, the hash encoded
+// as the DigestEncoding option says.
 func ComputeDigest(modelPath string, opts *options.Options) (string, error) {
 	serializer := New(opts)
 	manifest, err := serializer.Serialize(modelPath)
@@ -236,5 +684,5 @@ func ComputeDigest(modelPath string, opts *options.Options) (string, error) {
 		return "", err
 	}
 
-	return "sha256:" + rootDigest, nil
+	return string(manifest.algorithm()) + ":" + rootDigest, nil
 }
@@ -8,11 +8,30 @@
 package dir
 
 import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
 	"testing"
+	"testing/fstest"
 
 	"github.com/carabiner-dev/model-signing/internal/serializer/options"
+	intoto "github.com/in-toto/attestation/go/v1"
+	"github.com/zeebo/blake3"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
 )
 
 func TestSerialize(t *testing.T) {
@@ -108,6 +127,120 @@ func TestIgnorePaths(t *testing.T) {
 	}
 }
 
+func TestIgnorePathsNearMisses(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		files    []string
+		ignore   []string
+		expected []string
+	}{
+		{
+			"directory",
+			[]string{"data/train.bin", "data/sub/eval.bin", "data-extra", "database.bin", "data.bin", "sub/data/x.bin"},
+			[]string{"data"},
+			[]string{"data-extra", "data.bin", "database.bin", "sub/data/x.bin"},
+		},
+		{
+			"file",
+			[]string{"data", "data-extra", "database.bin", "datadir/x.bin"},
+			[]string{"data"},
+			[]string{"data-extra", "database.bin", "datadir/x.bin"},
+		},
+		{
+			"nested",
+			[]string{"sub/data/x.bin", "sub/database.bin", "subdir/data/x.bin", "sub/data-extra/y.bin"},
+			[]string{"sub/data"},
+			[]string{"sub/data-extra/y.bin", "sub/database.bin", "subdir/data/x.bin"},
+		},
+		{
+			"prefix of a component",
+			[]string{"foo/x.bin", "foobar/x.bin", "foo.bin"},
+			[]string{"foo"},
+			[]string{"foo.bin", "foobar/x.bin"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			for _, name := range tc.files {
+				path := filepath.Join(tempDir, filepath.FromSlash(name))
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatalf("Failed to create dir for %s: %v", name, err)
+				}
+				if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+					t.Fatalf("Failed to create test file %s: %v", name, err)
+				}
+			}
+
+			manifest, err := NewWithOptions(options.WithIgnorePaths(tc.ignore...)).Serialize(tempDir)
+			if err != nil {
+				t.Fatalf("Serialize failed: %v", err)
+			}
+			var names []string
+			for _, file := range manifest.Files {
+				names = append(names, file.Name)
+			}
+			if !slices.Equal(names, tc.expected) {
+				t.Errorf("Ignoring %v: expected %v, got %v", tc.ignore, tc.expected, names)
+			}
+		})
+	}
+}
+
+func TestRelativeIgnorePaths(t *testing.T) {
+	tempDir := t.TempDir()
+	for _, name := range []string{"model.bin", "subdir/layer.bin", "nested/subdir/layer.bin", "a.tmp", "nested/b.tmp"} {
+		path := filepath.Join(tempDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create dir for %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatalf("Failed to create test file %s: %v", name, err)
+		}
+	}
+	all := []string{"a.tmp", "model.bin", "nested/b.tmp", "nested/subdir/layer.bin", "subdir/layer.bin"}
+	withoutSubdir := []string{"a.tmp", "model.bin", "nested/b.tmp", "nested/subdir/layer.bin"}
+
+	for _, tc := range []struct {
+		ignore   string
+		expected []string
+	}{
+		// Plain paths are anchored at the model root in every form
+		{"subdir", withoutSubdir},
+		{"./subdir", withoutSubdir},
+		{"subdir/", withoutSubdir},
+		{"./subdir/", withoutSubdir},
+		{".//subdir", withoutSubdir},
+		{"././subdir", withoutSubdir},
+		{filepath.Join(tempDir, "subdir"), withoutSubdir},
+		{"nested/subdir/", []string{"a.tmp", "model.bin", "nested/b.tmp", "subdir/layer.bin"}},
+		// A trailing slash only matches directories
+		{"model.bin/", all},
+		{"./model.bin", []string{"a.tmp", "nested/b.tmp", "nested/subdir/layer.bin", "subdir/layer.bin"}},
+		// Wildcards match at any depth, unless anchored with ./
+		{"*.tmp", []string{"model.bin", "nested/subdir/layer.bin", "subdir/layer.bin"}},
+		{"./*.tmp", []string{"model.bin", "nested/b.tmp", "nested/subdir/layer.bin", "subdir/layer.bin"}},
+	} {
+		for _, confine := range []bool{false, true} {
+			opts := options.Default().Apply(options.WithIgnorePaths(tc.ignore))
+			opts.ConfineToRoot = confine
+			manifest, err := New(opts).Serialize(tempDir)
+			if err != nil {
+				t.Fatalf("Ignoring %q: Serialize failed: %v", tc.ignore, err)
+			}
+			if names := manifestNames(manifest); !slices.Equal(names, tc.expected) {
+				t.Errorf("Ignoring %q (ConfineToRoot %v): expected %v, got %v", tc.ignore, confine, tc.expected, names)
+			}
+		}
+	}
+
+	// The root itself cannot be ignored
+	for _, entry := range []string{".", "./", "subdir/..", tempDir} {
+		if _, err := NewWithOptions(options.WithIgnorePaths(entry)).Serialize(tempDir); err == nil {
+			t.Errorf("Expected ignoring %q to fail", entry)
+		}
+	}
+}
+
 func TestIgnoreGitPaths(t *testing.T) {
 	// Create a temporary test directory
 	tempDir, err := os.MkdirTemp("", "modeldigest-test-*")
@@ -170,6 +303,138 @@ func TestIgnoreGitPaths(t *testing.T) {
 	}
 }
 
+func TestNestedGitPaths(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "modeldigest-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	testFiles := map[string]string{
+		"model.bin":                       "weights",
+		".git/config":                     "git config",
+		"submodule/.git":                  "gitdir: ../.git/modules/submodule",
+		"submodule/.gitattributes":        "*.bin filter=lfs",
+		"submodule/layer.bin":             "layer",
+		"submodule/deep/.git/HEAD":        "ref: refs/heads/main",
+		"submodule/deep/.github/ci.yml":   "on: push",
+		"submodule/deep/data.bin":         "data",
+		"submodule/deep/not.git/keep.bin": "kept",
+	}
+	for path, content := range testFiles {
+		fullPath := filepath.Join(tempDir, path)
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			t.Fatalf("Failed to create dir for %s: %v", path, err)
+		}
+		if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+
+	for _, tc := range []struct {
+		root     string
+		expected []string
+	}{
+		{tempDir, []string{"model.bin", "submodule/deep/data.bin", "submodule/deep/not.git/keep.bin", "submodule/layer.bin"}},
+		{filepath.Join(tempDir, "submodule"), []string{"deep/data.bin", "deep/not.git/keep.bin", "layer.bin"}},
+	} {
+		for _, confine := range []bool{false, true} {
+			manifest, err := NewWithOptions(options.WithConfineToRoot(confine)).Serialize(tc.root)
+			if err != nil {
+				t.Fatalf("Serialize failed: %v", err)
+			}
+			var names []string
+			for _, file := range manifest.Files {
+				names = append(names, file.Name)
+			}
+			if !reflect.DeepEqual(names, tc.expected) {
+				t.Errorf("%s (confined: %v): expected %v, got %v", tc.root, confine, tc.expected, names)
+			}
+		}
+	}
+}
+
+func TestCrossPlatformNames(t *testing.T) {
+	// Walks on Windows and POSIX systems record the same names
+	for _, tc := range []struct {
+		prefix, rel string
+		sep         rune
+		expected    string
+	}{
+		{"", "model.bin", '/', "model.bin"},
+		{"", `subdir\nested\layer.bin`, '\\', "subdir/nested/layer.bin"},
+		{"", "subdir/nested/layer.bin", '/', "subdir/nested/layer.bin"},
+		{"linked", `nested\layer.bin`, '\\', "linked/nested/layer.bin"},
+		{"linked", ".", '\\', "linked"},
+		{"", ".", '/', "."},
+	} {
+		if got := manifestName(tc.prefix, tc.rel, tc.sep); got != tc.expected {
+			t.Errorf("manifestName(%q, %q, %q): expected %q, got %q", tc.prefix, tc.rel, tc.sep, tc.expected, got)
+		}
+	}
+
+	// The same contents get the same names and root digest from disk, an
+	// in-memory file system and archives
+	files := map[string]string{
+		"model.bin":               "weights",
+		"subdir/layer.bin":        "layer",
+		"subdir/nested/layer.bin": "nested",
+	}
+	tempDir, err := os.MkdirTemp("", "modeldigest-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	fsys := fstest.MapFS{}
+	entries := make([]tarEntry, 0, len(files))
+	for name, content := range files {
+		path := filepath.Join(tempDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create dir for %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test file %s: %v", name, err)
+		}
+		fsys[name] = &fstest.MapFile{Data: []byte(content), Mode: 0644}
+		entries = append(entries, tarEntry{name: name, typeflag: tar.TypeReg, content: content})
+	}
+
+	expected, err := New(options.Default()).Serialize(tempDir)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	expectedDigest, err := ComputeRootDigest(expected)
+	if err != nil {
+		t.Fatalf("ComputeRootDigest failed: %v", err)
+	}
+
+	manifests := map[string]func() (*Manifest, error){
+		"SerializeFS":  func() (*Manifest, error) { return New(nil).SerializeFS(fsys, ".") },
+		"SerializeTar": func() (*Manifest, error) { return SerializeTar(bytes.NewReader(buildTar(t, entries)), nil) },
+		"SerializeZip": func() (*Manifest, error) {
+			archive := buildZip(t, files)
+			return SerializeZip(bytes.NewReader(archive), int64(len(archive)), nil)
+		},
+	}
+	for source, serialize := range manifests {
+		manifest, err := serialize()
+		if err != nil {
+			t.Fatalf("%s failed: %v", source, err)
+		}
+		if diff := Compare(expected, manifest); !diff.Empty() {
+			t.Errorf("%s: unexpected manifest: %v", source, diff)
+		}
+		if digest, err := ComputeRootDigest(manifest); err != nil || digest != expectedDigest {
+			t.Errorf("%s: expected root digest %s, got %s (%v)", source, expectedDigest, digest, err)
+		}
+	}
+
+	if _, err := New(nil).SerializeMultiple(map[string]string{`models\a`: tempDir}); err == nil {
+		t.Error("Expected prefixes with backslashes to be rejected")
+	}
+}
+
 func TestComputeDigest(t *testing.T) {
 	// Create a temporary test directory
 	tempDir, err := os.MkdirTemp("", "modeldigest-test-*")
@@ -199,3 +464,1346 @@ func TestComputeDigest(t *testing.T) {
 		t.Errorf("Expected 71 characters (sha256: + 64 hex), got %d: %s", len(digest), digest)
 	}
 }
+
+func TestRootDigestPreimage(t *testing.T) {
+	_, manifest := newTestManifest(t)
+
+	// The raw hashes of config.json, model.bin and subdir/layer.bin
+	var expected []byte
+	for _, content := range []string{"{}", "weights", "layer"} {
+		sum := sha256.Sum256([]byte(content))
+		expected = append(expected, sum[:]...)
+	}
+
+	preimage, err := RootDigestPreimage(manifest)
+	if err != nil {
+		t.Fatalf("RootDigestPreimage failed: %v", err)
+	}
+	if !bytes.Equal(preimage, expected) {
+		t.Errorf("Expected preimage %x, got %x", expected, preimage)
+	}
+
+	rootDigest, err := ComputeRootDigest(manifest)
+	if err != nil {
+		t.Fatalf("ComputeRootDigest failed: %v", err)
+	}
+	if sum := sha256.Sum256(preimage); hex.EncodeToString(sum[:]) != rootDigest {
+		t.Errorf("Root digest %s is not the hash of the preimage", rootDigest)
+	}
+
+	// Name and length mode binds the names in the preimage
+	manifest.RootDigestMode = options.RootDigestNameAndLength
+	preimage, err = RootDigestPreimage(manifest)
+	if err != nil {
+		t.Fatalf("RootDigestPreimage failed: %v", err)
+	}
+	expected = binary.BigEndian.AppendUint64(nil, uint64(len("config.json")))
+	expected = append(expected, "config.json"...)
+	expected = binary.BigEndian.AppendUint64(expected, sha256.Size)
+	if !bytes.HasPrefix(preimage, expected) {
+		t.Errorf("Expected preimage to start with %x, got %x", expected, preimage)
+	}
+
+	manifest.Files[0].Digest = map[string]string{"sha256": "not hex"}
+	if _, err := RootDigestPreimage(manifest); err == nil {
+		t.Error("Expected an error for an invalid manifest")
+	}
+}
+
+func TestHashAlgorithmSHA512(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "modeldigest-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	opts := options.Default()
+	opts.HashAlgorithm = intoto.AlgorithmSHA512
+
+	// Empty directory must produce the SHA512 of empty input
+	digest, err := ComputeDigest(tempDir, opts)
+	if err != nil {
+		t.Fatalf("ComputeDigest failed: %v", err)
+	}
+	expectedEmpty := "sha512:cf83e1357eefb8bdf1542850d66d8007d620e4050b5715dc83f4a921d36ce9ce47d0d13c5d85f2b0ff8318d2877eec2f63b931bd47417a81a538327af927da3e"
+	if digest != expectedEmpty {
+		t.Errorf("Expected SHA512 of empty input, got %s", digest)
+	}
+
+	if err := os.WriteFile(filepath.Join(tempDir, "test.txt"), []byte("test"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	manifest, err := New(opts).Serialize(tempDir)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+
+	if manifest.HashAlgorithm != intoto.AlgorithmSHA512 {
+		t.Errorf("Expected manifest algorithm sha512, got %s", manifest.HashAlgorithm)
+	}
+	if len(manifest.Files[0].Digest["sha512"]) != 128 {
+		t.Errorf("Expected 128 character sha512 file digest, got %q", manifest.Files[0].Digest["sha512"])
+	}
+	if _, ok := manifest.Files[0].Digest["sha256"]; ok {
+		t.Error("Unexpected sha256 digest in sha512 manifest")
+	}
+
+	digest, err = ComputeDigest(tempDir, opts)
+	if err != nil {
+		t.Fatalf("ComputeDigest failed: %v", err)
+	}
+	// sha512: + 128 hex chars = 135 total
+	if len(digest) != 135 || digest[:7] != "sha512:" {
+		t.Errorf("Expected sha512: prefixed digest, got %s", digest)
+	}
+
+	opts.HashAlgorithm = "mickey"
+	if _, err := New(opts).Serialize(tempDir); err == nil {
+		t.Error("Expected error for unsupported hash algorithm")
+	}
+}
+
+func TestHashAlgorithmBLAKE3(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "modeldigest-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	if err := os.WriteFile(filepath.Join(tempDir, "test.txt"), []byte("test"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	opts := options.Default()
+	opts.HashAlgorithm = options.AlgorithmBLAKE3
+
+	manifest, err := New(opts).Serialize(tempDir)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+
+	fileHash := blake3.Sum256([]byte("test"))
+	if got := manifest.Files[0].Digest["blake3"]; got != hex.EncodeToString(fileHash[:]) {
+		t.Errorf("Unexpected blake3 file digest %q", got)
+	}
+
+	digest, err := ComputeDigest(tempDir, opts)
+	if err != nil {
+		t.Fatalf("ComputeDigest failed: %v", err)
+	}
+	rootHash := blake3.Sum256(fileHash[:])
+	if expected := "blake3:" + hex.EncodeToString(rootHash[:]); digest != expected {
+		t.Errorf("Expected %s, got %s", expected, digest)
+	}
+}
+
+func TestSupportedAlgorithms(t *testing.T) {
+	// Known answers for "hello"
+	expected := map[intoto.HashAlgorithm]string{
+		intoto.AlgorithmSHA256:   "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824",
+		intoto.AlgorithmSHA384:   "59e1748777448c69de6b800d7a33bbfb9ff1b463e44354c3553bcdb9c666fa90125a3c79f90397bdf5f6a13de828684f",
+		intoto.AlgorithmSHA512:   "9b71d224bd62f3785d96d46ad3ea3d73319bfbc2890caadae2dff72519673ca72323c3d99ba5c11d7c7acc6e14b8c5da0c4663475c2e5c3adef46f73bcdec043",
+		intoto.AlgorithmSHA3_256: "3338be694f50c5f338814986cdf0686453a888b84f424d792af4b9202398f392",
+		intoto.AlgorithmSHA3_384: "720aea11019ef06440fbf05d87aa24680a2153df3907b23631e7177ce620fa1330ff07c0fddee54699a4c3ee0ee9d887",
+		intoto.AlgorithmSHA3_512: "75d527c368f2efe848ecf6b073a36767800805e9eef2b1857d5f984f036eb6df891d75f72d9b154518c1cd58835286d1da9a38deba3de98b5a53e5ed78a84976",
+		options.AlgorithmBLAKE3:  "ea8f163db38682925e4491c5e58d4bb3506ef8c14eb78a86e908c5624a67200f",
+	}
+	if len(SupportedAlgorithms()) != len(expected) {
+		t.Errorf("Expected %d supported algorithms, got %v", len(expected), SupportedAlgorithms())
+	}
+	for _, algo := range SupportedAlgorithms() {
+		descriptor, err := HashReader("hello.txt", strings.NewReader("hello"), options.Default().Apply(options.WithHashAlgorithm(algo)))
+		if err != nil {
+			t.Errorf("HashReader %s failed: %v", algo, err)
+			continue
+		}
+		if got := descriptor.GetDigest()[string(algo)]; got != expected[algo] {
+			t.Errorf("Expected %s digest %s, got %s", algo, expected[algo], got)
+		}
+	}
+
+	// Broken algorithms and those not hashing the contents are rejected
+	tempDir, _ := newTestManifest(t)
+	for _, algo := range []intoto.HashAlgorithm{
+		intoto.AlgorithmMD5, intoto.AlgorithmSHA1, intoto.AlgorithmSHA224, intoto.AlgorithmSHA512_224,
+		intoto.AlgorithmSHA512_256, intoto.AlgorithmSHA3_224, intoto.AlgorithmGitBlob, intoto.AlgorithmGitCommit,
+		intoto.AlgorithmGitTag, intoto.AlgorithmGitTree, intoto.AlgorithmDirHash,
+	} {
+		if _, err := New(options.Default().Apply(options.WithHashAlgorithm(algo))).Serialize(tempDir); err == nil {
+			t.Errorf("Expected %s to be rejected", algo)
+		}
+		if DefaultHasher().NewHash(algo) != nil {
+			t.Errorf("Expected no default hasher for %s", algo)
+		}
+	}
+}
+
+func TestMultipleAlgorithms(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "modeldigest-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	for _, name := range []string{"a.bin", "b.bin"} {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte(name), 0644); err != nil {
+			t.Fatalf("Failed to create test file %s: %v", name, err)
+		}
+	}
+
+	opts := options.Default()
+	opts.Algorithms = []intoto.HashAlgorithm{intoto.AlgorithmSHA512, intoto.AlgorithmSHA256}
+
+	manifest, err := New(opts).Serialize(tempDir)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+
+	for _, file := range manifest.Files {
+		if len(file.Digest) != 2 {
+			t.Errorf("Expected 2 digests for %s, got %v", file.Name, file.Digest)
+		}
+	}
+
+	// The root digest keeps following the primary algorithm
+	rootDigest, err := ComputeRootDigest(manifest)
+	if err != nil {
+		t.Fatalf("ComputeRootDigest failed: %v", err)
+	}
+	expected, err := ComputeDigest(tempDir, options.Default())
+	if err != nil {
+		t.Fatalf("ComputeDigest failed: %v", err)
+	}
+	if "sha256:"+rootDigest != expected {
+		t.Errorf("Expected root digest %s, got sha256:%s", expected, rootDigest)
+	}
+
+	// ...and can be computed with any of the recorded ones
+	sha512Root, err := ComputeRootDigestWithAlgorithm(manifest, intoto.AlgorithmSHA512)
+	if err != nil {
+		t.Fatalf("ComputeRootDigestWithAlgorithm failed: %v", err)
+	}
+	sha512Opts := options.Default()
+	sha512Opts.HashAlgorithm = intoto.AlgorithmSHA512
+	expected, err = ComputeDigest(tempDir, sha512Opts)
+	if err != nil {
+		t.Fatalf("ComputeDigest failed: %v", err)
+	}
+	if "sha512:"+sha512Root != expected {
+		t.Errorf("Expected root digest %s, got sha512:%s", expected, sha512Root)
+	}
+
+	if _, err := ComputeRootDigestWithAlgorithm(manifest, intoto.AlgorithmSHA384); err == nil {
+		t.Error("Expected error computing the root with an algorithm not in the manifest")
+	}
+}
+
+func TestSerializeContext(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "modeldigest-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	for i := range 10 {
+		name := filepath.Join(tempDir, fmt.Sprintf("file-%d.bin", i))
+		if err := os.WriteFile(name, []byte(name), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	t.Run("Canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		for _, confine := range []bool{false, true} {
+			opts := options.Default()
+			opts.ConfineToRoot = confine
+			if _, err := New(opts).SerializeContext(ctx, tempDir); !errors.Is(err, context.Canceled) {
+				t.Errorf("Expected context.Canceled (confined: %v), got %v", confine, err)
+			}
+		}
+	})
+
+	t.Run("CanceledWhileHashing", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		names := make([]string, 100)
+		for i := range names {
+			names[i] = fmt.Sprintf("file-%d", i)
+		}
+
+		opts := options.Default()
+		opts.Concurrency = 1
+		_, err := New(opts).hashFiles(ctx, names, func(name string) (io.ReadCloser, error) {
+			cancel()
+			return io.NopCloser(strings.NewReader(name)), nil
+		})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+	})
+
+	t.Run("Background", func(t *testing.T) {
+		manifest, err := New(options.Default()).SerializeContext(context.Background(), tempDir)
+		if err != nil {
+			t.Fatalf("SerializeContext failed: %v", err)
+		}
+		if len(manifest.Files) != 10 {
+			t.Errorf("Expected 10 files, got %d", len(manifest.Files))
+		}
+	})
+}
+
+func TestIgnorePatterns(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "modeldigest-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	for _, name := range []string{
+		"model.bin",
+		"weights.bin",
+		"config.json",
+		"scratch.tmp",
+		"logs/run.txt",
+		"a/b/tmp/cache",
+		"a/checkpoints/step1",
+		"data/file",
+		"database.bin",
+	} {
+		path := filepath.Join(tempDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create dir for %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatalf("Failed to create test file %s: %v", name, err)
+		}
+	}
+
+	for _, tc := range []struct {
+		name     string
+		patterns []string
+		expected []string
+	}{
+		{"glob", []string{"*.bin", "*.tmp"}, []string{"a/b/tmp/cache", "a/checkpoints/step1", "config.json", "data/file", "logs/run.txt"}},
+		{"dirs", []string{"logs/", "**/tmp", "**/checkpoints/"}, []string{"config.json", "data/file", "database.bin", "model.bin", "scratch.tmp", "weights.bin"}},
+		{"negation", []string{"*.bin", "!model.bin", "a", "data", "logs", "*.tmp"}, []string{"config.json", "model.bin"}},
+		{"plain", []string{"data", "logs/run.txt", filepath.Join(tempDir, "a")}, []string{"config.json", "database.bin", "model.bin", "scratch.tmp", "weights.bin"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := options.Default()
+			opts.IgnorePaths = tc.patterns
+
+			manifest, err := New(opts).Serialize(tempDir)
+			if err != nil {
+				t.Fatalf("Serialize failed: %v", err)
+			}
+
+			if len(manifest.Files) != len(tc.expected) {
+				t.Fatalf("Expected %v, got %d files", tc.expected, len(manifest.Files))
+			}
+			for i, file := range manifest.Files {
+				if file.Name != tc.expected[i] {
+					t.Errorf("File %d: expected %s, got %s", i, tc.expected[i], file.Name)
+				}
+			}
+		})
+	}
+
+	opts := options.Default()
+	opts.IgnorePaths = []string{"[unterminated"}
+	if _, err := New(opts).Serialize(tempDir); err == nil {
+		t.Error("Expected error for invalid ignore pattern")
+	}
+}
+
+func TestRespectGitignore(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "modeldigest-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	testFiles := map[string]string{
+		".gitignore":               "*.pyc\n/build/\n# comment\n",
+		"model.bin":                "weights",
+		"cache.pyc":                "bytecode",
+		"build/out.bin":            "build output",
+		"sub/.gitignore":           "*.tmp\n!keep.pyc\n",
+		"sub/data.tmp":             "temporary",
+		"sub/keep.pyc":             "kept bytecode",
+		"sub/build/layer.bin":      "not the root build dir",
+		"other/data.tmp":           "not scoped by sub/.gitignore",
+		"sub/nested/.gitignore":    "/only-here.bin\n",
+		"sub/nested/only-here.bin": "ignored",
+	}
+	for path, content := range testFiles {
+		fullPath := filepath.Join(tempDir, path)
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			t.Fatalf("Failed to create dir for %s: %v", path, err)
+		}
+		if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+
+	// The .gitignore files are git paths, ignored at any depth
+	expected := []string{
+		"model.bin",
+		"other/data.tmp",
+		"sub/build/layer.bin",
+		"sub/keep.pyc",
+	}
+
+	for _, confine := range []bool{false, true} {
+		opts := options.Default()
+		opts.RespectGitignore = true
+		opts.ConfineToRoot = confine
+
+		manifest, err := New(opts).Serialize(tempDir)
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+
+		if len(manifest.Files) != len(expected) {
+			for _, f := range manifest.Files {
+				t.Logf("  Found file: %s", f.Name)
+			}
+			t.Fatalf("Expected %d files (confined: %v), got %d", len(expected), confine, len(manifest.Files))
+		}
+		for i, file := range manifest.Files {
+			if file.Name != expected[i] {
+				t.Errorf("File %d: expected %s, got %s", i, expected[i], file.Name)
+			}
+		}
+	}
+
+	// Without the option .gitignore files are not read
+	manifest, err := New(options.Default()).Serialize(tempDir)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	if len(manifest.Files) != len(testFiles)-3 {
+		t.Errorf("Expected %d files, got %d", len(testFiles)-3, len(manifest.Files))
+	}
+}
+
+func TestRespectIgnoreFile(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "modeldigest-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	testFiles := map[string]string{
+		IgnoreFileName:          "*.tmp\nlogs/\n!keep.tmp\n# comment\n",
+		".gitignore":            "!data.tmp\n",
+		"model.bin":             "weights",
+		"data.tmp":              "temporary",
+		"keep.tmp":              "kept",
+		"logs/run.txt":          "log",
+		"sub/scratch.tmp":       "temporary",
+		"sub/" + IgnoreFileName: "layer.bin\n",
+		"sub/layer.bin":         "only the root ignore file is read",
+	}
+	for path, content := range testFiles {
+		fullPath := filepath.Join(tempDir, path)
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			t.Fatalf("Failed to create dir for %s: %v", path, err)
+		}
+		if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+
+	// A .gitignore cannot re-include what the ignore file ignores
+	expected := []string{
+		IgnoreFileName,
+		"keep.tmp",
+		"model.bin",
+		"sub/" + IgnoreFileName,
+		"sub/layer.bin",
+	}
+	check := func(t *testing.T, manifest *Manifest) {
+		t.Helper()
+		var names []string
+		for _, file := range manifest.Files {
+			names = append(names, file.Name)
+		}
+		if !slices.Equal(names, expected) {
+			t.Errorf("Expected %v, got %v", expected, names)
+		}
+	}
+
+	opts := options.Default()
+	opts.RespectIgnoreFile = true
+	opts.RespectGitignore = true
+	for _, confine := range []bool{false, true} {
+		opts.ConfineToRoot = confine
+		manifest, err := New(opts).Serialize(tempDir)
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+		check(t, manifest)
+	}
+
+	fsys := fstest.MapFS{}
+	var entries []tarEntry
+	for path, content := range testFiles {
+		fsys["model/"+path] = &fstest.MapFile{Data: []byte(content)}
+		entries = append(entries, tarEntry{name: path, content: content, typeflag: tar.TypeReg})
+	}
+	manifest, err := New(opts).SerializeFS(fsys, "model")
+	if err != nil {
+		t.Fatalf("SerializeFS failed: %v", err)
+	}
+	check(t, manifest)
+
+	manifest, err = SerializeTar(bytes.NewReader(buildTar(t, entries)), opts)
+	if err != nil {
+		t.Fatalf("SerializeTar failed: %v", err)
+	}
+	check(t, manifest)
+
+	// Without the option the ignore file is not read
+	manifest, err = New(options.Default()).Serialize(tempDir)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	if len(manifest.Files) != len(testFiles)-1 {
+		t.Errorf("Expected %d files, got %d", len(testFiles)-1, len(manifest.Files))
+	}
+}
+
+func TestExternalIgnorePaths(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "modeldigest-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	if err := os.WriteFile(filepath.Join(tempDir, "model.bin"), []byte("weights"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	for _, entry := range []string{
+		filepath.Join(filepath.Dir(tempDir), "elsewhere"),
+		"../model.bin",
+		"!../*.bin",
+		"..",
+	} {
+		opts := options.Default()
+		opts.IgnorePaths = []string{entry}
+		if _, err := New(opts).Serialize(tempDir); err == nil {
+			t.Errorf("Expected error for external ignore path %q", entry)
+		}
+
+		opts.AllowExternalIgnorePaths = true
+		manifest, err := New(opts).Serialize(tempDir)
+		if err != nil {
+			t.Fatalf("Serialize with AllowExternalIgnorePaths failed for %q: %v", entry, err)
+		}
+		if len(manifest.Files) != 1 {
+			t.Errorf("Expected 1 file for %q, got %d", entry, len(manifest.Files))
+		}
+	}
+
+	opts := options.Default()
+	opts.IgnorePaths = []string{"..model.bin"}
+	manifest, err := New(opts).Serialize(tempDir)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	if len(manifest.Files) != 1 {
+		t.Errorf("Expected 1 file, got %d", len(manifest.Files))
+	}
+
+	// Plain paths are cleaned, staying inside of the model
+	opts.IgnorePaths = []string{"sub/../model.bin"}
+	manifest, err = New(opts).Serialize(tempDir)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	if len(manifest.Files) != 0 {
+		t.Errorf("Expected model.bin to be ignored, got %d files", len(manifest.Files))
+	}
+}
+
+func TestVCSIgnorePaths(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "modeldigest-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	for _, name := range []string{"model.bin", ".git/config", ".hg/store", ".hgignore", ".svn/entries"} {
+		path := filepath.Join(tempDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create dir for %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatalf("Failed to create test file %s: %v", name, err)
+		}
+	}
+
+	for _, tc := range []struct {
+		name     string
+		paths    []string
+		expected int
+	}{
+		{"default", nil, 4},
+		{"mercurial", []string{".hg", ".hgignore", ".svn"}, 2},
+		{"empty", []string{}, 5},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := options.Default()
+			opts.VCSIgnorePaths = tc.paths
+
+			manifest, err := New(opts).Serialize(tempDir)
+			if err != nil {
+				t.Fatalf("Serialize failed: %v", err)
+			}
+			if len(manifest.Files) != tc.expected {
+				t.Errorf("Expected %d files, got %d", tc.expected, len(manifest.Files))
+			}
+		})
+	}
+}
+
+func TestNameNormalization(t *testing.T) {
+	composed := "caf\u00e9.bin"
+	decomposed := "cafe\u0301.bin"
+
+	dirs := map[string]string{}
+	for form, name := range map[string]string{"nfc": composed, "nfd": decomposed} {
+		tempDir, err := os.MkdirTemp("", "modeldigest-test-*")
+		if err != nil {
+			t.Fatalf("Failed to create temp dir: %v", err)
+		}
+		defer os.RemoveAll(tempDir)
+
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte("weights"), 0644); err != nil {
+			t.Skipf("File system does not accept the name: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(tempDir, "z.bin"), []byte("z"), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+		dirs[form] = tempDir
+	}
+
+	for _, tc := range []struct {
+		normalization options.Normalization
+		name          string
+	}{
+		{"", composed},
+		{options.NormalizationNFC, composed},
+		{options.NormalizationNFD, decomposed},
+	} {
+		digests := map[string]string{}
+		for form, dir := range dirs {
+			opts := options.Default()
+			opts.NameNormalization = tc.normalization
+
+			manifest, err := New(opts).Serialize(dir)
+			if err != nil {
+				t.Fatalf("Serialize failed: %v", err)
+			}
+			if manifest.Files[0].Name != tc.name {
+				t.Errorf("%q: expected name %q, got %q", tc.normalization, tc.name, manifest.Files[0].Name)
+			}
+
+			digests[form], err = ComputeRootDigest(manifest)
+			if err != nil {
+				t.Fatalf("ComputeRootDigest failed: %v", err)
+			}
+		}
+		if digests["nfc"] != digests["nfd"] {
+			t.Errorf("%q: digests differ across normalization forms: %v", tc.normalization, digests)
+		}
+	}
+
+	opts := options.Default()
+	opts.NameNormalization = options.NormalizationNone
+	manifest, err := New(opts).Serialize(dirs["nfd"])
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	if manifest.Files[0].Name != decomposed {
+		t.Errorf("Expected the name as read, got %q", manifest.Files[0].Name)
+	}
+}
+
+func TestDuplicateName(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "modeldigest-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	composed := "caf\u00e9.bin"
+	decomposed := "cafe\u0301.bin"
+	for _, name := range []string{composed, decomposed} {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte(name), 0644); err != nil {
+			t.Skipf("File system does not accept the name: %v", err)
+		}
+	}
+	entries, err := os.ReadDir(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to read dir: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Skip("File system normalizes names")
+	}
+
+	_, err = New(nil).Serialize(tempDir)
+	if !errors.Is(err, ErrDuplicateName) {
+		t.Fatalf("Expected ErrDuplicateName, got %v", err)
+	}
+	if !strings.Contains(err.Error(), decomposed) || !strings.Contains(err.Error(), composed) {
+		t.Errorf("Error does not name both files: %v", err)
+	}
+
+	opts := options.Default()
+	opts.NameNormalization = options.NormalizationNone
+	manifest, err := New(opts).Serialize(tempDir)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	if len(manifest.Files) != 2 {
+		t.Errorf("Expected 2 files, got %d", len(manifest.Files))
+	}
+}
+
+func TestCaseInsensitiveIgnores(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "modeldigest-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	for _, name := range []string{"model.bin", "readme.md", "Logs/run.txt"} {
+		path := filepath.Join(tempDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create dir for %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatalf("Failed to create test file %s: %v", name, err)
+		}
+	}
+
+	opts := options.Default()
+	opts.IgnorePaths = []string{"README.md", filepath.Join(tempDir, "logs")}
+	manifest, err := New(opts).Serialize(tempDir)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	if len(manifest.Files) != 3 {
+		t.Errorf("Expected 3 files with case-sensitive ignores, got %d", len(manifest.Files))
+	}
+
+	opts.CaseInsensitiveIgnores = true
+	manifest, err = New(opts).Serialize(tempDir)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	if len(manifest.Files) != 1 || manifest.Files[0].Name != "model.bin" {
+		t.Errorf("Expected only model.bin with case-insensitive ignores, got %v", manifest.Files)
+	}
+}
+
+func TestSkipHidden(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "modeldigest-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// The model itself lives in a hidden directory, as in a cache.
+	modelDir := filepath.Join(tempDir, ".cache", "model")
+	for _, name := range []string{
+		"model.bin", "extra.txt", ".DS_Store", ".gitignore",
+		".ipynb_checkpoints/notebook.ipynb", "sub/weights.bin", "sub/.hidden",
+	} {
+		path := filepath.Join(modelDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create dir for %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatalf("Failed to create test file %s: %v", name, err)
+		}
+	}
+
+	opts := options.Default()
+	opts.IgnorePaths = []string{"extra.txt"}
+	manifest, err := New(opts).Serialize(modelDir)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	if len(manifest.Files) != 5 {
+		t.Errorf("Expected 5 files without SkipHidden, got %d", len(manifest.Files))
+	}
+
+	opts.SkipHidden = true
+	manifest, err = New(opts).Serialize(modelDir)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	var names []string
+	for _, file := range manifest.Files {
+		names = append(names, file.Name)
+	}
+	if strings.Join(names, ",") != "model.bin,sub/weights.bin" {
+		t.Errorf("Expected model.bin and sub/weights.bin, got %v", names)
+	}
+}
+
+func TestRecordEmptyDirs(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "modeldigest-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	for _, name := range []string{"model.bin", "data/x.bin", "logs/run.log"} {
+		path := filepath.Join(tempDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create dir for %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatalf("Failed to create test file %s: %v", name, err)
+		}
+	}
+	for _, dir := range []string{"cache", "nested/a/b"} {
+		if err := os.MkdirAll(filepath.Join(tempDir, dir), 0755); err != nil {
+			t.Fatalf("Failed to create dir %s: %v", dir, err)
+		}
+	}
+
+	opts := options.Default()
+	opts.IgnorePaths = []string{"*.log"}
+	plain, err := New(opts).Serialize(tempDir)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+
+	opts.RecordEmptyDirs = true
+	manifest, err := New(opts).Serialize(tempDir)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	var names []string
+	for _, file := range manifest.Files {
+		names = append(names, file.Name)
+	}
+	expected := "cache/,data/x.bin,logs/,model.bin,nested/a/b/"
+	if strings.Join(names, ",") != expected {
+		t.Errorf("Expected %s, got %v", expected, names)
+	}
+	empty := sha256.Sum256(nil)
+	if manifest.Files[0].Digest["sha256"] != hex.EncodeToString(empty[:]) {
+		t.Errorf("Expected the empty digest for the marker, got %s", manifest.Files[0].Digest["sha256"])
+	}
+
+	plainDigest, err := ComputeRootDigest(plain)
+	if err != nil {
+		t.Fatalf("ComputeRootDigest failed: %v", err)
+	}
+	digest, err := ComputeRootDigest(manifest)
+	if err != nil {
+		t.Fatalf("ComputeRootDigest failed: %v", err)
+	}
+	if digest == plainDigest {
+		t.Error("Expected empty directories to change the root digest")
+	}
+
+	opts.ConfineToRoot = true
+	confined, err := New(opts).Serialize(tempDir)
+	if err != nil {
+		t.Fatalf("Serialize with ConfineToRoot failed: %v", err)
+	}
+	if diff := Compare(manifest, confined); !diff.Empty() {
+		t.Errorf("Confined manifest differs: %+v", diff)
+	}
+}
+
+func TestNewWithOptions(t *testing.T) {
+	tempDir, _ := newTestManifest(t)
+
+	opts := options.Default()
+	opts.IgnoreGitPaths = false
+	opts.IgnorePaths = []string{"config.json"}
+	opts.HashAlgorithm = intoto.AlgorithmSHA512
+	opts.RecordSizes = true
+	expected, err := New(opts).Serialize(tempDir)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+
+	manifest, err := NewWithOptions(
+		options.WithIgnoreGitPaths(false),
+		options.WithIgnorePaths("config.json"),
+		options.WithHashAlgorithm(intoto.AlgorithmSHA512),
+		options.WithRecordSizes(true),
+	).Serialize(tempDir)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	if len(manifest.Files) != len(expected.Files) {
+		t.Fatalf("Expected %d files, got %d", len(expected.Files), len(manifest.Files))
+	}
+	for i := range manifest.Files {
+		if manifest.Files[i].String() != expected.Files[i].String() {
+			t.Errorf("File %d: expected %v, got %v", i, expected.Files[i], manifest.Files[i])
+		}
+	}
+
+	// Without options it uses the defaults
+	if !reflect.DeepEqual(NewWithOptions().opts, options.Default()) {
+		t.Error("Expected NewWithOptions() to use the default options")
+	}
+}
+
+func TestIgnoreExtensions(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "modeldigest-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	for _, name := range []string{
+		"model.bin", "train.log", "sub/cache.TMP", "data.tar.gz", "data.gz", "run.log/notes.txt",
+	} {
+		path := filepath.Join(tempDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create dir for %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatalf("Failed to create test file %s: %v", name, err)
+		}
+	}
+
+	names := func(opts *options.Options) string {
+		t.Helper()
+		manifest, err := New(opts).Serialize(tempDir)
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+		var names []string
+		for _, file := range manifest.Files {
+			names = append(names, file.Name)
+		}
+		return strings.Join(names, ",")
+	}
+
+	opts := options.Default()
+	opts.IgnoreExtensions = []string{".log", "tmp", ".tar.gz"}
+	if got := names(opts); got != "data.gz,model.bin,run.log/notes.txt,sub/cache.TMP" {
+		t.Errorf("Unexpected files %s", got)
+	}
+
+	opts.CaseInsensitiveIgnores = true
+	if got := names(opts); got != "data.gz,model.bin,run.log/notes.txt" {
+		t.Errorf("Unexpected files with case-insensitive ignores %s", got)
+	}
+}
+
+func TestIncludePatterns(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "modeldigest-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	for _, name := range []string{
+		"model.safetensors", "config.json", "README.md",
+		"shards/a.safetensors", "shards/b.safetensors", "tokenizer/vocab.txt",
+	} {
+		path := filepath.Join(tempDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create dir for %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatalf("Failed to create test file %s: %v", name, err)
+		}
+	}
+
+	for _, tc := range []struct {
+		name     string
+		include  []string
+		ignore   []string
+		expected string
+	}{
+		{"None", nil, nil, "README.md,config.json,model.safetensors,shards/a.safetensors,shards/b.safetensors,tokenizer/vocab.txt"},
+		{"Extension", []string{"*.safetensors"}, nil, "model.safetensors,shards/a.safetensors,shards/b.safetensors"},
+		{"Directory", []string{"tokenizer/", "/config.json"}, nil, "config.json,tokenizer/vocab.txt"},
+		{"ThenIgnore", []string{"*.safetensors"}, []string{"shards/b.safetensors"}, "model.safetensors,shards/a.safetensors"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := options.Default()
+			opts.IncludePatterns = tc.include
+			opts.IgnorePaths = tc.ignore
+
+			for _, confine := range []bool{false, true} {
+				opts.ConfineToRoot = confine
+				manifest, err := New(opts).Serialize(tempDir)
+				if err != nil {
+					t.Fatalf("Serialize failed: %v", err)
+				}
+				var names []string
+				for _, file := range manifest.Files {
+					names = append(names, file.Name)
+				}
+				if got := strings.Join(names, ","); got != tc.expected {
+					t.Errorf("ConfineToRoot %v: expected %s, got %s", confine, tc.expected, got)
+				}
+			}
+		})
+	}
+}
+
+func TestFilterFunc(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "modeldigest-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	for name, content := range map[string]string{
+		"model.bin":       "weights weights weights",
+		"config.json":     "{}",
+		"cache/entry.bin": "cached",
+		"subdir/big.bin":  "a large layer of weights",
+		".git/HEAD":       "ref",
+	} {
+		path := filepath.Join(tempDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create dir for %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test file %s: %v", name, err)
+		}
+	}
+
+	errFilter := errors.New("filter failed")
+	for _, tc := range []struct {
+		name     string
+		filter   func(path string, info os.FileInfo) (bool, error)
+		expected string
+		err      error
+	}{
+		{"KeepAll", func(string, os.FileInfo) (bool, error) { return true, nil }, "cache/entry.bin,config.json,model.bin,subdir/big.bin", nil},
+		{"Size", func(_ string, info os.FileInfo) (bool, error) {
+			return info.IsDir() || info.Size() < 20, nil
+		}, "cache/entry.bin,config.json", nil},
+		{"Directory", func(path string, info os.FileInfo) (bool, error) {
+			return !info.IsDir() || path != "cache", nil
+		}, "config.json,model.bin,subdir/big.bin", nil},
+		{"Error", func(path string, _ os.FileInfo) (bool, error) {
+			if path == "config.json" {
+				return false, errFilter
+			}
+			return true, nil
+		}, "", errFilter},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var seen []string
+			opts := options.Default()
+			opts.FilterFunc = func(path string, info os.FileInfo) (bool, error) {
+				seen = append(seen, path)
+				return tc.filter(path, info)
+			}
+
+			for _, confine := range []bool{false, true} {
+				opts.ConfineToRoot = confine
+				manifest, err := New(opts).Serialize(tempDir)
+				if tc.err != nil {
+					if !errors.Is(err, tc.err) {
+						t.Errorf("ConfineToRoot %v: expected %v, got %v", confine, tc.err, err)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatalf("Serialize failed: %v", err)
+				}
+				var names []string
+				for _, file := range manifest.Files {
+					names = append(names, file.Name)
+				}
+				if got := strings.Join(names, ","); got != tc.expected {
+					t.Errorf("ConfineToRoot %v: expected %s, got %s", confine, tc.expected, got)
+				}
+			}
+
+			// The filter only sees what the ignore rules keep
+			for _, path := range seen {
+				if path == ".git" || strings.HasPrefix(path, ".git/") {
+					t.Errorf("FilterFunc called with ignored path %s", path)
+				}
+			}
+		})
+	}
+}
+
+func TestDigestEncoding(t *testing.T) {
+	tempDir, manifest := newTestManifest(t)
+
+	hexRoot, err := ComputeRootDigest(manifest)
+	if err != nil {
+		t.Fatalf("ComputeRootDigest failed: %v", err)
+	}
+	rawRoot, err := hex.DecodeString(hexRoot)
+	if err != nil {
+		t.Fatalf("Failed to decode root digest: %v", err)
+	}
+
+	for enc, encoding := range map[options.DigestEncoding]func([]byte) string{
+		options.DigestEncodingBase64:    base64.StdEncoding.EncodeToString,
+		options.DigestEncodingBase64URL: base64.RawURLEncoding.EncodeToString,
+	} {
+		t.Run(string(enc), func(t *testing.T) {
+			encoded, err := NewWithOptions(options.WithDigestEncoding(enc)).Serialize(tempDir)
+			if err != nil {
+				t.Fatalf("Serialize failed: %v", err)
+			}
+			for i, file := range encoded.Files {
+				raw, err := hex.DecodeString(manifest.Files[i].Digest["sha256"])
+				if err != nil {
+					t.Fatalf("Failed to decode digest: %v", err)
+				}
+				if file.Digest["sha256"] != encoding(raw) {
+					t.Errorf("%s: expected digest %s, got %s", file.Name, encoding(raw), file.Digest["sha256"])
+				}
+			}
+
+			// The root digest is the same, encoded the same way
+			digest, err := ComputeDigest(tempDir, &options.Options{DigestEncoding: enc, IgnoreGitPaths: true})
+			if err != nil {
+				t.Fatalf("ComputeDigest failed: %v", err)
+			}
+			if digest != "sha256:"+encoding(rawRoot) {
+				t.Errorf("Expected root digest sha256:%s, got %s", encoding(rawRoot), digest)
+			}
+
+			// The encoding survives JSON
+			data, err := json.Marshal(encoded)
+			if err != nil {
+				t.Fatalf("Marshal failed: %v", err)
+			}
+			var decoded Manifest
+			if err := json.Unmarshal(data, &decoded); err != nil {
+				t.Fatalf("Unmarshal failed: %v", err)
+			}
+			if err := decoded.Validate(); err != nil {
+				t.Errorf("Validate failed: %v", err)
+			}
+			if root, err := ComputeRootDigest(&decoded); err != nil || root != encoding(rawRoot) {
+				t.Errorf("Decoded manifest root digest %s (%v), expected %s", root, err, encoding(rawRoot))
+			}
+
+			// Statements hold hex digests
+			statement, err := encoded.ToStatement("https://example.com/model/v1")
+			if err != nil {
+				t.Fatalf("ToStatement failed: %v", err)
+			}
+			if got := statement.Subject[0].Digest["sha256"]; got != hexRoot {
+				t.Errorf("Expected hex root digest %s in the statement, got %s", hexRoot, got)
+			}
+
+			if diff := Compare(manifest, encoded); !diff.Empty() {
+				t.Errorf("Encodings compared as different: %+v", diff)
+			}
+			if err := New(options.Default()).Verify(tempDir, encoded); err != nil {
+				t.Errorf("Verify failed: %v", err)
+			}
+			if err := VerifyRootDigest(tempDir, digest, &options.Options{DigestEncoding: enc, IgnoreGitPaths: true}); err != nil {
+				t.Errorf("VerifyRootDigest failed: %v", err)
+			}
+		})
+	}
+
+	if _, err := NewWithOptions(options.WithDigestEncoding("base32")).Serialize(tempDir); err == nil {
+		t.Error("Expected an unknown digest encoding to fail")
+	}
+}
+
+func TestRootDigestMode(t *testing.T) {
+	tempDir, manifest := newTestManifest(t)
+
+	concat, err := ComputeRootDigest(manifest)
+	if err != nil {
+		t.Fatalf("ComputeRootDigest failed: %v", err)
+	}
+
+	opts := options.Default()
+	opts.RootDigestMode = ""
+	unset, err := ComputeDigest(tempDir, opts)
+	if err != nil {
+		t.Fatalf("ComputeDigest failed: %v", err)
+	}
+	if unset != "sha256:"+concat {
+		t.Errorf("Empty mode digest %s does not match concat %s", unset, concat)
+	}
+
+	opts.RootDigestMode = options.RootDigestNameAndLength
+	named, err := New(opts).Serialize(tempDir)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	digest, err := ComputeRootDigest(named)
+	if err != nil {
+		t.Fatalf("ComputeRootDigest failed: %v", err)
+	}
+
+	h := sha256.New()
+	for _, file := range named.Files {
+		raw, err := hex.DecodeString(file.Digest["sha256"])
+		if err != nil {
+			t.Fatalf("Failed to decode digest: %v", err)
+		}
+		h.Write(binary.BigEndian.AppendUint64(nil, uint64(len(file.Name))))
+		h.Write([]byte(file.Name))
+		h.Write(binary.BigEndian.AppendUint64(nil, uint64(len(raw))))
+		h.Write(raw)
+	}
+	if expected := hex.EncodeToString(h.Sum(nil)); digest != expected {
+		t.Errorf("NameAndLength digest %s, expected %s", digest, expected)
+	}
+	if digest == concat {
+		t.Error("NameAndLength digest matches the concat digest")
+	}
+
+	// Renaming a file changes the digest in NameAndLength mode only
+	if err := os.Rename(filepath.Join(tempDir, "model.bin"), filepath.Join(tempDir, "model2.bin")); err != nil {
+		t.Fatalf("Failed to rename file: %v", err)
+	}
+	renamed, err := New(opts).Serialize(tempDir)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	renamedDigest, err := ComputeRootDigest(renamed)
+	if err != nil {
+		t.Fatalf("ComputeRootDigest failed: %v", err)
+	}
+	if renamedDigest == digest {
+		t.Error("Renaming a file did not change the NameAndLength digest")
+	}
+
+	// The mode survives a JSON round trip
+	data, err := json.Marshal(named)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	var loaded Manifest
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if loadedDigest, err := ComputeRootDigest(&loaded); err != nil || loadedDigest != digest {
+		t.Errorf("Loaded manifest digest %s (%v), expected %s", loadedDigest, err, digest)
+	}
+
+	named.RootDigestMode = "mickey"
+	if _, err := ComputeRootDigest(named); err == nil {
+		t.Error("Expected error for unsupported root digest mode")
+	}
+}
+
+func TestIncludeMetadataInDigest(t *testing.T) {
+	tempDir, manifest := newTestManifest(t)
+	plain, err := ComputeRootDigest(manifest)
+	if err != nil {
+		t.Fatalf("ComputeRootDigest failed: %v", err)
+	}
+
+	opts := options.Default().Apply(options.WithRecordSizes(true), options.WithIncludeMetadataInDigest(true))
+	withMeta, err := New(opts).Serialize(tempDir)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	if !withMeta.MetadataInDigest {
+		t.Fatal("Expected the manifest to record MetadataInDigest")
+	}
+	digest, err := ComputeRootDigest(withMeta)
+	if err != nil {
+		t.Fatalf("ComputeRootDigest failed: %v", err)
+	}
+
+	// Each raw hash is followed by the length and canonical JSON of the annotations
+	h := sha256.New()
+	for _, file := range withMeta.Files {
+		raw, err := hex.DecodeString(file.Digest["sha256"])
+		if err != nil {
+			t.Fatalf("Failed to decode digest: %v", err)
+		}
+		meta := map[string]string{
+			"config.json":      `{"size":2}`,
+			"model.bin":        `{"size":7}`,
+			"subdir/layer.bin": `{"size":5}`,
+		}[file.Name]
+		h.Write(raw)
+		h.Write(binary.BigEndian.AppendUint64(nil, uint64(len(meta))))
+		h.Write([]byte(meta))
+	}
+	if expected := hex.EncodeToString(h.Sum(nil)); digest != expected {
+		t.Errorf("Metadata digest %s, expected %s", digest, expected)
+	}
+
+	// Files without annotations contribute "{}"
+	bare, err := New(options.Default().Apply(options.WithIncludeMetadataInDigest(true))).Serialize(tempDir)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	preimage, err := RootDigestPreimage(bare)
+	if err != nil {
+		t.Fatalf("RootDigestPreimage failed: %v", err)
+	}
+	if len(preimage) != 3*(32+8+2) || !bytes.HasSuffix(preimage, []byte("\x00\x00\x00\x00\x00\x00\x00\x02{}")) {
+		t.Errorf("Unexpected preimage %x", preimage)
+	}
+	if bareDigest, err := ComputeRootDigest(bare); err != nil || bareDigest == plain {
+		t.Errorf("Expected a digest other than %s, got %s (%v)", plain, bareDigest, err)
+	}
+
+	// Changing an annotation changes the digest only with MetadataInDigest
+	for _, include := range []bool{false, true} {
+		m := *withMeta
+		m.MetadataInDigest = include
+		m.Files = slices.Clone(withMeta.Files)
+		m.Files[0] = proto.Clone(m.Files[0]).(*intoto.ResourceDescriptor)
+		before, err := ComputeRootDigest(&m)
+		if err != nil {
+			t.Fatalf("ComputeRootDigest failed: %v", err)
+		}
+		m.Files[0].Annotations.Fields["size"] = structpb.NewNumberValue(3)
+		after, err := ComputeRootDigest(&m)
+		if err != nil {
+			t.Fatalf("ComputeRootDigest failed: %v", err)
+		}
+		if (before != after) != include {
+			t.Errorf("MetadataInDigest %v: digest changed %v", include, before != after)
+		}
+	}
+
+	// The setting survives a JSON round trip and reaches the statement
+	data, err := json.Marshal(withMeta)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	var loaded Manifest
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if loadedDigest, err := ComputeRootDigest(&loaded); err != nil || loadedDigest != digest {
+		t.Errorf("Expected %s after a JSON round trip, got %s (%v)", digest, loadedDigest, err)
+	}
+	statement, err := withMeta.ToStatement(PredicateType)
+	if err != nil {
+		t.Fatalf("ToStatement failed: %v", err)
+	}
+	if !statement.GetPredicate().GetFields()["metadataInDigest"].GetBoolValue() {
+		t.Errorf("Expected metadataInDigest in the predicate, got %v", statement.GetPredicate())
+	}
+}
@@ -0,0 +1,67 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build unix
+
+package dir
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/carabiner-dev/model-signing/internal/serializer/options"
+)
+
+func TestSpecialFiles(t *testing.T) {
+	tempDir, expected := newTestManifest(t)
+	if err := syscall.Mkfifo(filepath.Join(tempDir, "pipe"), 0644); err != nil {
+		t.Fatalf("Failed to create named pipe: %v", err)
+	}
+
+	// Special files are rejected by default
+	for _, confine := range []bool{false, true} {
+		opts := options.Default()
+		opts.ConfineToRoot = confine
+		if _, err := New(opts).Serialize(tempDir); !errors.Is(err, ErrUnsupportedFileType) {
+			t.Errorf("ConfineToRoot %v: expected ErrUnsupportedFileType, got %v", confine, err)
+		}
+	}
+	if _, err := New(nil).SerializeFS(os.DirFS(tempDir), "."); !errors.Is(err, ErrUnsupportedFileType) {
+		t.Errorf("Expected ErrUnsupportedFileType from SerializeFS, got %v", err)
+	}
+
+	// Skipped with the option or when ignored
+	for _, opts := range []*options.Options{
+		options.Default().Apply(options.WithSkipSpecialFiles(true)),
+		options.Default().Apply(options.WithIgnorePaths("pipe")),
+	} {
+		manifest, err := New(opts).Serialize(tempDir)
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+		if diff := Compare(expected, manifest); !diff.Empty() {
+			t.Errorf("Unexpected manifest: %v", diff)
+		}
+	}
+
+	// Archives hold devices and named pipes too
+	archive := buildTar(t, []tarEntry{
+		{name: "model.bin", typeflag: tar.TypeReg, content: "weights"},
+		{name: "dev/null", typeflag: tar.TypeChar},
+	})
+	if _, err := SerializeTar(bytes.NewReader(archive), nil); !errors.Is(err, ErrUnsupportedFileType) {
+		t.Errorf("Expected ErrUnsupportedFileType from SerializeTar, got %v", err)
+	}
+	manifest, err := SerializeTar(bytes.NewReader(archive), options.Default().Apply(options.WithSkipSpecialFiles(true)))
+	if err != nil {
+		t.Fatalf("SerializeTar failed: %v", err)
+	}
+	if len(manifest.Files) != 1 {
+		t.Errorf("Expected only model.bin, got %v", manifest.Files)
+	}
+}
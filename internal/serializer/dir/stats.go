@@ -0,0 +1,115 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package dir
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Stats describes the work done serializing a model.
+type Stats struct {
+	// FileCount is the number of files hashed, found in the digest cache
+	// or, by SerializeIncremental, copied from the previous manifest.
+	// Empty directory markers are not counted.
+	FileCount int
+
+	// BytesHashed is the number of bytes read from the files and hashed.
+	// Files found in the digest cache or copied from the previous
+	// manifest are not read.
+	BytesHashed int64
+
+	// FailedFiles is the number of files left out of a partial manifest
+	// because they could not be hashed, under ContinueOnError.
+	FailedFiles int
+
+	// Duration is the time the serialization took.
+	Duration time.Duration
+}
+
+// statsKey is the context key of the stats collector of a serialization.
+type statsKey struct{}
+
+// statsCollector gathers the Stats of a serialization from the hashing
+// workers.
+type statsCollector struct {
+	start time.Time
+	files atomic.Int64
+	bytes atomic.Int64
+
+	mu       sync.Mutex
+	failures []*HashError
+}
+
+// withStats returns a context collecting the stats of the serialization
+// starting now.
+func withStats(ctx context.Context) context.Context {
+	return context.WithValue(ctx, statsKey{}, &statsCollector{start: time.Now()})
+}
+
+// statsFrom returns the stats collector of ctx, nil if it has none.
+func statsFrom(ctx context.Context) *statsCollector {
+	c, _ := ctx.Value(statsKey{}).(*statsCollector)
+	return c
+}
+
+// addFile records a file of which n bytes were hashed.
+func (c *statsCollector) addFile(n int64) {
+	if c == nil {
+		return
+	}
+	c.files.Add(1)
+	c.bytes.Add(n)
+}
+
+// addFailure records a file that could not be hashed and is left out of
+// the manifest.
+func (c *statsCollector) addFailure(err *HashError) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.failures = append(c.failures, err)
+}
+
+// partialErr returns the ErrPartial error joining the failures recorded,
+// sorted by file name, or nil if there were none.
+func (c *statsCollector) partialErr() error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.failures) == 0 {
+		return nil
+	}
+
+	sort.Slice(c.failures, func(i, j int) bool {
+		return c.failures[i].Name < c.failures[j].Name
+	})
+	errs := make([]error, 0, len(c.failures))
+	for _, err := range c.failures {
+		errs = append(errs, err)
+	}
+	return fmt.Errorf("%w: %w: %w", ErrPartial, ErrHashFailed, errors.Join(errs...))
+}
+
+// stats returns the stats collected so far.
+func (c *statsCollector) stats() Stats {
+	if c == nil {
+		return Stats{}
+	}
+	c.mu.Lock()
+	failed := len(c.failures)
+	c.mu.Unlock()
+	return Stats{
+		FileCount:   int(c.files.Load()),
+		BytesHashed: c.bytes.Load(),
+		FailedFiles: failed,
+		Duration:    time.Since(c.start),
+	}
+}
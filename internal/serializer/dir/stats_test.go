@@ -0,0 +1,58 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package dir
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/carabiner-dev/model-signing/internal/serializer/options"
+)
+
+func TestStats(t *testing.T) {
+	tempDir, manifest := newTestManifest(t)
+
+	// model.bin, config.json and subdir/layer.bin
+	if manifest.Stats.FileCount != 3 {
+		t.Errorf("Expected 3 files, got %d", manifest.Stats.FileCount)
+	}
+	if manifest.Stats.BytesHashed != 14 {
+		t.Errorf("Expected 14 bytes hashed, got %d", manifest.Stats.BytesHashed)
+	}
+	if manifest.Stats.Duration <= 0 {
+		t.Errorf("Expected a positive duration, got %v", manifest.Stats.Duration)
+	}
+
+	// Files found in the digest cache are counted but not hashed
+	cacheDir, err := os.MkdirTemp("", "modeldigest-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(cacheDir)
+	opts := options.Default()
+	opts.CachePath = filepath.Join(cacheDir, "digests.json")
+	for _, expected := range []int64{14, 0} {
+		manifest, err := New(opts).Serialize(tempDir)
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+		if manifest.Stats.FileCount != 3 || manifest.Stats.BytesHashed != expected {
+			t.Errorf("Expected 3 files and %d bytes hashed, got %+v", expected, manifest.Stats)
+		}
+	}
+
+	// Archives report their stats too
+	manifest, err = SerializeTar(bytes.NewReader(buildTar(t, []tarEntry{
+		{name: "model.bin", content: "weights", typeflag: tar.TypeReg},
+	})), options.Default())
+	if err != nil {
+		t.Fatalf("SerializeTar failed: %v", err)
+	}
+	if manifest.Stats.FileCount != 1 || manifest.Stats.BytesHashed != 7 {
+		t.Errorf("Expected 1 file and 7 bytes hashed, got %+v", manifest.Stats)
+	}
+}
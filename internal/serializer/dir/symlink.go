@@ -0,0 +1,100 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package dir
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/carabiner-dev/model-signing/internal/serializer/options"
+)
+
+// ErrSymlinkLoop is returned when a directory symlink points to one of
+// the directories containing it, which would make the walk recurse
+// forever.
+var ErrSymlinkLoop = errors.New("symlink loop")
+
+// resolveSymlink follows the symlink at path and returns the path and file
+// info of its target, and whether the target lies within realRoot, the
+// model directory with its own symlinks resolved. External targets are
+// only resolved with followExternal, the path and info are empty
+// otherwise.
+func resolveSymlink(path, realRoot string, followExternal bool) (string, fs.FileInfo, bool, error) {
+	target, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", nil, false, fmt.Errorf("resolving symlink %s: %w", path, err)
+	}
+
+	internal := true
+	rel, err := filepath.Rel(realRoot, target)
+	if err != nil || isExternal(filepath.ToSlash(rel)) {
+		if !followExternal {
+			return "", nil, false, nil
+		}
+		internal = false
+	}
+
+	info, err := os.Stat(target)
+	if err != nil {
+		return "", nil, false, fmt.Errorf("reading symlink target %s: %w", path, err)
+	}
+	return target, info, internal, nil
+}
+
+// checkSymlinkLoop returns ErrSymlinkLoop if target, the directory the
+// symlink at name points to, is one of the directories containing the
+// link. Directories are compared by identity (device and inode on Unix),
+// stat returning the info of the model paths leading to the link.
+func checkSymlinkLoop(name string, target fs.FileInfo, stat func(name string) (fs.FileInfo, error)) error {
+	for dir := path.Dir(name); ; dir = path.Dir(dir) {
+		info, err := stat(dir)
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", dir, err)
+		}
+		if os.SameFile(info, target) {
+			return fmt.Errorf("%w: %s points to %s", ErrSymlinkLoop, name, dir)
+		}
+		if dir == "." {
+			return nil
+		}
+	}
+}
+
+// symlinkPolicy returns the configured symlink policy, derived from the
+// deprecated AllowSymlinks option when not set.
+func (s *Serializer) symlinkPolicy() options.SymlinkPolicy {
+	switch {
+	case s.opts.Strict:
+		return options.SymlinkReject
+	case s.opts.SymlinkPolicy != "":
+		return s.opts.SymlinkPolicy
+	case s.opts.AllowSymlinks:
+		return options.SymlinkFollowInternal
+	default:
+		return options.SymlinkReject
+	}
+}
+
+// validateSymlinkPolicy checks the configured symlink policy is known.
+func (s *Serializer) validateSymlinkPolicy() error {
+	switch policy := s.symlinkPolicy(); policy {
+	case options.SymlinkReject, options.SymlinkFollowInternal, options.SymlinkFollowAll, options.SymlinkRecordLink:
+		return nil
+	default:
+		return fmt.Errorf("unsupported symlink policy %q", policy)
+	}
+}
+
+// externalSymlink returns the error for a symlink pointing outside of the
+// model, or nil if SkipExternalSymlinks is set and it is skipped.
+func (s *Serializer) externalSymlink(path string) error {
+	if s.opts.SkipExternalSymlinks {
+		return nil
+	}
+	return fmt.Errorf("symlink %s points outside of the model directory (use SkipExternalSymlinks option)", path)
+}
@@ -0,0 +1,384 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package dir
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/carabiner-dev/model-signing/internal/serializer/options"
+)
+
+func TestFollowSymlinks(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "modeldigest-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	outside, err := os.MkdirTemp("", "modeldigest-outside-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(outside)
+
+	modelDir := filepath.Join(tempDir, "model")
+	for name, content := range map[string]string{
+		"model.bin":        "weights",
+		"subdir/layer.bin": "layer",
+	} {
+		path := filepath.Join(modelDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create dir for %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test file %s: %v", name, err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(outside, "secret"), []byte("secret"), 0644); err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+
+	if err := os.Symlink("subdir/layer.bin", filepath.Join(modelDir, "link.bin")); err != nil {
+		t.Skipf("Symlinks not supported: %v", err)
+	}
+	if err := os.Symlink("subdir", filepath.Join(modelDir, "dirlink")); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+
+	for _, confined := range []bool{false, true} {
+		opts := options.Default()
+		opts.AllowSymlinks = true
+		opts.ConfineToRoot = confined
+
+		manifest, err := New(opts).Serialize(modelDir)
+		if err != nil {
+			t.Fatalf("Serialize (confined: %v) failed: %v", confined, err)
+		}
+
+		files := map[string]string{}
+		for _, f := range manifest.Files {
+			files[f.Name] = f.Digest["sha256"]
+		}
+		if len(files) != 4 {
+			t.Errorf("Expected 4 files (confined: %v), got %v", confined, files)
+		}
+		if files["link.bin"] == "" || files["link.bin"] != files["subdir/layer.bin"] {
+			t.Errorf("Symlink not hashed as its target (confined: %v): %v", confined, files)
+		}
+		if files["dirlink/layer.bin"] != files["subdir/layer.bin"] {
+			t.Errorf("Directory symlink not followed (confined: %v): %v", confined, files)
+		}
+	}
+
+	// The root itself can be reached through a symlink
+	rootLink := filepath.Join(tempDir, "rootlink")
+	if err := os.Symlink(modelDir, rootLink); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+	opts := options.Default()
+	opts.AllowSymlinks = true
+	if _, err := New(opts).Serialize(rootLink + string(filepath.Separator)); err != nil {
+		t.Errorf("Serialize through a symlinked root failed: %v", err)
+	}
+
+	if err := os.Symlink(filepath.Join(outside, "secret"), filepath.Join(modelDir, "escape")); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+
+	for _, confined := range []bool{false, true} {
+		opts := options.Default()
+		opts.AllowSymlinks = true
+		opts.ConfineToRoot = confined
+		if _, err := New(opts).Serialize(modelDir); err == nil {
+			t.Errorf("Expected error for external symlink (confined: %v)", confined)
+		}
+
+		opts.SkipExternalSymlinks = true
+		manifest, err := New(opts).Serialize(modelDir)
+		if err != nil {
+			t.Fatalf("Serialize with SkipExternalSymlinks (confined: %v) failed: %v", confined, err)
+		}
+		for _, f := range manifest.Files {
+			if f.Name == "escape" {
+				t.Errorf("External symlink was hashed (confined: %v)", confined)
+			}
+		}
+
+		opts = options.Default()
+		opts.AllowSymlinks = true
+		opts.ConfineToRoot = confined
+		opts.IgnorePaths = []string{"escape"}
+		if _, err := New(opts).Serialize(modelDir); err != nil {
+			t.Errorf("Ignored external symlink failed the serialization (confined: %v): %v", confined, err)
+		}
+	}
+}
+
+func TestSymlinkLoop(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		links map[string]string
+	}{
+		{"self", map[string]string{"a/self": "."}},
+		{"root", map[string]string{"a/root": ".."}},
+		{"indirect", map[string]string{"a/to-b": "../b", "b/to-a": "../a"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tempDir, err := os.MkdirTemp("", "modeldigest-test-*")
+			if err != nil {
+				t.Fatalf("Failed to create temp dir: %v", err)
+			}
+			defer os.RemoveAll(tempDir)
+
+			for _, dir := range []string{"a", "b"} {
+				if err := os.MkdirAll(filepath.Join(tempDir, dir), 0755); err != nil {
+					t.Fatalf("Failed to create dir: %v", err)
+				}
+				if err := os.WriteFile(filepath.Join(tempDir, dir, "file"), []byte(dir), 0644); err != nil {
+					t.Fatalf("Failed to create test file: %v", err)
+				}
+			}
+			for link, target := range tc.links {
+				if err := os.Symlink(target, filepath.Join(tempDir, link)); err != nil {
+					t.Skipf("Symlinks not supported: %v", err)
+				}
+			}
+
+			for _, confined := range []bool{false, true} {
+				opts := options.Default()
+				opts.AllowSymlinks = true
+				opts.ConfineToRoot = confined
+				if _, err := New(opts).Serialize(tempDir); !errors.Is(err, ErrSymlinkLoop) {
+					t.Errorf("Expected ErrSymlinkLoop (confined: %v), got %v", confined, err)
+				}
+			}
+		})
+	}
+
+	// Two links to the same directory are not a loop
+	tempDir, err := os.MkdirTemp("", "modeldigest-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	if err := os.MkdirAll(filepath.Join(tempDir, "shared"), 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tempDir, "shared", "file"), []byte("shared"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	for _, link := range []string{"one", "two"} {
+		if err := os.Symlink("shared", filepath.Join(tempDir, link)); err != nil {
+			t.Skipf("Symlinks not supported: %v", err)
+		}
+	}
+
+	opts := options.Default()
+	opts.AllowSymlinks = true
+	manifest, err := New(opts).Serialize(tempDir)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	if len(manifest.Files) != 3 {
+		t.Errorf("Expected 3 files, got %d", len(manifest.Files))
+	}
+}
+
+func TestSymlinkPolicy(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "modeldigest-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	modelDir := filepath.Join(tempDir, "model")
+	outside := filepath.Join(tempDir, "outside")
+	for name, content := range map[string]string{
+		"model/model.bin":    "weights",
+		"outside/vocab.txt":  "vocab",
+		"outside/shared/a.b": "shared",
+	} {
+		path := filepath.Join(tempDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create dir for %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test file %s: %v", name, err)
+		}
+	}
+	if err := os.Symlink("model.bin", filepath.Join(modelDir, "internal.bin")); err != nil {
+		t.Skipf("Symlinks not supported: %v", err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "vocab.txt"), filepath.Join(modelDir, "vocab.txt")); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "shared"), filepath.Join(modelDir, "shared")); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name     string
+		opts     func(*options.Options)
+		expected string
+		err      bool
+	}{
+		{"Default", func(*options.Options) {}, "", true},
+		{"Reject", func(o *options.Options) { o.SymlinkPolicy = options.SymlinkReject; o.AllowSymlinks = true }, "", true},
+		{"FollowInternal", func(o *options.Options) { o.SymlinkPolicy = options.SymlinkFollowInternal }, "", true},
+		{"FollowInternalSkip", func(o *options.Options) {
+			o.SymlinkPolicy = options.SymlinkFollowInternal
+			o.SkipExternalSymlinks = true
+		}, "internal.bin,model.bin", false},
+		{"AllowSymlinks", func(o *options.Options) { o.AllowSymlinks = true; o.SkipExternalSymlinks = true }, "internal.bin,model.bin", false},
+		{"FollowAll", func(o *options.Options) { o.SymlinkPolicy = options.SymlinkFollowAll }, "internal.bin,model.bin,shared/a.b,vocab.txt", false},
+		{"Unknown", func(o *options.Options) { o.SymlinkPolicy = "sometimes" }, "", true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := options.Default()
+			tc.opts(opts)
+
+			manifest, err := New(opts).Serialize(modelDir)
+			if tc.err {
+				if err == nil {
+					t.Fatal("Expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Serialize failed: %v", err)
+			}
+
+			var names []string
+			for _, file := range manifest.Files {
+				names = append(names, file.Name)
+			}
+			if got := strings.Join(names, ","); got != tc.expected {
+				t.Errorf("Expected %s, got %s", tc.expected, got)
+			}
+		})
+	}
+
+	// External targets are hashed like model files
+	opts := options.Default()
+	opts.SymlinkPolicy = options.SymlinkFollowAll
+	manifest, err := New(opts).Serialize(modelDir)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	direct, err := HashReader("vocab.txt", strings.NewReader("vocab"), nil)
+	if err != nil {
+		t.Fatalf("HashReader failed: %v", err)
+	}
+	if got := manifest.Files[3].Digest["sha256"]; got != direct.Digest["sha256"] {
+		t.Errorf("Expected the digest of the link target %s, got %s", direct.Digest["sha256"], got)
+	}
+}
+
+func TestRecordLink(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "modeldigest-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	for name, content := range map[string]string{
+		"model.bin":        "weights",
+		"subdir/layer.bin": "layer",
+	} {
+		path := filepath.Join(tempDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create dir for %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test file %s: %v", name, err)
+		}
+	}
+	links := map[string]string{
+		"latest.bin": "model.bin",
+		"snapshot":   "subdir",
+		"dangling":   "missing.bin",
+		"external":   "../../etc/passwd",
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(tempDir, name)); err != nil {
+			t.Skipf("Symlinks not supported: %v", err)
+		}
+	}
+
+	for _, confined := range []bool{false, true} {
+		opts := options.Default()
+		opts.SymlinkPolicy = options.SymlinkRecordLink
+		opts.ConfineToRoot = confined
+		manifest, err := New(opts).Serialize(tempDir)
+		if err != nil {
+			t.Fatalf("Serialize (confined: %v) failed: %v", confined, err)
+		}
+
+		var names []string
+		for _, file := range manifest.Files {
+			names = append(names, file.Name)
+			target, ok := links[file.Name]
+			if !ok {
+				continue
+			}
+			sum := sha256.Sum256([]byte(target))
+			if got := file.Digest["sha256"]; got != hex.EncodeToString(sum[:]) {
+				t.Errorf("%s: expected the digest of %q, got %s", file.Name, target, got)
+			}
+			if got := file.GetAnnotations().GetFields()[AnnotationLinkTarget].GetStringValue(); got != target {
+				t.Errorf("%s: expected target annotation %q, got %q", file.Name, target, got)
+			}
+		}
+		expected := "dangling,external,latest.bin,model.bin,snapshot,subdir/layer.bin"
+		if got := strings.Join(names, ","); got != expected {
+			t.Errorf("Confined %v: expected %s, got %s", confined, expected, got)
+		}
+	}
+
+	// Archives record their links the same way
+	opts := options.Default()
+	opts.SymlinkPolicy = options.SymlinkRecordLink
+	expected, err := New(opts).Serialize(tempDir)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	entries := []tarEntry{
+		{name: "model.bin", content: "weights", typeflag: tar.TypeReg},
+		{name: "subdir/layer.bin", content: "layer", typeflag: tar.TypeReg},
+	}
+	for name, target := range links {
+		entries = append(entries, tarEntry{name: name, typeflag: tar.TypeSymlink, linkname: target})
+	}
+	manifest, err := SerializeTar(bytes.NewReader(buildTar(t, entries)), opts)
+	if err != nil {
+		t.Fatalf("SerializeTar failed: %v", err)
+	}
+	if diff := Compare(expected, manifest); !diff.Empty() {
+		t.Errorf("Archive manifest differs: %+v", diff)
+	}
+
+	// Retargeting a link changes its digest
+	if err := os.Remove(filepath.Join(tempDir, "latest.bin")); err != nil {
+		t.Fatalf("Failed to remove link: %v", err)
+	}
+	if err := os.Symlink("subdir/layer.bin", filepath.Join(tempDir, "latest.bin")); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+	retargeted, err := New(opts).Serialize(tempDir)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	if diff := Compare(expected, retargeted); len(diff.Modified) != 1 || diff.Modified[0].Name != "latest.bin" {
+		t.Errorf("Expected latest.bin to be modified, got %+v", diff)
+	}
+}
@@ -0,0 +1,456 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package dir
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/carabiner-dev/model-signing/internal/serializer/options"
+	intoto "github.com/in-toto/attestation/go/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// SerializeTar serializes the model stored in the tar archive read from
+// r without extracting it. Entry names are treated as paths relative to
+// the model root, so the manifest (and its root digest) is the same one
+// Serialize produces for the directory the archive extracts to, whatever
+// the order of the entries. ModelName is left empty as archives carry no
+// model name.
+//
+// Regular files and hard links are hashed, later entries replacing
+// earlier ones with the same name as tar extraction does. Ignore rules
+// are applied once the whole archive is read, as .gitignore files can
+// come after the entries they match. Symbolic links are rejected under
+// the SymlinkReject policy and recorded under SymlinkRecordLink. Under
+// the follow policies, once the archive is read, links to files of the
+// archive are hashed as their target; links leaving the archive are
+// handled as external ones, and fail under SymlinkFollowAll as their
+// target cannot be read. Links to directories are an error. Device and named pipe
+// entries fail with ErrUnsupportedFileType unless SkipSpecialFiles is set.
+// Entry names escaping the archive root are an error.
+func SerializeTar(r io.Reader, opts *options.Options) (*Manifest, error) {
+	s := New(opts)
+	ctx := withStats(context.Background())
+
+	model, err := s.newTarModel(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := model.readTar(tar.NewReader(r), 0, false); err != nil {
+		return nil, err
+	}
+	return model.manifest()
+}
+
+// tarModel gathers the entries of one or more tar streams, the layers of
+// the model, into its manifest. Entries of later layers replace earlier
+// ones with the same name, as extraction does.
+type tarModel struct {
+	s      *Serializer
+	ctx    context.Context
+	rules  *ignoreRules
+	limits *limits
+
+	files  map[string][]*intoto.ResourceDescriptor
+	infos  map[string]fs.FileInfo
+	layers map[string]int
+
+	// ignoreFiles holds the contents of the .gitignore and
+	// IgnoreFileName entries read, by name, when they are respected.
+	ignoreFiles map[string][]byte
+
+	// special holds the type of the device and named pipe entries,
+	// checked once the ignore rules are known.
+	special map[string]fs.FileMode
+
+	// symlinks holds the targets of the symlink entries to follow, by
+	// name, resolved once the whole archive is read.
+	symlinks map[string]string
+}
+
+// newTarModel validates the serializer options and returns an empty
+// model to read tar streams into.
+func (s *Serializer) newTarModel(ctx context.Context) (*tarModel, error) {
+	if err := s.validateAlgorithms(); err != nil {
+		return nil, err
+	}
+	if err := s.validateSymlinkPolicy(); err != nil {
+		return nil, err
+	}
+	if err := s.validateMethod(); err != nil {
+		return nil, err
+	}
+	if err := s.validateDigestEncoding(); err != nil {
+		return nil, err
+	}
+
+	rules, err := s.newIgnoreRules("")
+	if err != nil {
+		return nil, err
+	}
+
+	return &tarModel{
+		s:           s,
+		ctx:         ctx,
+		rules:       rules,
+		limits:      s.newLimits(),
+		files:       map[string][]*intoto.ResourceDescriptor{},
+		infos:       map[string]fs.FileInfo{},
+		layers:      map[string]int{},
+		ignoreFiles: map[string][]byte{},
+		special:     map[string]fs.FileMode{},
+		symlinks:    map[string]string{},
+	}, nil
+}
+
+// readTar reads the entries of tr as the given layer of the model. With
+// whiteouts, the OCI whiteout entries of the layer remove the entries of
+// the layers below instead of being hashed.
+func (m *tarModel) readTar(tr *tar.Reader, layer int, whiteouts bool) error {
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reading tar archive: %w", err)
+		}
+
+		name, err := tarEntryName(hdr.Name)
+		if err != nil {
+			return err
+		}
+
+		if whiteouts && strings.HasPrefix(path.Base(name), whiteoutPrefix) {
+			m.whiteout(name, layer)
+			continue
+		}
+
+		if err := m.addEntry(name, hdr, tr, layer); err != nil {
+			return err
+		}
+	}
+}
+
+// addEntry adds the tar entry name of the given layer, reading the
+// contents of regular files from r.
+func (m *tarModel) addEntry(name string, hdr *tar.Header, r io.Reader, layer int) error {
+	s, ctx, files := m.s, m.ctx, m.files
+	m.infos[name] = hdr.FileInfo()
+	delete(m.special, name)
+	delete(m.symlinks, name)
+
+	var err error
+	switch hdr.Typeflag {
+	case tar.TypeReg:
+		if m.limits != nil {
+			if err := m.limits.add(name, hdr.Size); err != nil {
+				return err
+			}
+		}
+
+		data := r
+		if (s.opts.RespectGitignore && path.Base(name) == ".gitignore") || (s.opts.RespectIgnoreFile && name == IgnoreFileName) {
+			contents, err := io.ReadAll(r)
+			if err != nil {
+				return fmt.Errorf("reading %s: %w", name, err)
+			}
+			m.ignoreFiles[name] = contents
+			data = bytes.NewReader(contents)
+		}
+
+		descriptors, err := s.hashFile(ctx, name, func(string) (io.ReadCloser, error) {
+			return &tarFile{Reader: data, hdr: hdr}, nil
+		})
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrHashFailed, &HashError{Name: name, Err: err})
+		}
+		files[name] = descriptors
+
+	case tar.TypeDir:
+		if s.opts.RecordEmptyDirs && name != "." {
+			marker := name + "/"
+			files[marker], err = s.hashFile(ctx, marker, nil)
+			if err != nil {
+				return fmt.Errorf("%w: %w", ErrHashFailed, &HashError{Name: marker, Err: err})
+			}
+			m.layers[marker] = layer
+		}
+
+	case tar.TypeLink:
+		target, err := tarEntryName(hdr.Linkname)
+		if err != nil {
+			return err
+		}
+		descriptors, ok := files[target]
+		if !ok {
+			return fmt.Errorf("hard link %s points to missing entry %s", name, target)
+		}
+		files[name] = renameDescriptors(descriptors, target, name)
+
+	case tar.TypeChar, tar.TypeBlock, tar.TypeFifo:
+		delete(files, name)
+		m.special[name] = hdr.FileInfo().Mode().Type()
+
+	case tar.TypeSymlink:
+		switch s.symlinkPolicy() {
+		case options.SymlinkReject:
+			return &SymlinkError{Path: name}
+		case options.SymlinkRecordLink:
+			target := hdr.Linkname
+			files[name], err = s.hashFile(ctx, name, func(string) (io.ReadCloser, error) {
+				return newLinkFile(target, hdr.FileInfo()), nil
+			})
+			if err != nil {
+				return fmt.Errorf("%w: %w", ErrHashFailed, &HashError{Name: name, Err: err})
+			}
+		default:
+			delete(files, name)
+			m.symlinks[name] = hdr.Linkname
+		}
+	}
+
+	m.layers[name] = layer
+	return nil
+}
+
+// manifest applies the ignore rules to the entries read and returns the
+// manifest of the model.
+func (m *tarModel) manifest() (*Manifest, error) {
+	s, rules := m.s, m.rules
+
+	// Apply the ignore files parents first, as a directory walk would
+	seen := map[string]struct{}{}
+	dirs := make([]string, 0, len(m.ignoreFiles))
+	for name := range m.ignoreFiles {
+		if _, ok := seen[path.Dir(name)]; !ok {
+			seen[path.Dir(name)] = struct{}{}
+			dirs = append(dirs, path.Dir(name))
+		}
+	}
+	sort.Slice(dirs, func(i, j int) bool {
+		if dirs[i] == "." || dirs[j] == "." {
+			return dirs[i] == "." && dirs[j] != "."
+		}
+		return dirs[i] < dirs[j]
+	})
+	for _, dir := range dirs {
+		if err := rules.loadIgnoreFiles(dir, func(name string) ([]byte, error) {
+			contents, ok := m.ignoreFiles[name]
+			if !ok {
+				return nil, fs.ErrNotExist
+			}
+			return contents, nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := m.followSymlinks(); err != nil {
+		return nil, err
+	}
+
+	special := make([]string, 0, len(m.special))
+	for name := range m.special {
+		special = append(special, name)
+	}
+	sort.Strings(special)
+	for _, name := range special {
+		if rules.match(name, false) || !rules.included(name) {
+			continue
+		}
+		keep, err := rules.keep(name, func() (fs.FileInfo, error) { return m.infos[name], nil })
+		if err != nil {
+			return nil, err
+		}
+		if keep {
+			if err := s.specialFile(name, m.special[name]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var fileDescriptors []*intoto.ResourceDescriptor
+	for name, descriptors := range m.files {
+		entry := strings.TrimSuffix(name, "/")
+		if rules.match(entry, isDirMarker(name)) || !rules.included(name) {
+			continue
+		}
+		keep, err := rules.keep(entry, func() (fs.FileInfo, error) { return m.infos[entry], nil })
+		if err != nil {
+			return nil, err
+		}
+		if !keep {
+			continue
+		}
+		fileDescriptors = append(fileDescriptors, descriptors...)
+	}
+
+	manifest, err := s.newManifest("", fileDescriptors)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.finishManifest(m.ctx, manifest); err != nil {
+		return nil, err
+	}
+
+	return manifest, statsFrom(m.ctx).partialErr()
+}
+
+// followSymlinks adds the symlinks to follow as the entries they point
+// to, as the directory the archive extracts to would be walked. Ignored
+// links are not resolved.
+func (m *tarModel) followSymlinks() error {
+	s, rules := m.s, m.rules
+
+	names := make([]string, 0, len(m.symlinks))
+	for name := range m.symlinks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if rules.match(name, false) || !rules.included(name) {
+			continue
+		}
+		target, internal, err := m.resolveSymlink(name)
+		if err != nil {
+			return fmt.Errorf("resolving symlink %s: %w", name, err)
+		}
+		if !internal {
+			if s.symlinkPolicy() == options.SymlinkFollowAll {
+				return fmt.Errorf("symlink %s points outside of the archive", name)
+			}
+			if err := s.externalSymlink(name); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if descriptors, ok := m.files[target]; ok {
+			m.files[name] = renameDescriptors(descriptors, target, name)
+			m.infos[name] = m.infos[target]
+			continue
+		}
+		if mode, ok := m.special[target]; ok {
+			m.special[name] = mode
+			m.infos[name] = m.infos[target]
+			continue
+		}
+		if m.isDir(target) {
+			return fmt.Errorf("symlink %s points to the directory %s, which cannot be followed in archives", name, target)
+		}
+		return fmt.Errorf("symlink %s points to missing entry %s", name, target)
+	}
+	return nil
+}
+
+// resolveSymlink resolves the symlink entry name, following every link
+// found on the way to its target, and returns the target and whether it
+// is in the archive. Absolute targets and ".." components above the
+// archive root leave it.
+func (m *tarModel) resolveSymlink(name string) (string, bool, error) {
+	pending, resolved, hops := strings.Split(name, "/"), ".", 0
+	for len(pending) > 0 {
+		part := pending[0]
+		pending = pending[1:]
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			if resolved == "." {
+				return "", false, nil
+			}
+			resolved = path.Dir(resolved)
+			continue
+		}
+
+		next := path.Join(resolved, part)
+		target, ok := m.symlinks[next]
+		if !ok {
+			resolved = next
+			continue
+		}
+		if hops++; hops > maxLinkHops {
+			return "", false, ErrSymlinkLoop
+		}
+		if path.IsAbs(target) {
+			return "", false, nil
+		}
+		pending = append(strings.Split(target, "/"), pending...)
+	}
+	return resolved, true, nil
+}
+
+// isDir returns whether name is a directory of the archive, read as an
+// entry or holding some.
+func (m *tarModel) isDir(name string) bool {
+	if name == "." {
+		return true
+	}
+	if info, ok := m.infos[name]; ok && info.IsDir() {
+		return true
+	}
+	for entry := range m.files {
+		if strings.HasPrefix(entry, name+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// SerializeTarGz serializes the model stored in the gzip-compressed tar
+// archive read from r, decompressing it on the fly. The manifest is the
+// one SerializeTar produces for the uncompressed archive.
+func SerializeTarGz(r io.Reader, opts *options.Options) (*Manifest, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("reading gzip stream: %w", err)
+	}
+	defer zr.Close()
+
+	return SerializeTar(zr, opts)
+}
+
+// tarEntryName returns the path relative to the model root of a tar entry
+// name, refusing names that escape the archive root.
+func tarEntryName(name string) (string, error) {
+	clean := path.Clean(strings.TrimPrefix(name, "./"))
+	if !fs.ValidPath(clean) {
+		return "", fmt.Errorf("unsafe tar entry name %q", name)
+	}
+	return clean, nil
+}
+
+// renameDescriptors copies the descriptors of the file target (one per
+// shard) for the file name.
+func renameDescriptors(descriptors []*intoto.ResourceDescriptor, target, name string) []*intoto.ResourceDescriptor {
+	renamed := make([]*intoto.ResourceDescriptor, 0, len(descriptors))
+	for _, descriptor := range descriptors {
+		d := proto.Clone(descriptor).(*intoto.ResourceDescriptor)
+		d.Name = name + strings.TrimPrefix(d.Name, target)
+		renamed = append(renamed, d)
+	}
+	return renamed
+}
+
+// tarFile exposes a tar entry to hashFile, reporting the file mode of its
+// header.
+type tarFile struct {
+	io.Reader
+	hdr *tar.Header
+}
+
+func (f *tarFile) Close() error { return nil }
+
+func (f *tarFile) Stat() (fs.FileInfo, error) { return f.hdr.FileInfo(), nil }
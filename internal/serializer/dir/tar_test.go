@@ -0,0 +1,325 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package dir
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/carabiner-dev/model-signing/internal/serializer/options"
+)
+
+// tarEntry is an entry written by buildTar.
+type tarEntry struct {
+	name     string
+	content  string
+	typeflag byte
+	linkname string
+}
+
+// buildTar returns a tar archive with the entries in order.
+func buildTar(t *testing.T, entries []tarEntry) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		hdr := &tar.Header{
+			Name:     e.name,
+			Mode:     0644,
+			Size:     int64(len(e.content)),
+			Typeflag: e.typeflag,
+			Linkname: e.linkname,
+		}
+		if e.typeflag != tar.TypeReg {
+			hdr.Size = 0
+		}
+		if e.typeflag == tar.TypeDir {
+			hdr.Mode = 0755
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("Failed to write tar header: %v", err)
+		}
+		if hdr.Size > 0 {
+			if _, err := tw.Write([]byte(e.content)); err != nil {
+				t.Fatalf("Failed to write tar entry: %v", err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("Failed to close tar writer: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestSerializeTar(t *testing.T) {
+	entries := []tarEntry{
+		{name: "./", typeflag: tar.TypeDir},
+		{name: "./model.bin", content: "weights", typeflag: tar.TypeReg},
+		{name: "./config.json", content: "{}", typeflag: tar.TypeReg},
+		{name: "./subdir/", typeflag: tar.TypeDir},
+		{name: "./subdir/layer.bin", content: "layer", typeflag: tar.TypeReg},
+		{name: "./subdir/.gitignore", content: "*.log\n", typeflag: tar.TypeReg},
+		{name: "./subdir/train.log", content: "log", typeflag: tar.TypeReg},
+		{name: "./.git/config", content: "git config", typeflag: tar.TypeReg},
+		{name: "./logs/run.txt", content: "run", typeflag: tar.TypeReg},
+		{name: "./cache/", typeflag: tar.TypeDir},
+	}
+
+	tempDir, err := os.MkdirTemp("", "modeldigest-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// Extract the archive by hand
+	for _, e := range entries {
+		path := filepath.Join(tempDir, filepath.FromSlash(e.name))
+		if e.typeflag == tar.TypeDir {
+			if err := os.MkdirAll(path, 0755); err != nil {
+				t.Fatalf("Failed to create dir %s: %v", e.name, err)
+			}
+			continue
+		}
+		if e.typeflag != tar.TypeReg {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create dir for %s: %v", e.name, err)
+		}
+		if err := os.WriteFile(path, []byte(e.content), 0644); err != nil {
+			t.Fatalf("Failed to create test file %s: %v", e.name, err)
+		}
+	}
+
+	for _, tc := range []struct {
+		name string
+		opts func(*options.Options)
+	}{
+		{"Default", func(*options.Options) {}},
+		{"IgnorePaths", func(o *options.Options) { o.IgnorePaths = []string{"logs/", "config.json"} }},
+		{"RespectGitignore", func(o *options.Options) { o.RespectGitignore = true }},
+		{"RecordPermissions", func(o *options.Options) { o.RecordPermissions = true; o.RecordSizes = true }},
+		{"RecordEmptyDirs", func(o *options.Options) { o.RecordEmptyDirs = true }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := options.Default()
+			tc.opts(opts)
+
+			expected, err := New(opts).Serialize(tempDir)
+			if err != nil {
+				t.Fatalf("Serialize failed: %v", err)
+			}
+
+			manifest, err := SerializeTar(bytes.NewReader(buildTar(t, entries)), opts)
+			if err != nil {
+				t.Fatalf("SerializeTar failed: %v", err)
+			}
+
+			if len(manifest.Files) != len(expected.Files) {
+				t.Fatalf("Expected %d files, got %d", len(expected.Files), len(manifest.Files))
+			}
+			for i := range manifest.Files {
+				if manifest.Files[i].String() != expected.Files[i].String() {
+					t.Errorf("File %d: expected %v, got %v", i, expected.Files[i], manifest.Files[i])
+				}
+			}
+
+			expectedDigest, err := ComputeRootDigest(expected)
+			if err != nil {
+				t.Fatalf("ComputeRootDigest failed: %v", err)
+			}
+			digest, err := ComputeRootDigest(manifest)
+			if err != nil {
+				t.Fatalf("ComputeRootDigest failed: %v", err)
+			}
+			if digest != expectedDigest {
+				t.Errorf("Tar digest %s does not match extracted %s", digest, expectedDigest)
+			}
+		})
+	}
+
+	t.Run("EntryOrder", func(t *testing.T) {
+		forward, err := SerializeTar(bytes.NewReader(buildTar(t, entries)), nil)
+		if err != nil {
+			t.Fatalf("SerializeTar failed: %v", err)
+		}
+
+		reversed := make([]tarEntry, 0, len(entries))
+		for i := len(entries) - 1; i >= 0; i-- {
+			reversed = append(reversed, entries[i])
+		}
+		backward, err := SerializeTar(bytes.NewReader(buildTar(t, reversed)), nil)
+		if err != nil {
+			t.Fatalf("SerializeTar failed: %v", err)
+		}
+
+		d1, err := ComputeRootDigest(forward)
+		if err != nil {
+			t.Fatalf("ComputeRootDigest failed: %v", err)
+		}
+		d2, err := ComputeRootDigest(backward)
+		if err != nil {
+			t.Fatalf("ComputeRootDigest failed: %v", err)
+		}
+		if d1 != d2 {
+			t.Errorf("Entry order changed the digest: %s != %s", d1, d2)
+		}
+	})
+
+	t.Run("HardLink", func(t *testing.T) {
+		manifest, err := SerializeTar(bytes.NewReader(buildTar(t, []tarEntry{
+			{name: "model.bin", content: "weights", typeflag: tar.TypeReg},
+			{name: "copy.bin", typeflag: tar.TypeLink, linkname: "model.bin"},
+		})), nil)
+		if err != nil {
+			t.Fatalf("SerializeTar failed: %v", err)
+		}
+		if len(manifest.Files) != 2 || manifest.Files[0].Name != "copy.bin" {
+			t.Fatalf("Expected copy.bin and model.bin, got %v", manifest.Files)
+		}
+		if manifest.Files[0].Digest["sha256"] != manifest.Files[1].Digest["sha256"] {
+			t.Error("Hard link digest does not match its target")
+		}
+	})
+
+	t.Run("Symlinks", func(t *testing.T) {
+		archive := buildTar(t, []tarEntry{
+			{name: "model.bin", content: "weights", typeflag: tar.TypeReg},
+			{name: "link.bin", typeflag: tar.TypeSymlink, linkname: "model.bin"},
+		})
+		if _, err := SerializeTar(bytes.NewReader(archive), nil); err == nil {
+			t.Error("Expected error for symlink entry")
+		}
+
+		opts := options.Default()
+		opts.AllowSymlinks = true
+		manifest, err := SerializeTar(bytes.NewReader(archive), opts)
+		if err != nil {
+			t.Fatalf("SerializeTar failed: %v", err)
+		}
+		if len(manifest.Files) != 2 || manifest.Files[0].Name != "link.bin" {
+			t.Fatalf("Expected link.bin and model.bin, got %v", manifest.Files)
+		}
+		if manifest.Files[0].Digest["sha256"] != manifest.Files[1].Digest["sha256"] {
+			t.Error("Symlink digest does not match its target")
+		}
+
+		// The archive hashes as the directory it extracts to
+		entries := []tarEntry{
+			{name: "subdir/link.bin", typeflag: tar.TypeSymlink, linkname: "../chain.bin"},
+			{name: "chain.bin", typeflag: tar.TypeSymlink, linkname: "model.bin"},
+			{name: "model.bin", content: "weights", typeflag: tar.TypeReg},
+			{name: "subdir/layer.bin", content: "layer", typeflag: tar.TypeReg},
+		}
+		tempDir := t.TempDir()
+		for _, e := range entries {
+			p := filepath.Join(tempDir, filepath.FromSlash(e.name))
+			if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+				t.Fatalf("Failed to create directory: %v", err)
+			}
+			if e.typeflag == tar.TypeSymlink {
+				err = os.Symlink(filepath.FromSlash(e.linkname), p)
+			} else {
+				err = os.WriteFile(p, []byte(e.content), 0o644)
+			}
+			if err != nil {
+				t.Fatalf("Failed to extract %s: %v", e.name, err)
+			}
+		}
+		for _, policy := range []options.SymlinkPolicy{options.SymlinkFollowInternal, options.SymlinkFollowAll} {
+			opts := options.Default().Apply(options.WithSymlinkPolicy(policy))
+			expected, err := New(opts).Serialize(tempDir)
+			if err != nil {
+				t.Fatalf("Serialize failed: %v", err)
+			}
+			manifest, err := SerializeTar(bytes.NewReader(buildTar(t, entries)), opts)
+			if err != nil {
+				t.Fatalf("SerializeTar failed: %v", err)
+			}
+			if diff := Compare(expected, manifest); !diff.Empty() {
+				t.Errorf("%s: SerializeTar differs from Serialize: %+v", policy, diff)
+			}
+		}
+
+		for _, tc := range []struct {
+			name     string
+			linkname string
+		}{
+			{"external", "../outside.bin"},
+			{"absolute", "/etc/passwd"},
+			{"directory", "subdir"},
+			{"missing", "missing.bin"},
+			{"loop", "link.bin"},
+		} {
+			archive := buildTar(t, []tarEntry{
+				{name: "subdir/layer.bin", content: "layer", typeflag: tar.TypeReg},
+				{name: "link.bin", typeflag: tar.TypeSymlink, linkname: tc.linkname},
+			})
+			if _, err := SerializeTar(bytes.NewReader(archive), opts); err == nil {
+				t.Errorf("%s: expected error for symlink to %s", tc.name, tc.linkname)
+			}
+		}
+
+		// External links can be skipped, and ignored ones are not resolved
+		archive = buildTar(t, []tarEntry{
+			{name: "model.bin", content: "weights", typeflag: tar.TypeReg},
+			{name: "link.bin", typeflag: tar.TypeSymlink, linkname: "../outside.bin"},
+		})
+		for _, opt := range []options.Option{options.WithSkipExternalSymlinks(true), options.WithIgnorePaths("link.bin")} {
+			opts := options.Default().Apply(options.WithSymlinkPolicy(options.SymlinkFollowInternal), opt)
+			manifest, err := SerializeTar(bytes.NewReader(archive), opts)
+			if err != nil {
+				t.Fatalf("SerializeTar failed: %v", err)
+			}
+			if len(manifest.Files) != 1 {
+				t.Errorf("Expected 1 file, got %v", manifest.Files)
+			}
+		}
+	})
+
+	t.Run("UnsafeNames", func(t *testing.T) {
+		for _, name := range []string{"../escape.bin", "/abs.bin", "a/../../b"} {
+			archive := buildTar(t, []tarEntry{{name: name, content: "x", typeflag: tar.TypeReg}})
+			if _, err := SerializeTar(bytes.NewReader(archive), nil); err == nil {
+				t.Errorf("Expected error for entry %q", name)
+			}
+		}
+	})
+}
+
+func TestSerializeTarGz(t *testing.T) {
+	entries := []tarEntry{
+		{name: "model.bin", content: "weights", typeflag: tar.TypeReg},
+		{name: "config.json", content: "{}", typeflag: tar.TypeReg},
+		{name: "subdir/", typeflag: tar.TypeDir},
+		{name: "subdir/layer.bin", content: "layer", typeflag: tar.TypeReg},
+	}
+	_, expected := newTestManifest(t)
+
+	manifest, err := SerializeTarGz(bytes.NewReader(gzipData(t, buildTar(t, entries), gzip.BestCompression)), options.Default())
+	if err != nil {
+		t.Fatalf("SerializeTarGz failed: %v", err)
+	}
+	expectedDigest, err := ComputeRootDigest(expected)
+	if err != nil {
+		t.Fatalf("ComputeRootDigest failed: %v", err)
+	}
+	digest, err := ComputeRootDigest(manifest)
+	if err != nil {
+		t.Fatalf("ComputeRootDigest failed: %v", err)
+	}
+	if digest != expectedDigest {
+		t.Errorf("Expected root digest %s, got %s", expectedDigest, digest)
+	}
+
+	// A plain tar archive is not a gzip stream
+	if _, err := SerializeTarGz(bytes.NewReader(buildTar(t, entries)), options.Default()); err == nil {
+		t.Error("Expected error serializing an uncompressed archive")
+	}
+}
@@ -0,0 +1,82 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package dir
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/carabiner-dev/model-signing/internal/serializer/options"
+	intoto "github.com/in-toto/attestation/go/v1"
+)
+
+// ErrInvalidManifest is returned by Validate, wrapping every problem found
+// in the manifest.
+var ErrInvalidManifest = errors.New("invalid manifest")
+
+// Validate checks the manifest as a whole before its digests are used:
+// its hash algorithm, root digest mode and digest encoding must be
+// supported and every file must have a name, unique in the manifest, and
+// a digest of the manifest algorithm. Digests of the algorithms known to
+// the package must be in the manifest encoding (hex by default) and of
+// the length the algorithm produces, digests of
+// unknown ones are not checked. All the problems found are reported in
+// the error, which wraps ErrInvalidManifest.
+func (m *Manifest) Validate() error {
+	var errs []error
+
+	algo := m.algorithm()
+	if newHasher(algo) == nil {
+		errs = append(errs, fmt.Errorf("unsupported hash algorithm %q", algo))
+	}
+	if mode := m.rootDigestMode(); mode != options.RootDigestConcat && mode != options.RootDigestNameAndLength {
+		errs = append(errs, fmt.Errorf("unsupported root digest mode %q", mode))
+	}
+	enc := m.digestEncoding()
+	if _, err := encodeDigest(enc, nil); err != nil {
+		errs = append(errs, err)
+		enc = ""
+	}
+
+	sizes := map[string]int{}
+	names := make(map[string]struct{}, len(m.Files))
+	for i, file := range m.Files {
+		name := file.GetName()
+		if name == "" {
+			errs = append(errs, fmt.Errorf("file %d has no name", i))
+		} else if _, ok := names[name]; ok {
+			errs = append(errs, fmt.Errorf("%w: %q", ErrDuplicateName, name))
+		}
+		names[name] = struct{}{}
+
+		if _, ok := file.GetDigest()[string(algo)]; !ok {
+			errs = append(errs, fmt.Errorf("%s digest not found for %s", algo, name))
+		}
+		for a, digest := range file.GetDigest() {
+			size, ok := sizes[a]
+			if !ok {
+				if h := newHasher(intoto.HashAlgorithm(a)); h != nil {
+					size = h.Size()
+				}
+				sizes[a] = size
+			}
+			if size == 0 || enc == "" {
+				continue
+			}
+			raw, err := decodeDigest(enc, digest)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("invalid %s digest of %s: %w", a, name, err))
+				continue
+			}
+			if len(raw) != size {
+				errs = append(errs, fmt.Errorf("invalid %s digest of %s: %d bytes, expected %d", a, name, len(raw), size))
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%w: %w", ErrInvalidManifest, errors.Join(errs...))
+	}
+	return nil
+}
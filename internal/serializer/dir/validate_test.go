@@ -0,0 +1,64 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package dir
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	intoto "github.com/in-toto/attestation/go/v1"
+)
+
+func TestValidate(t *testing.T) {
+	_, manifest := newTestManifest(t)
+	if err := manifest.Validate(); err != nil {
+		t.Fatalf("Validate failed on a serialized manifest: %v", err)
+	}
+
+	digest := manifest.Files[0].Digest["sha256"]
+	for _, tc := range []struct {
+		name     string
+		files    []*intoto.ResourceDescriptor
+		problems []string
+	}{
+		{"EmptyName", []*intoto.ResourceDescriptor{{Digest: map[string]string{"sha256": digest}}}, []string{"file 0 has no name"}},
+		{"Duplicate", []*intoto.ResourceDescriptor{
+			{Name: "a.bin", Digest: map[string]string{"sha256": digest}},
+			{Name: "a.bin", Digest: map[string]string{"sha256": digest}},
+		}, []string{`duplicate file name: "a.bin"`}},
+		{"Missing", []*intoto.ResourceDescriptor{{Name: "a.bin", Digest: map[string]string{"sha512": digest}}}, []string{"sha256 digest not found for a.bin", "invalid sha512 digest of a.bin"}},
+		{"NotHex", []*intoto.ResourceDescriptor{{Name: "a.bin", Digest: map[string]string{"sha256": "zz"}}}, []string{"invalid sha256 digest of a.bin"}},
+		{"Length", []*intoto.ResourceDescriptor{{Name: "a.bin", Digest: map[string]string{"sha256": "abcd"}}}, []string{"2 bytes, expected 32"}},
+		{"All", []*intoto.ResourceDescriptor{
+			{Name: "a.bin", Digest: map[string]string{"sha256": "abcd"}},
+			{Name: "b.bin", Digest: map[string]string{"sha256": "zz"}},
+			{Name: "", Digest: map[string]string{"sha256": digest}},
+		}, []string{"invalid sha256 digest of a.bin", "invalid sha256 digest of b.bin", "file 2 has no name"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			m := &Manifest{ModelName: "model", Files: tc.files}
+			err := m.Validate()
+			if !errors.Is(err, ErrInvalidManifest) {
+				t.Fatalf("Expected ErrInvalidManifest, got %v", err)
+			}
+			for _, problem := range tc.problems {
+				if !strings.Contains(err.Error(), problem) {
+					t.Errorf("Error does not report %q: %v", problem, err)
+				}
+			}
+
+			// ComputeRootDigest reports the same problems
+			if _, err := ComputeRootDigest(m); !errors.Is(err, ErrInvalidManifest) {
+				t.Errorf("Expected ComputeRootDigest to fail with ErrInvalidManifest, got %v", err)
+			}
+		})
+	}
+
+	// Digests of unknown algorithms are not checked
+	m := &Manifest{Files: []*intoto.ResourceDescriptor{{Name: "a.bin", Digest: map[string]string{"sha256": digest, "custom": "?"}}}}
+	if err := m.Validate(); err != nil {
+		t.Errorf("Validate failed: %v", err)
+	}
+}
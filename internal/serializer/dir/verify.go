@@ -0,0 +1,223 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package dir
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/carabiner-dev/model-signing/internal/serializer/options"
+	intoto "github.com/in-toto/attestation/go/v1"
+)
+
+var (
+	// ErrFileNotInManifest is returned by VerifyFile for a file the
+	// manifest does not list.
+	ErrFileNotInManifest = errors.New("file not in manifest")
+
+	// ErrRootDigestMismatch is returned by VerifyRootDigest when the model
+	// does not have the expected root digest.
+	ErrRootDigestMismatch = errors.New("root digest mismatch")
+)
+
+// FileMismatch describes a file whose digest on disk differs from the one
+// recorded in the manifest. Digests are in algorithm:hash format.
+type FileMismatch struct {
+	Name     string
+	Expected string
+	Actual   string
+}
+
+// VerificationError lists the differences found between a model
+// directory and the manifest it was verified against.
+type VerificationError struct {
+	// Added are files on disk missing from the manifest.
+	Added []string
+
+	// Removed are files in the manifest missing from disk.
+	Removed []string
+
+	// Modified are files whose contents changed.
+	Modified []FileMismatch
+
+	// Permissions are files whose recorded mode changed. Expected and
+	// Actual hold octal modes.
+	Permissions []FileMismatch
+}
+
+func (e *VerificationError) Error() string {
+	parts := []string{}
+	if len(e.Added) > 0 {
+		parts = append(parts, "added: "+strings.Join(e.Added, ", "))
+	}
+	if len(e.Removed) > 0 {
+		parts = append(parts, "removed: "+strings.Join(e.Removed, ", "))
+	}
+	if len(e.Modified) > 0 {
+		modified := make([]string, 0, len(e.Modified))
+		for _, m := range e.Modified {
+			modified = append(modified, fmt.Sprintf("%s (expected %s, got %s)", m.Name, m.Expected, m.Actual))
+		}
+		parts = append(parts, "modified: "+strings.Join(modified, ", "))
+	}
+	if len(e.Permissions) > 0 {
+		changed := make([]string, 0, len(e.Permissions))
+		for _, m := range e.Permissions {
+			changed = append(changed, fmt.Sprintf("%s (expected %s, got %s)", m.Name, m.Expected, m.Actual))
+		}
+		parts = append(parts, "permissions changed: "+strings.Join(changed, ", "))
+	}
+	return "model does not match manifest: " + strings.Join(parts, "; ")
+}
+
+// Verify serializes the model directory with the serializer options and
+// compares it file by file against a previously produced manifest. Files
+// are hashed with the manifest hash algorithm and digest encoding. File
+// modes are compared when the manifest recorded them. When the directory
+// does not match, the returned error is a *VerificationError. Manifests
+// with file names that are absolute or hold ".." components fail with
+// ErrUnsafePath before the model is read.
+func (s *Serializer) Verify(modelPath string, manifest *Manifest) error {
+	for _, file := range manifest.Files {
+		if err := checkSafeName(file.Name); err != nil {
+			return err
+		}
+	}
+
+	opts := *s.opts
+	opts.HashAlgorithm = manifest.algorithm()
+	opts.DigestEncoding = manifest.digestEncoding()
+	opts.Algorithms = nil
+
+	modes := map[string]string{}
+	for _, file := range manifest.Files {
+		if mode, ok := file.GetAnnotations().GetFields()[AnnotationMode]; ok {
+			modes[file.Name] = mode.GetStringValue()
+		}
+	}
+	if len(modes) > 0 {
+		opts.RecordPermissions = true
+	}
+
+	current, err := New(&opts).Serialize(modelPath)
+	if err != nil {
+		return fmt.Errorf("serializing model: %w", err)
+	}
+
+	algo := string(manifest.algorithm())
+	expected := make(map[string]string, len(manifest.Files))
+	for _, file := range manifest.Files {
+		expected[file.Name] = file.Digest[algo]
+	}
+
+	verr := &VerificationError{}
+	seen := make(map[string]struct{}, len(current.Files))
+	for _, file := range current.Files {
+		seen[file.Name] = struct{}{}
+		want, ok := expected[file.Name]
+		if !ok {
+			verr.Added = append(verr.Added, file.Name)
+			continue
+		}
+		if got := file.Digest[algo]; got != want {
+			verr.Modified = append(verr.Modified, FileMismatch{
+				Name:     file.Name,
+				Expected: algo + ":" + want,
+				Actual:   algo + ":" + got,
+			})
+		}
+		if wantMode, ok := modes[file.Name]; ok {
+			gotMode := file.GetAnnotations().GetFields()[AnnotationMode].GetStringValue()
+			if gotMode != wantMode {
+				verr.Permissions = append(verr.Permissions, FileMismatch{
+					Name:     file.Name,
+					Expected: wantMode,
+					Actual:   gotMode,
+				})
+			}
+		}
+	}
+	for _, file := range manifest.Files {
+		if _, ok := seen[file.Name]; !ok {
+			verr.Removed = append(verr.Removed, file.Name)
+		}
+	}
+
+	if len(verr.Added) > 0 || len(verr.Removed) > 0 || len(verr.Modified) > 0 || len(verr.Permissions) > 0 {
+		return verr
+	}
+	return nil
+}
+
+// VerifyFile hashes the contents of the model file name read from r and
+// compares them with the digest the manifest records for it, so a file
+// received on its own can be checked without the rest of the model. name
+// is the manifest name, a shard is then verified from its own bytes
+// under its "<file>:<start>:<end>" name. Files recorded decompressed are
+// decompressed the same way before hashing. Names the manifest does not
+// list fail with ErrFileNotInManifest, different contents with a
+// *VerificationError listing the file as modified.
+func VerifyFile(manifest *Manifest, name string, r io.Reader) error {
+	recorded, ok := manifest.GetFile(name)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrFileNotInManifest, name)
+	}
+
+	opts := options.Default()
+	opts.HashAlgorithm = manifest.algorithm()
+	opts.DigestEncoding = manifest.digestEncoding()
+	if compression, ok := recorded.GetAnnotations().GetFields()[AnnotationDecompressed]; ok {
+		opts.DecompressExtensions = map[string]options.Compression{
+			name: options.Compression(compression.GetStringValue()),
+		}
+	}
+
+	descriptor, err := HashReader(name, r, opts)
+	if err != nil {
+		return fmt.Errorf("hashing %s: %w", name, err)
+	}
+
+	algo := string(manifest.algorithm())
+	if want, got := recorded.Digest[algo], descriptor.Digest[algo]; got != want {
+		return &VerificationError{Modified: []FileMismatch{{
+			Name:     name,
+			Expected: algo + ":" + want,
+			Actual:   algo + ":" + got,
+		}}}
+	}
+	return nil
+}
+
+// VerifyRootDigest serializes the model at modelPath and compares its root
+// digest with expected, as found in a signature, when no manifest is at
+// hand. expected is in algorithm:hash format, its algorithm replacing the
+// HashAlgorithm of the options; a bare hash is computed with the options
+// algorithm. The hash must be in the DigestEncoding of the options. A
+// different digest fails with an error wrapping ErrRootDigestMismatch
+// that holds both digests. Unlike Verify, it cannot tell which files
+// changed.
+func VerifyRootDigest(modelPath, expected string, opts *options.Options) error {
+	if opts == nil {
+		opts = options.Default()
+	}
+	o := *opts
+	if algo, _, ok := strings.Cut(expected, ":"); ok {
+		o.HashAlgorithm = intoto.HashAlgorithm(algo)
+	} else {
+		expected = string(New(&o).algorithm()) + ":" + expected
+	}
+
+	actual, err := ComputeDigest(modelPath, &o)
+	if err != nil {
+		return fmt.Errorf("serializing model: %w", err)
+	}
+	// Only hex digests are case-insensitive
+	hexDigest := o.DigestEncoding == "" || o.DigestEncoding == options.DigestEncodingHex
+	if actual != expected && (!hexDigest || !strings.EqualFold(actual, expected)) {
+		return fmt.Errorf("%w: expected %s, got %s", ErrRootDigestMismatch, expected, actual)
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package dir
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/carabiner-dev/model-signing/internal/serializer/options"
+)
+
+func TestVerify(t *testing.T) {
+	tempDir, manifest := newTestManifest(t)
+	serializer := New(options.Default())
+
+	if err := serializer.Verify(tempDir, manifest); err != nil {
+		t.Fatalf("Verify failed on unchanged model: %v", err)
+	}
+
+	// Git files are ignored on verification too
+	if err := os.WriteFile(filepath.Join(tempDir, ".gitignore"), []byte("*.tmp"), 0644); err != nil {
+		t.Fatalf("Failed to create .gitignore: %v", err)
+	}
+	if err := serializer.Verify(tempDir, manifest); err != nil {
+		t.Fatalf("Verify failed with ignored files: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(tempDir, "model.bin"), []byte("tampered"), 0644); err != nil {
+		t.Fatalf("Failed to modify model.bin: %v", err)
+	}
+	if err := os.Remove(filepath.Join(tempDir, "config.json")); err != nil {
+		t.Fatalf("Failed to remove config.json: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tempDir, "extra.bin"), []byte("extra"), 0644); err != nil {
+		t.Fatalf("Failed to create extra.bin: %v", err)
+	}
+
+	err := serializer.Verify(tempDir, manifest)
+	var verr *VerificationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("Expected VerificationError, got %v", err)
+	}
+
+	if len(verr.Added) != 1 || verr.Added[0] != "extra.bin" {
+		t.Errorf("Expected extra.bin added, got %v", verr.Added)
+	}
+	if len(verr.Removed) != 1 || verr.Removed[0] != "config.json" {
+		t.Errorf("Expected config.json removed, got %v", verr.Removed)
+	}
+	if len(verr.Modified) != 1 || verr.Modified[0].Name != "model.bin" {
+		t.Fatalf("Expected model.bin modified, got %v", verr.Modified)
+	}
+	if verr.Modified[0].Expected != "sha256:"+manifest.Files[1].Digest["sha256"] {
+		t.Errorf("Unexpected expected digest %s", verr.Modified[0].Expected)
+	}
+	if !strings.Contains(err.Error(), verr.Modified[0].Actual) {
+		t.Errorf("Error does not include the actual digest: %v", err)
+	}
+}
+
+func TestVerifyFile(t *testing.T) {
+	_, manifest := newTestManifest(t)
+
+	if err := VerifyFile(manifest, "subdir/layer.bin", strings.NewReader("layer")); err != nil {
+		t.Errorf("Expected the file to verify, got %v", err)
+	}
+
+	err := VerifyFile(manifest, "subdir/layer.bin", strings.NewReader("tampered"))
+	var verr *VerificationError
+	if !errors.As(err, &verr) || len(verr.Modified) != 1 || verr.Modified[0].Name != "subdir/layer.bin" {
+		t.Errorf("Expected subdir/layer.bin to be modified, got %v", err)
+	}
+
+	if err := VerifyFile(manifest, "missing.bin", strings.NewReader("")); !errors.Is(err, ErrFileNotInManifest) {
+		t.Errorf("Expected ErrFileNotInManifest, got %v", err)
+	}
+
+	// Shards verify from their own bytes
+	tempDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tempDir, "model.bin"), []byte("0123456789"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	sharded, err := NewWithOptions(options.WithShardSize(4)).Serialize(tempDir)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	if err := VerifyFile(sharded, ShardName("model.bin", 4, 8), strings.NewReader("4567")); err != nil {
+		t.Errorf("Expected the shard to verify, got %v", err)
+	}
+}
+
+func TestVerifyRootDigest(t *testing.T) {
+	tempDir, _ := newTestManifest(t)
+
+	digest, err := ComputeDigest(tempDir, nil)
+	if err != nil {
+		t.Fatalf("ComputeDigest failed: %v", err)
+	}
+	if err := VerifyRootDigest(tempDir, digest, nil); err != nil {
+		t.Errorf("Expected the digest to verify, got %v", err)
+	}
+	if err := VerifyRootDigest(tempDir, strings.TrimPrefix(digest, "sha256:"), nil); err != nil {
+		t.Errorf("Expected the bare digest to verify, got %v", err)
+	}
+
+	// The algorithm of the expected digest wins over the options
+	opts := options.Default()
+	opts.HashAlgorithm = "sha512"
+	digest512, err := ComputeDigest(tempDir, opts)
+	if err != nil {
+		t.Fatalf("ComputeDigest failed: %v", err)
+	}
+	if err := VerifyRootDigest(tempDir, digest512, nil); err != nil {
+		t.Errorf("Expected the sha512 digest to verify, got %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(tempDir, "model.bin"), []byte("tampered"), 0644); err != nil {
+		t.Fatalf("Failed to modify test file: %v", err)
+	}
+	err = VerifyRootDigest(tempDir, digest, nil)
+	if !errors.Is(err, ErrRootDigestMismatch) {
+		t.Fatalf("Expected ErrRootDigestMismatch, got %v", err)
+	}
+	if !strings.Contains(err.Error(), digest) {
+		t.Errorf("Error does not include the expected digest: %v", err)
+	}
+}
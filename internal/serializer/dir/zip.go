@@ -0,0 +1,42 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package dir
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+
+	"github.com/carabiner-dev/model-signing/internal/serializer/options"
+)
+
+// SerializeZip serializes the model stored in the zip archive of the given
+// size read from r without extracting it. As with SerializeTar, entry
+// names are treated as paths relative to the model root, so the manifest
+// is the one Serialize produces for the directory the archive extracts
+// to, and ModelName is left empty. Entry names escaping the archive root
+// are an error.
+func SerializeZip(r io.ReaderAt, size int64, opts *options.Options) (*Manifest, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("reading zip archive: %w", err)
+	}
+
+	// The fs.FS view of the archive silently drops unsafe names
+	for _, f := range zr.File {
+		if !fs.ValidPath(path.Clean(f.Name)) {
+			return nil, fmt.Errorf("unsafe zip entry name %q", f.Name)
+		}
+	}
+
+	manifest, err := New(opts).SerializeFS(zr, ".")
+	if manifest == nil {
+		return nil, err
+	}
+	manifest.ModelName = ""
+
+	return manifest, err
+}
@@ -0,0 +1,129 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package dir
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/carabiner-dev/model-signing/internal/serializer/options"
+)
+
+// buildZip returns a zip archive with the given entries, names ending in
+// a slash being directories.
+func buildZip(t *testing.T, entries map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range entries {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("Failed to create zip entry %s: %v", name, err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatalf("Failed to write zip entry %s: %v", name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Failed to close zip writer: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestSerializeZip(t *testing.T) {
+	entries := map[string]string{
+		"model.bin":           "weights",
+		"config.json":         "{}",
+		"subdir/":             "",
+		"subdir/layer.bin":    "layer",
+		"subdir/.gitignore":   "*.log\n",
+		"subdir/train.log":    "log",
+		".git/config":         "git config",
+		"logs/run.txt":        "run",
+		"cache/":              "",
+		"tokenizer/vocab.txt": "vocab",
+	}
+
+	tempDir, err := os.MkdirTemp("", "modeldigest-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// Extract the archive by hand
+	for name, content := range entries {
+		path := filepath.Join(tempDir, filepath.FromSlash(name))
+		if strings.HasSuffix(name, "/") {
+			if err := os.MkdirAll(path, 0755); err != nil {
+				t.Fatalf("Failed to create dir %s: %v", name, err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create dir for %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test file %s: %v", name, err)
+		}
+	}
+
+	archive := buildZip(t, entries)
+	for _, tc := range []struct {
+		name string
+		opts func(*options.Options)
+	}{
+		{"Default", func(*options.Options) {}},
+		{"IgnorePaths", func(o *options.Options) { o.IgnorePaths = []string{"logs/", "config.json"} }},
+		{"RespectGitignore", func(o *options.Options) { o.RespectGitignore = true }},
+		{"RecordEmptyDirs", func(o *options.Options) { o.RecordEmptyDirs = true }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := options.Default()
+			tc.opts(opts)
+
+			expected, err := New(opts).Serialize(tempDir)
+			if err != nil {
+				t.Fatalf("Serialize failed: %v", err)
+			}
+
+			manifest, err := SerializeZip(bytes.NewReader(archive), int64(len(archive)), opts)
+			if err != nil {
+				t.Fatalf("SerializeZip failed: %v", err)
+			}
+			if manifest.ModelName != "" {
+				t.Errorf("Expected empty model name, got %q", manifest.ModelName)
+			}
+
+			expected.ModelName = ""
+			if diff := Compare(expected, manifest); !diff.Empty() {
+				t.Errorf("Manifests differ: %v", diff)
+			}
+
+			expectedDigest, err := ComputeRootDigest(expected)
+			if err != nil {
+				t.Fatalf("ComputeRootDigest failed: %v", err)
+			}
+			digest, err := ComputeRootDigest(manifest)
+			if err != nil {
+				t.Fatalf("ComputeRootDigest failed: %v", err)
+			}
+			if digest != expectedDigest {
+				t.Errorf("Expected root digest %s, got %s", expectedDigest, digest)
+			}
+		})
+	}
+}
+
+func TestSerializeZipUnsafeName(t *testing.T) {
+	for _, name := range []string{"../escape.bin", "/abs.bin", "subdir/../../escape.bin"} {
+		archive := buildZip(t, map[string]string{"model.bin": "weights", name: "escape"})
+		if _, err := SerializeZip(bytes.NewReader(archive), int64(len(archive)), options.Default()); err == nil {
+			t.Errorf("Expected error for entry name %s", name)
+		}
+	}
+}
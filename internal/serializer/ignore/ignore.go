@@ -0,0 +1,212 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+// Package ignore implements gitignore-style path matching.
+//
+// Patterns follow the gitignore(5) rules: blank lines and lines starting
+// with # are skipped, a leading ! negates the pattern, a trailing / only
+// matches directories and a pattern containing a / elsewhere is anchored
+// to its base directory while one without it matches at any depth. The *,
+// ? and [...] wildcards never match a /, a ** segment matches any number
+// of directories. The last matching pattern wins and, as in git, a path
+// inside an ignored directory cannot be re-included.
+package ignore
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Pattern is a single compiled gitignore pattern.
+type Pattern struct {
+	base     string
+	segments []string
+	negate   bool
+	dirOnly  bool
+}
+
+// ParsePattern compiles a gitignore pattern line scoped to the base
+// directory (a slash-separated path relative to the matching root, empty
+// for the root itself). It returns nil for blank and comment lines.
+func ParsePattern(line, base string) (*Pattern, error) {
+	if !strings.HasSuffix(line, `\ `) {
+		line = strings.TrimRight(line, " ")
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return nil, nil
+	}
+
+	p := &Pattern{base: strings.Trim(base, "/")}
+	if strings.HasPrefix(line, "!") {
+		p.negate = true
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	if line == "" {
+		return nil, nil
+	}
+
+	for _, segment := range strings.Split(line, "/") {
+		if segment == "" {
+			continue
+		}
+		if segment == "**" && len(p.segments) > 0 && p.segments[len(p.segments)-1] == "**" {
+			continue
+		}
+		if _, err := path.Match(segment, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", line, err)
+		}
+		p.segments = append(p.segments, segment)
+	}
+
+	if !anchored && p.segments[0] != "**" {
+		p.segments = append([]string{"**"}, p.segments...)
+	}
+
+	return p, nil
+}
+
+// Escape returns a pattern line matching the slash-separated path
+// literally: the wildcard and escape characters and the spaces it holds
+// are escaped, so they match themselves. Its caller decides whether the
+// line is anchored.
+func Escape(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		if strings.ContainsRune(`\*?[ `, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Match returns true if the pattern matches the path given as its
+// slash-separated components relative to the matching root.
+func (p *Pattern) Match(parts []string, isDir bool) bool {
+	if p.dirOnly && !isDir {
+		return false
+	}
+	if p.base != "" {
+		baseParts := strings.Split(p.base, "/")
+		if len(parts) <= len(baseParts) {
+			return false
+		}
+		for i := range baseParts {
+			if parts[i] != baseParts[i] {
+				return false
+			}
+		}
+		parts = parts[len(baseParts):]
+	}
+	return matchSegments(p.segments, parts)
+}
+
+// matchSegments matches the path components against the pattern
+// segments, expanding ** to any number of components.
+func matchSegments(pattern, parts []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			rest := pattern[1:]
+			// A trailing ** matches everything inside, not the directory
+			if len(rest) == 0 {
+				return len(parts) > 0
+			}
+			for i := 0; i <= len(parts); i++ {
+				if matchSegments(rest, parts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(parts) == 0 {
+			return false
+		}
+		// Patterns are validated when parsed
+		if ok, _ := path.Match(pattern[0], parts[0]); !ok { //nolint:errcheck
+			return false
+		}
+		pattern, parts = pattern[1:], parts[1:]
+	}
+	return len(parts) == 0
+}
+
+// Matcher matches paths against an ordered list of patterns.
+type Matcher struct {
+	// IgnoreCase matches the patterns ignoring the case of letters, as on
+	// case-insensitive file systems. It must be set before adding patterns.
+	IgnoreCase bool
+
+	patterns []*Pattern
+}
+
+// New returns a Matcher for the pattern lines, scoped to the root.
+func New(lines []string) (*Matcher, error) {
+	m := &Matcher{}
+	if err := m.Add("", lines); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Add appends the pattern lines scoped to the base directory, as found
+// in a .gitignore file of that directory. Patterns added later take
+// precedence over earlier ones.
+func (m *Matcher) Add(base string, lines []string) error {
+	if m.IgnoreCase {
+		base = strings.ToLower(base)
+	}
+	for _, line := range lines {
+		if m.IgnoreCase {
+			line = strings.ToLower(line)
+		}
+		p, err := ParsePattern(line, base)
+		if err != nil {
+			return err
+		}
+		if p != nil {
+			m.patterns = append(m.patterns, p)
+		}
+	}
+	return nil
+}
+
+// Match returns true if the slash-separated path, relative to the
+// matching root, is ignored. A path is ignored if any of its parent
+// directories is.
+func (m *Matcher) Match(name string, isDir bool) bool {
+	name = strings.Trim(name, "/")
+	if m == nil || name == "" || name == "." {
+		return false
+	}
+	if m.IgnoreCase {
+		name = strings.ToLower(name)
+	}
+
+	parts := strings.Split(name, "/")
+	for i := 1; i < len(parts); i++ {
+		if m.match(parts[:i], true) {
+			return true
+		}
+	}
+	return m.match(parts, isDir)
+}
+
+// match applies the patterns in order, the last matching one wins.
+func (m *Matcher) match(parts []string, isDir bool) bool {
+	ignored := false
+	for _, p := range m.patterns {
+		if p.Match(parts, isDir) {
+			ignored = !p.negate
+		}
+	}
+	return ignored
+}
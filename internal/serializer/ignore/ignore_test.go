@@ -0,0 +1,126 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package ignore
+
+import (
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		patterns []string
+		path     string
+		isDir    bool
+		expect   bool
+	}{
+		{"extension", []string{"*.bin"}, "model.bin", false, true},
+		{"extension-nested", []string{"*.bin"}, "a/b/model.bin", false, true},
+		{"extension-miss", []string{"*.bin"}, "model.json", false, false},
+		{"star-no-slash", []string{"a*c"}, "ab/c", false, false},
+		{"question", []string{"file?.txt"}, "file1.txt", false, true},
+		{"class", []string{"file[0-9].txt"}, "filex.txt", false, false},
+		{"dir-only", []string{"logs/"}, "logs", true, true},
+		{"dir-only-file", []string{"logs/"}, "logs", false, false},
+		{"dir-only-child", []string{"logs/"}, "logs/run.txt", false, true},
+		{"dir-only-nested", []string{"logs/"}, "a/logs/run.txt", false, true},
+		{"double-star-prefix", []string{"**/tmp"}, "a/b/tmp", true, true},
+		{"double-star-prefix-root", []string{"**/tmp"}, "tmp", false, true},
+		{"double-star-middle", []string{"a/**/b"}, "a/b", false, true},
+		{"double-star-middle-deep", []string{"a/**/b"}, "a/x/y/b", false, true},
+		{"double-star-suffix", []string{"a/**"}, "a/x/y", false, true},
+		{"double-star-suffix-self", []string{"a/**"}, "a", true, false},
+		{"anchored", []string{"/model.bin"}, "sub/model.bin", false, false},
+		{"anchored-root", []string{"/model.bin"}, "model.bin", false, true},
+		{"middle-slash-anchored", []string{"sub/model.bin"}, "x/sub/model.bin", false, false},
+		{"component", []string{"/data"}, "database.bin", false, false},
+		{"component-dir", []string{"/data"}, "data/file", false, true},
+		{"negation", []string{"*.bin", "!model.bin"}, "model.bin", false, false},
+		{"negation-other", []string{"*.bin", "!model.bin"}, "other.bin", false, true},
+		{"negation-order", []string{"!model.bin", "*.bin"}, "model.bin", false, true},
+		{"negation-parent-excluded", []string{"logs/", "!logs/keep.txt"}, "logs/keep.txt", false, true},
+		{"comment", []string{"# *.bin"}, "model.bin", false, false},
+		{"escaped-hash", []string{`\#file`}, "#file", false, true},
+		{"escaped-bang", []string{`\!file`}, "!file", false, true},
+		{"trailing-space", []string{"*.bin  "}, "model.bin", false, true},
+		{"root", []string{"**"}, ".", true, false},
+		{"escape-wildcards", []string{"/" + Escape("w*[1]?")}, "w*[1]?", false, true},
+		{"escape-wildcards-miss", []string{"/" + Escape("w*")}, "wx", false, false},
+		{"escape-backslash", []string{"/" + Escape(`a\b`)}, `a\b`, false, true},
+		{"escape-trailing-space", []string{"/" + Escape("name  ")}, "name  ", false, true},
+		{"escape-trailing-space-miss", []string{"/" + Escape("name  ")}, "name", false, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			m, err := New(tc.patterns)
+			if err != nil {
+				t.Fatalf("New failed: %v", err)
+			}
+			if got := m.Match(tc.path, tc.isDir); got != tc.expect {
+				t.Errorf("Match(%q) with %v: expected %v, got %v", tc.path, tc.patterns, tc.expect, got)
+			}
+		})
+	}
+}
+
+func TestAddScoped(t *testing.T) {
+	m, err := New(nil)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if err := m.Add("sub", []string{"*.tmp", "/local.txt"}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	for path, expect := range map[string]bool{
+		"sub/a.tmp":         true,
+		"sub/deep/a.tmp":    true,
+		"a.tmp":             false,
+		"other/a.tmp":       false,
+		"sub/local.txt":     true,
+		"sub/x/local.txt":   false,
+		"subdir/a.tmp":      false,
+		"sub":               false,
+		"sub/not-a-tmp.txt": false,
+	} {
+		if got := m.Match(path, false); got != expect {
+			t.Errorf("Match(%q): expected %v, got %v", path, expect, got)
+		}
+	}
+}
+
+func TestInvalidPattern(t *testing.T) {
+	if _, err := New([]string{"[unterminated"}); err == nil {
+		t.Error("Expected error for invalid pattern")
+	}
+}
+
+func TestIgnoreCase(t *testing.T) {
+	m := &Matcher{IgnoreCase: true}
+	if err := m.Add("Sub", []string{"README.md", "*.BIN", "!Keep.bin"}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	for _, tc := range []struct {
+		path   string
+		expect bool
+	}{
+		{"sub/readme.md", true},
+		{"SUB/ReadMe.MD", true},
+		{"sub/model.bin", true},
+		{"sub/keep.BIN", false},
+		{"readme.md", false},
+	} {
+		if got := m.Match(tc.path, false); got != tc.expect {
+			t.Errorf("Match(%q) = %v, expected %v", tc.path, got, tc.expect)
+		}
+	}
+
+	sensitive, err := New([]string{"README.md"})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if sensitive.Match("readme.md", false) {
+		t.Error("Case-sensitive matcher ignored readme.md")
+	}
+}
@@ -0,0 +1,265 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package options
+
+import (
+	"os"
+
+	intoto "github.com/in-toto/attestation/go/v1"
+)
+
+// Option sets one of the Options, for building them inline with
+// functional options instead of filling the struct.
+type Option func(*Options)
+
+// Apply sets the options on o, in order.
+func (o *Options) Apply(opts ...Option) *Options {
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithIgnorePaths adds paths to IgnorePaths.
+func WithIgnorePaths(paths ...string) Option {
+	return func(o *Options) { o.IgnorePaths = append(o.IgnorePaths, paths...) }
+}
+
+// WithIncludePatterns adds patterns to IncludePatterns.
+func WithIncludePatterns(patterns ...string) Option {
+	return func(o *Options) { o.IncludePatterns = append(o.IncludePatterns, patterns...) }
+}
+
+// WithIgnoreExtensions adds extensions to IgnoreExtensions.
+func WithIgnoreExtensions(exts ...string) Option {
+	return func(o *Options) { o.IgnoreExtensions = append(o.IgnoreExtensions, exts...) }
+}
+
+// WithAllowExternalIgnorePaths sets AllowExternalIgnorePaths.
+func WithAllowExternalIgnorePaths(allow bool) Option {
+	return func(o *Options) { o.AllowExternalIgnorePaths = allow }
+}
+
+// WithCaseInsensitiveIgnores sets CaseInsensitiveIgnores.
+func WithCaseInsensitiveIgnores(insensitive bool) Option {
+	return func(o *Options) { o.CaseInsensitiveIgnores = insensitive }
+}
+
+// WithIgnoreGitPaths sets IgnoreGitPaths.
+func WithIgnoreGitPaths(ignore bool) Option {
+	return func(o *Options) { o.IgnoreGitPaths = ignore }
+}
+
+// WithVCSIgnorePaths sets VCSIgnorePaths, calling it without paths
+// ignores no VCS path at all.
+func WithVCSIgnorePaths(paths ...string) Option {
+	return func(o *Options) { o.VCSIgnorePaths = append([]string{}, paths...) }
+}
+
+// WithSkipHidden sets SkipHidden.
+func WithSkipHidden(skip bool) Option {
+	return func(o *Options) { o.SkipHidden = skip }
+}
+
+// WithRecordEmptyDirs sets RecordEmptyDirs.
+func WithRecordEmptyDirs(record bool) Option {
+	return func(o *Options) { o.RecordEmptyDirs = record }
+}
+
+// WithRespectGitignore sets RespectGitignore.
+func WithRespectGitignore(respect bool) Option {
+	return func(o *Options) { o.RespectGitignore = respect }
+}
+
+// WithRespectIgnoreFile sets RespectIgnoreFile.
+func WithRespectIgnoreFile(respect bool) Option {
+	return func(o *Options) { o.RespectIgnoreFile = respect }
+}
+
+// WithSymlinkPolicy sets SymlinkPolicy.
+func WithSymlinkPolicy(policy SymlinkPolicy) Option {
+	return func(o *Options) { o.SymlinkPolicy = policy }
+}
+
+// WithAllowSymlinks sets SymlinkPolicy to SymlinkFollowInternal when
+// allow is true, to SymlinkReject otherwise.
+func WithAllowSymlinks(allow bool) Option {
+	return func(o *Options) {
+		o.SymlinkPolicy = SymlinkReject
+		if allow {
+			o.SymlinkPolicy = SymlinkFollowInternal
+		}
+	}
+}
+
+// WithSkipExternalSymlinks sets SkipExternalSymlinks.
+func WithSkipExternalSymlinks(skip bool) Option {
+	return func(o *Options) { o.SkipExternalSymlinks = skip }
+}
+
+// WithSkipSpecialFiles sets SkipSpecialFiles.
+func WithSkipSpecialFiles(skip bool) Option {
+	return func(o *Options) { o.SkipSpecialFiles = skip }
+}
+
+// WithHuggingFaceSnapshot sets HuggingFaceSnapshot.
+func WithHuggingFaceSnapshot(snapshot bool) Option {
+	return func(o *Options) { o.HuggingFaceSnapshot = snapshot }
+}
+
+// WithModelName sets ModelName.
+func WithModelName(name string) Option {
+	return func(o *Options) { o.ModelName = name }
+}
+
+// WithConfineToRoot sets ConfineToRoot.
+func WithConfineToRoot(confine bool) Option {
+	return func(o *Options) { o.ConfineToRoot = confine }
+}
+
+// WithExternalFile adds the file at path to ExternalFiles under name.
+func WithExternalFile(name, path string) Option {
+	return func(o *Options) {
+		if o.ExternalFiles == nil {
+			o.ExternalFiles = map[string]string{}
+		}
+		o.ExternalFiles[name] = path
+	}
+}
+
+// WithPostHash sets PostHash.
+func WithPostHash(fn func(name, digest string) (include bool, err error)) Option {
+	return func(o *Options) { o.PostHash = fn }
+}
+
+// WithFilterFunc sets FilterFunc.
+func WithFilterFunc(fn func(path string, info os.FileInfo) (keep bool, err error)) Option {
+	return func(o *Options) { o.FilterFunc = fn }
+}
+
+// WithOnWalkError sets OnWalkError.
+func WithOnWalkError(fn func(path string, err error) error) Option {
+	return func(o *Options) { o.OnWalkError = fn }
+}
+
+// WithResourceAnnotator sets ResourceAnnotator.
+func WithResourceAnnotator(fn func(path string, info os.FileInfo) map[string]any) Option {
+	return func(o *Options) { o.ResourceAnnotator = fn }
+}
+
+// WithNameNormalization sets NameNormalization.
+func WithNameNormalization(form Normalization) Option {
+	return func(o *Options) { o.NameNormalization = form }
+}
+
+// WithDecompressExtension decompresses the files ending in ext with the
+// compression format before hashing them.
+func WithDecompressExtension(ext string, compression Compression) Option {
+	return func(o *Options) {
+		if o.DecompressExtensions == nil {
+			o.DecompressExtensions = map[string]Compression{}
+		}
+		o.DecompressExtensions[ext] = compression
+	}
+}
+
+// WithHashAlgorithm sets HashAlgorithm.
+func WithHashAlgorithm(algo intoto.HashAlgorithm) Option {
+	return func(o *Options) { o.HashAlgorithm = algo }
+}
+
+// WithHasher sets Hasher.
+func WithHasher(hasher Hasher) Option {
+	return func(o *Options) { o.Hasher = hasher }
+}
+
+// WithRootDigestMode sets RootDigestMode.
+func WithRootDigestMode(mode RootDigestMode) Option {
+	return func(o *Options) { o.RootDigestMode = mode }
+}
+
+// WithDigestEncoding sets DigestEncoding.
+func WithDigestEncoding(encoding DigestEncoding) Option {
+	return func(o *Options) { o.DigestEncoding = encoding }
+}
+
+// WithIncludeMetadataInDigest sets IncludeMetadataInDigest.
+func WithIncludeMetadataInDigest(include bool) Option {
+	return func(o *Options) { o.IncludeMetadataInDigest = include }
+}
+
+// WithAlgorithms adds algorithms to Algorithms.
+func WithAlgorithms(algos ...intoto.HashAlgorithm) Option {
+	return func(o *Options) { o.Algorithms = append(o.Algorithms, algos...) }
+}
+
+// WithConcurrency sets Concurrency.
+func WithConcurrency(workers int) Option {
+	return func(o *Options) { o.Concurrency = workers }
+}
+
+// WithLargestFirst sets LargestFirst.
+func WithLargestFirst(largestFirst bool) Option {
+	return func(o *Options) { o.LargestFirst = largestFirst }
+}
+
+// WithUseMmap sets UseMmap.
+func WithUseMmap(use bool) Option {
+	return func(o *Options) { o.UseMmap = use }
+}
+
+// WithCachePath sets CachePath.
+func WithCachePath(path string) Option {
+	return func(o *Options) { o.CachePath = path }
+}
+
+// WithMaxFiles sets MaxFiles.
+func WithMaxFiles(limit int) Option {
+	return func(o *Options) { o.MaxFiles = limit }
+}
+
+// WithMaxTotalBytes sets MaxTotalBytes.
+func WithMaxTotalBytes(limit int64) Option {
+	return func(o *Options) { o.MaxTotalBytes = limit }
+}
+
+// WithMaxFileSize sets MaxFileSize.
+func WithMaxFileSize(size int64) Option {
+	return func(o *Options) { o.MaxFileSize = size }
+}
+
+// WithRecordSizes sets RecordSizes.
+func WithRecordSizes(record bool) Option {
+	return func(o *Options) { o.RecordSizes = record }
+}
+
+// WithMethod sets Method.
+func WithMethod(method Method) Option {
+	return func(o *Options) { o.Method = method }
+}
+
+// WithShardSize sets ShardSize.
+func WithShardSize(size int64) Option {
+	return func(o *Options) { o.ShardSize = size }
+}
+
+// WithRecordPermissions sets RecordPermissions.
+func WithRecordPermissions(record bool) Option {
+	return func(o *Options) { o.RecordPermissions = record }
+}
+
+// WithRejectControlChars sets RejectControlChars.
+func WithRejectControlChars(reject bool) Option {
+	return func(o *Options) { o.RejectControlChars = reject }
+}
+
+// WithContinueOnError sets ContinueOnError.
+func WithContinueOnError(continueOnError bool) Option {
+	return func(o *Options) { o.ContinueOnError = continueOnError }
+}
+
+// WithStrict sets Strict.
+func WithStrict(strict bool) Option {
+	return func(o *Options) { o.Strict = strict }
+}
@@ -3,26 +3,527 @@
 
 package options
 
+import (
+	"hash"
+	"os"
+
+	intoto "github.com/in-toto/attestation/go/v1"
+)
+
+// AlgorithmBLAKE3 selects the 256-bit BLAKE3 hash, much faster than SHA256
+// on large files. Its digests are recorded under the "blake3" key.
+const AlgorithmBLAKE3 intoto.HashAlgorithm = "blake3"
+
+// Compression identifies a compression format that can be removed before
+// hashing a file.
+type Compression string
+
+// CompressionGzip decompresses gzip streams.
+const CompressionGzip Compression = "gzip"
+
+// RootDigestMode selects how the file hashes are combined into the root
+// digest.
+type RootDigestMode string
+
+const (
+	// RootDigestConcat hashes the concatenation of the raw file hashes in
+	// name order. It is the default and the mode of the Python library.
+	RootDigestConcat RootDigestMode = "concat"
+
+	// RootDigestNameAndLength hashes, for every file in name order, the
+	// length of its name, the name, the length of its hash and the hash,
+	// lengths being big-endian uint64. Binding the names to the hashes
+	// removes any ambiguity about which file a hash belongs to.
+	RootDigestNameAndLength RootDigestMode = "name-and-length"
+)
+
+// Method is the serialization method of the Python library a manifest is
+// produced with, which decides how files map to descriptors.
+type Method string
+
+const (
+	// FilesMethod records a descriptor per file, hashed whole. It is the
+	// default and matches the "files" method of model_signing 1.0 and
+	// later (hashing_config.use_file_serialization()), the only method of
+	// the earlier releases. A positive ShardSize still splits the files
+	// larger than it, which the Python library does not do.
+	FilesMethod Method = "files"
+
+	// ShardsMethod splits every file in shards of ShardSize bytes
+	// (DefaultShardSize when ShardSize is not positive) and records a
+	// descriptor per shard named "<file>:<start>:<end>", even for files
+	// holding a single shard. Empty files have no shards and are not
+	// recorded. It matches the "shards" method of model_signing 1.0 and
+	// later (hashing_config.use_shard_serialization()).
+	ShardsMethod Method = "shards"
+)
+
+// DefaultShardSize is the shard size of ShardsMethod when ShardSize is not
+// set, the default of the Python library.
+const DefaultShardSize int64 = 1_000_000_000
+
+// DigestEncoding is the text encoding of the digests recorded in a
+// manifest and of the root digest.
+type DigestEncoding string
+
+const (
+	// DigestEncodingHex encodes the digests in lowercase hexadecimal, as
+	// in-toto and the Python library expect. It is the default.
+	DigestEncodingHex DigestEncoding = "hex"
+
+	// DigestEncodingBase64 encodes the digests in standard base64, with
+	// padding (RFC 4648, section 4).
+	DigestEncodingBase64 DigestEncoding = "base64"
+
+	// DigestEncodingBase64URL encodes the digests in URL-safe base64,
+	// without padding (RFC 4648, section 5).
+	DigestEncodingBase64URL DigestEncoding = "base64url"
+)
+
+// Normalization is the Unicode normalization form applied to the file
+// names recorded in a manifest.
+type Normalization string
+
+const (
+	// NormalizationNFC composes the names (the default), as most Linux
+	// and Windows tools write them.
+	NormalizationNFC Normalization = "NFC"
+
+	// NormalizationNFD decomposes the names, as older macOS file systems
+	// store them.
+	NormalizationNFD Normalization = "NFD"
+
+	// NormalizationNone records the names byte for byte as read.
+	NormalizationNone Normalization = "none"
+)
+
+// SymlinkPolicy selects how the symbolic links found in a model are
+// handled.
+type SymlinkPolicy string
+
+const (
+	// SymlinkReject fails the serialization on any symlink.
+	SymlinkReject SymlinkPolicy = "reject"
+
+	// SymlinkFollowInternal follows the links to regular files and
+	// directories within the model directory, hashing their targets under
+	// the name of the link. Links escaping the model fail the
+	// serialization, unless SkipExternalSymlinks is set. Directory links
+	// pointing to one of their own parents fail with ErrSymlinkLoop. It
+	// is the policy to use when signing untrusted trees with links.
+	SymlinkFollowInternal SymlinkPolicy = "follow-internal"
+
+	// SymlinkFollowAll follows every link like SymlinkFollowInternal,
+	// including those pointing outside of the model directory, whose
+	// targets are hashed as if they were part of the model. With
+	// ConfineToRoot, links escaping the root still cannot be followed.
+	SymlinkFollowAll SymlinkPolicy = "follow-all"
+
+	// SymlinkRecordLink records every link without following it, as a
+	// descriptor named after the link whose digest is the hash of its
+	// target path (slash-separated, as stored in the link) and annotated
+	// with that target under "linkTarget". Changing where a link points
+	// changes the digest, yet no file outside of the model is ever read
+	// and dangling links are recorded too. Reading link targets requires
+	// a model directory, a tar archive or a file system able to read
+	// links.
+	SymlinkRecordLink SymlinkPolicy = "record-link"
+)
+
+// Hasher creates the hash functions the files are digested with, to plug
+// in other implementations of the algorithms (hardware accelerated or
+// remote ones, or mocks in tests).
+type Hasher interface {
+	// NewHash returns a new hash.Hash computing algo, or nil if the
+	// algorithm is not supported. It may be called concurrently.
+	NewHash(algo intoto.HashAlgorithm) hash.Hash
+}
+
 // Options configures the serialization behavior.
+//
+// Digests only cover file names and contents. Ownership, permission bits
+// and timestamps never reach the root digest, so serializing the same
+// tree as different users (or under different umasks) yields the same
+// digest: a user lacking read access gets an error, never a different
+// digest. The only user-sensitive option is RecordPermissions, whose
+// annotations reflect the umask the files were created with.
 type Options struct {
 	// IgnorePaths is a list of paths to ignore during serialization.
-	// If a path is a directory, all children are ignored.
+	// Relative paths are always relative to the model root, never to the
+	// working directory, and absolute paths must point inside the model.
+	// If a path is a directory, all children are ignored. Plain paths are
+	// anchored at the root and compared component by component: "data"
+	// ignores the file or the directory named data at the root, never
+	// data-extra, database.bin or sub/data. "data", "./data" and "data/"
+	// are equivalent, except that a trailing slash only matches a
+	// directory. Entries using wildcards (*, ?, [...], **) or a leading !
+	// for negation are matched as gitignore patterns, so "*.tmp" or
+	// "**/checkpoints" work as in a .gitignore file; a leading "./"
+	// anchors them at the root, as in "./*.tmp".
 	IgnorePaths []string
 
+	// IncludePatterns, when not empty, restricts the files serialized to
+	// those matching one of these gitignore patterns, like
+	// "*.safetensors" or "weights/". Files are first selected by the
+	// include patterns, then the ignore options apply to them, so a file
+	// must be included and not ignored to be hashed. Directories are
+	// always walked, and a single-file model is always serialized.
+	IncludePatterns []string
+
+	// IgnoreExtensions ignores the files whose name ends in one of these
+	// extensions, like ".log" or ".tmp" (the leading dot is optional).
+	// Multi-part extensions like ".tar.gz" work too. Directories are never
+	// matched by extension.
+	IgnoreExtensions []string
+
+	// AllowExternalIgnorePaths accepts IgnorePaths pointing outside of the
+	// model directory, skipping them. By default such entries (absolute
+	// paths elsewhere or relative paths starting with "..") make the
+	// serialization fail, as they are usually a misspelled path.
+	AllowExternalIgnorePaths bool
+
+	// CaseInsensitiveIgnores matches the IgnorePaths, IgnoreExtensions,
+	// the VCS paths and the .gitignore patterns ignoring case, so
+	// "README.md" also ignores "readme.md" as it would on a
+	// case-insensitive file system.
+	CaseInsensitiveIgnores bool
+
 	// IgnoreGitPaths controls whether git-related files are ignored.
-	// When true (default), .git/, .gitignore, .gitattributes, and .github/ are ignored.
+	// When true (default), .git/, .gitignore, .gitattributes, and .github/ are ignored
+	// at any depth, so the .git of a submodule is ignored like the top-level one,
+	// whether or not the model root is itself part of a git checkout.
 	IgnoreGitPaths bool
 
+	// VCSIgnorePaths, when not nil, replaces the git paths ignored with
+	// IgnoreGitPaths, e.g. with []string{".hg", ".hgignore", ".svn"} for
+	// other version control systems. Entries are relative to the model
+	// root and follow the IgnorePaths syntax. An empty, non-nil list
+	// ignores nothing.
+	VCSIgnorePaths []string
+
+	// SkipHidden ignores the files and directories whose name starts with
+	// a dot, like .DS_Store or .ipynb_checkpoints/, and everything below
+	// them. It applies on top of IgnoreGitPaths and IgnorePaths.
+	SkipHidden bool
+
+	// RecordEmptyDirs records every directory without files to hash as a
+	// marker descriptor named after the directory with a trailing slash
+	// ("cache/"), holding the digest of empty contents. Markers take part
+	// in the root digest like files do, so adding or removing an empty
+	// directory changes it. Directories whose files are all ignored count
+	// as empty. Without it, directories are not recorded at all.
+	RecordEmptyDirs bool
+
+	// RespectGitignore applies the patterns of every .gitignore file found
+	// in the model tree, each one scoped to its own directory as git does.
+	// IgnorePaths always take precedence over them.
+	RespectGitignore bool
+
+	// RespectIgnoreFile applies the gitignore patterns of the
+	// .modelsigningignore file found at the model root, so the ignore
+	// rules can be versioned with the model. They are matched like the
+	// IgnorePaths, a path ignored by either of them being ignored, and
+	// take precedence over the .gitignore files. The ignore file itself
+	// is hashed as any other file unless it is ignored.
+	RespectIgnoreFile bool
+
+	// SymlinkPolicy selects how symbolic links are handled. Empty means
+	// SymlinkFollowInternal if AllowSymlinks is set, SymlinkReject
+	// otherwise (the default).
+	SymlinkPolicy SymlinkPolicy
+
 	// AllowSymlinks controls whether symbolic links are included.
 	// If false (default) and a symlink is encountered, an error is returned.
+	// When true, links to regular files and directories within the model
+	// directory are followed, their targets hashed under the name of the
+	// link. Directory links pointing to one of their own parents fail with
+	// ErrSymlinkLoop.
+	//
+	// Deprecated: set SymlinkPolicy to SymlinkFollowInternal instead. It
+	// only applies when SymlinkPolicy is empty.
 	AllowSymlinks bool
+
+	// SkipExternalSymlinks skips the symlinks whose target lies outside of
+	// the model directory instead of failing the serialization, under the
+	// SymlinkFollowInternal policy. Such links are not followed, to avoid
+	// hashing arbitrary files of the system. With ConfineToRoot, every link
+	// that cannot be resolved within the root is skipped.
+	SkipExternalSymlinks bool
+
+	// SkipSpecialFiles skips the devices, named pipes and sockets found in
+	// the model, which otherwise fail the serialization with
+	// ErrUnsupportedFileType so they are not silently left out of the
+	// digest. Ignored special files never fail it.
+	SkipSpecialFiles bool
+
+	// HuggingFaceSnapshot serializes a snapshot directory of the Hugging
+	// Face cache (<cache>/models--org--name/snapshots/<revision>), whose
+	// files are symlinks to the repository blobs/ directory. Links to the
+	// blobs are followed whatever the SymlinkPolicy, and their contents
+	// recorded under the snapshot-relative names of the links; any other
+	// link follows the SymlinkPolicy. The model name is the repository id
+	// ("org/name"). It cannot be combined with ConfineToRoot.
+	HuggingFaceSnapshot bool
+
+	// ModelName, when set, is recorded as the name of the model instead of
+	// the one derived from the model path (its base name, the repository
+	// id with HuggingFaceSnapshot, none for archives and multiple models),
+	// which is meaningless for temporary directories. It names the model
+	// subject of the statements of the manifest. The root digest does not
+	// depend on it.
+	ModelName string
+
+	// ConfineToRoot performs every read of the model directory through an
+	// os.Root opened at the model path. Paths resolving outside of the
+	// model (through symlinks or ".." components) fail to open, making
+	// this the mode to use when serializing untrusted inputs.
+	ConfineToRoot bool
+
+	// ExternalFiles declares files living outside of the model directory
+	// that are part of its integrity boundary (a shared vocabulary, for
+	// example). Keys are the names recorded in the manifest and values the
+	// paths to read. Names must be relative, slash-separated and must not
+	// collide with any file of the model. ExternalFiles are read directly
+	// from their paths, ConfineToRoot does not apply to them.
+	ExternalFiles map[string]string
+
+	// PostHash, when set, is called for every file after it is hashed and
+	// before its descriptor is added to the manifest. It receives the
+	// manifest name of the file and its hex-encoded digest. Returning false
+	// excludes the file (it is then listed in Manifest.Excluded), returning
+	// an error aborts the serialization. Files split in shards are checked
+	// shard by shard, with the shard names; rejecting a shard excludes the
+	// whole file, listed in Excluded under the file name.
+	PostHash func(name, digest string) (include bool, err error)
+
+	// FilterFunc, when set, is called during the walk for every file and
+	// directory the built-in ignore checks keep. It receives the name of
+	// the entry relative to the model root, slash-separated, and its file
+	// information. Returning false skips the entry (and everything under
+	// it for a directory), returning an error aborts the serialization.
+	FilterFunc func(path string, info os.FileInfo) (keep bool, err error)
+
+	// OnWalkError, when set, is called with the errors the walk of the
+	// model tree finds listing a directory or reading the information of
+	// an entry, as on unreadable directories or flaky network mounts. It
+	// receives the name of the entry relative to the model root,
+	// slash-separated, and the error. Returning nil skips the entry (and
+	// everything under it for a directory), returning an error aborts the
+	// serialization, wrapped in ErrWalkFailed as walk errors are when it is
+	// unset.
+	OnWalkError func(path string, err error) error
+
+	// ResourceAnnotator, when set, is called for every file hashed with
+	// its name relative to the model root, slash-separated, and its file
+	// information (nil when the file cannot be stat'ed, as for the
+	// streams of HashReader). The annotations it returns, like a content
+	// type or a license, are added to the descriptors of the file and
+	// travel with the manifest into its statement. Values must be
+	// representable as JSON. The annotations recorded by the serializer
+	// itself take precedence, and digests never depend on annotations.
+	// The digest cache is not used with it.
+	ResourceAnnotator func(path string, info os.FileInfo) map[string]any
+
+	// NameNormalization is the Unicode normalization form applied to the
+	// file names before they are recorded and sorted, so a model gets the
+	// same root digest whether its file system stores composed or
+	// decomposed names. Empty means NFC. Ignore paths are matched against
+	// the names as read from the file system.
+	NameNormalization Normalization
+
+	// DecompressExtensions maps file name suffixes (like ".gz") to a
+	// compression format. Matching files are hashed over their
+	// decompressed contents so the digest does not depend on the
+	// compression level, and their descriptors are annotated with the
+	// format removed. Corrupt compressed files fail the serialization.
+	DecompressExtensions map[string]Compression
+
+	// HashAlgorithm is the algorithm used to hash every file and to compute
+	// the root digest. Defaults to SHA256. The default hasher supports
+	// sha256, sha384, sha512, sha3_256, sha3_384, sha3_512 and
+	// AlgorithmBLAKE3.
+	HashAlgorithm intoto.HashAlgorithm
+
+	// Hasher computes the file digests. Nil uses the default hasher of
+	// the serializer, backed by the carabiner hasher library and
+	// supporting the algorithms listed above. Root
+	// digests are always computed with the default implementations, so a
+	// Hasher must compute the standard algorithms it is asked for.
+	Hasher Hasher
+
+	// RootDigestMode selects how the root digest combines the file
+	// hashes. Empty means RootDigestConcat, which existing signatures use.
+	RootDigestMode RootDigestMode
+
+	// DigestEncoding is the encoding of the file digests recorded in the
+	// manifest and of the root digest ComputeRootDigest and ComputeDigest
+	// return. Empty means DigestEncodingHex. It never changes the root
+	// digest value, only its text form. The digests given to PostHash are
+	// always hex, and so are those of statements, as in-toto requires.
+	DigestEncoding DigestEncoding
+
+	// IncludeMetadataInDigest folds the annotations of every file, like
+	// its RecordSizes size or RecordPermissions mode, into the root
+	// digest, so a signature covers them too and not only the file
+	// contents. After the digest of each file, the preimage of the root
+	// digest gets the length of the canonical JSON encoding of its
+	// annotations as a big-endian uint64, followed by the encoding itself
+	// ("{}" for a file without annotations); see RootDigestPreimage. The
+	// manifest records the setting. Defaults to false, the root digest of
+	// the existing signatures.
+	IncludeMetadataInDigest bool
+
+	// Algorithms lists extra algorithms to record in every file
+	// descriptor besides HashAlgorithm, so a single manifest can be
+	// consumed by verifiers expecting different digests. They do not
+	// change the root digest, which is computed with HashAlgorithm.
+	Algorithms []intoto.HashAlgorithm
+
+	// Concurrency is the number of files hashed in parallel. Zero (the
+	// default) uses one worker per CPU. The manifest is the same
+	// regardless of the value.
+	Concurrency int
+
+	// LargestFirst hashes the largest files first when several workers
+	// run (see Concurrency), so a large file found late in the walk does
+	// not leave the other workers idle while it is hashed alone. The
+	// whole walk then runs before any file is hashed, to learn the file
+	// sizes. The manifest is the same either way.
+	LargestFirst bool
+
+	// UseMmap memory-maps the files of 64 MiB or more instead of reading
+	// them, which hashes large files faster on many systems. Files that
+	// cannot be mapped, and all of them on platforms without mmap, are
+	// read as usual. A file truncated while mapped can crash the process,
+	// so do not use it on files that may change during the serialization.
+	UseMmap bool
+
+	// CachePath, when set, is the file caching the digests of the model
+	// files between runs. Files whose size, modification time and mode did
+	// not change since they were cached, hashed with the same settings,
+	// are not read again. Anyone able to write the cache can forge the
+	// digests it holds: leave CachePath empty (the default) to disable the
+	// cache for security-sensitive runs. The cache file should live
+	// outside of the model directory, or be ignored.
+	CachePath string
+
+	// MaxFiles, when positive, is the maximum number of files a model may
+	// hold. Larger models fail with ErrLimitExceeded.
+	MaxFiles int
+
+	// MaxTotalBytes, when positive, is the maximum size of all the files
+	// of a model added up, as stored on disk. Larger models fail with
+	// ErrLimitExceeded.
+	//
+	// With either limit set, the model is walked in full before hashing
+	// starts, so an oversized model fails without reading any file.
+	// Archives are checked as their entries are read. ExternalFiles do
+	// not count towards the limits.
+	MaxTotalBytes int64
+
+	// MaxFileSize, when positive, ignores the regular files larger than
+	// it, in bytes, like logs or checkpoints left in the model directory.
+	// Unlike MaxFiles and MaxTotalBytes it does not fail the serialization:
+	// the files are left out, and DryRun lists them as ignored. Archive
+	// entries are checked by their size in the archive; ExternalFiles are
+	// never ignored.
+	MaxFileSize int64
+
+	// RecordSizes records the size in bytes of every file, as read from
+	// disk, in the "size" annotation of its descriptor. Sizes do not
+	// change the root digest.
+	RecordSizes bool
+
+	// Method is the serialization method of the Python library to match,
+	// so the manifest verifies against signatures made with it. Empty
+	// means FilesMethod.
+	Method Method
+
+	// ShardSize, when positive, splits the files larger than it into
+	// shards of ShardSize bytes hashed independently. Each shard is
+	// recorded as its own descriptor named "<file>:<start>:<end>", like in
+	// the sharded manifests of the Python library. Files not larger than
+	// ShardSize keep a single descriptor with their plain name, unless
+	// Method is ShardsMethod.
+	ShardSize int64
+
+	// RejectControlChars fails the serialization with ErrUnsafePath when a
+	// file name holds a control character, like a newline, a tab or NUL,
+	// which could confuse the tools parsing manifests line by line. It is
+	// set by default; names are never escaped, so turning it off records
+	// them as found.
+	RejectControlChars bool
+
+	// ContinueOnError leaves out of the manifest the files that cannot be
+	// read or hashed (permission denied, corrupt compressed files...)
+	// instead of failing on the first one. The partial manifest is then
+	// returned along with an ErrPartial error joining the failure of every
+	// file left out, so callers decide whether it is fatal. Walk errors
+	// and cancellation still fail the serialization. ExternalFiles are
+	// covered too; archives, read as a stream, are not.
+	ContinueOnError bool
+
+	// Strict rejects every input that could make the digest of a model
+	// differ between machines, as a preset of the individual guards:
+	// symlinks fail whatever the SymlinkPolicy (so HuggingFaceSnapshot
+	// cannot be used), devices, named pipes and sockets fail even with
+	// SkipSpecialFiles, names holding control characters fail as with
+	// RejectControlChars, and names that only differ by their Unicode
+	// normalization fail with ErrDuplicateName whatever the
+	// NameNormalization, like any duplicate name.
+	Strict bool
+
+	// RecordPermissions records the Unix mode of every file (permission
+	// bits plus setuid, setgid and sticky) as an octal string in the
+	// "mode" annotation of its descriptor, and makes Verify report
+	// permission changes. On Windows only the bits Go can represent are
+	// recorded: read/write for everyone, or read-only.
+	RecordPermissions bool
 }
 
 // DefaultOptions returns the default options matching the Python implementation.
 func Default() *Options {
 	return &Options{
-		IgnorePaths:    []string{},
-		IgnoreGitPaths: true,
-		AllowSymlinks:  false,
+		IgnorePaths:              []string{},
+		IncludePatterns:          []string{},
+		IgnoreExtensions:         []string{},
+		AllowExternalIgnorePaths: false,
+		CaseInsensitiveIgnores:   false,
+		IgnoreGitPaths:           true,
+		VCSIgnorePaths:           nil,
+		SkipHidden:               false,
+		RecordEmptyDirs:          false,
+		RespectGitignore:         false,
+		RespectIgnoreFile:        false,
+		SymlinkPolicy:            "",
+		AllowSymlinks:            false,
+		SkipExternalSymlinks:     false,
+		SkipSpecialFiles:         false,
+		HuggingFaceSnapshot:      false,
+		ModelName:                "",
+		ConfineToRoot:            false,
+		ExternalFiles:            map[string]string{},
+		NameNormalization:        NormalizationNFC,
+		DecompressExtensions:     map[string]Compression{},
+		HashAlgorithm:            intoto.AlgorithmSHA256,
+		Hasher:                   nil,
+		RootDigestMode:           RootDigestConcat,
+		DigestEncoding:           DigestEncodingHex,
+		IncludeMetadataInDigest:  false,
+		Algorithms:               []intoto.HashAlgorithm{},
+		Concurrency:              0,
+		LargestFirst:             false,
+		UseMmap:                  false,
+		CachePath:                "",
+		MaxFiles:                 0,
+		MaxTotalBytes:            0,
+		MaxFileSize:              0,
+		RecordSizes:              false,
+		Method:                   FilesMethod,
+		ShardSize:                0,
+		RecordPermissions:        false,
+		RejectControlChars:       true,
+		ContinueOnError:          false,
+		Strict:                   false,
 	}
 }
@@ -0,0 +1,116 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+// Package sign signs model manifests with sigstore. It is kept apart from
+// the serializer so programs that only compute digests do not link the
+// sigstore dependencies.
+package sign
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/sigstore/sigstore-go/pkg/bundle"
+	sigstore "github.com/sigstore/sigstore-go/pkg/sign"
+
+	"github.com/carabiner-dev/model-signing/internal/serializer/dir"
+)
+
+const (
+	// DefaultFulcioURL is the Fulcio instance of the sigstore public good
+	// infrastructure.
+	DefaultFulcioURL = "https://fulcio.sigstore.dev"
+
+	// DefaultRekorURL is the Rekor instance of the sigstore public good
+	// infrastructure.
+	DefaultRekorURL = "https://rekor.sigstore.dev"
+)
+
+// ErrNoIDToken is returned when signing without an OIDC identity token.
+var ErrNoIDToken = errors.New("no OIDC identity token")
+
+// Options configure the sigstore keyless signing flow.
+type Options struct {
+	// IDToken is the OIDC identity token exchanged with Fulcio for the
+	// short-lived signing certificate. It is required.
+	IDToken string
+
+	// FulcioURL is the base URL of the Fulcio certificate authority.
+	FulcioURL string
+
+	// RekorURL is the base URL of the Rekor transparency log.
+	RekorURL string
+
+	// CertificateProvider, when set, issues the signing certificate
+	// instead of a Fulcio client for FulcioURL.
+	CertificateProvider sigstore.CertificateProvider
+
+	// TransparencyLogs, when set, record the signature instead of a Rekor
+	// client for RekorURL.
+	TransparencyLogs []sigstore.Transparency
+}
+
+// DefaultOptions returns options signing with the sigstore public good
+// infrastructure. The IDToken must still be set.
+func DefaultOptions() *Options {
+	return &Options{
+		FulcioURL: DefaultFulcioURL,
+		RekorURL:  DefaultRekorURL,
+	}
+}
+
+// SignManifest signs the manifest with the sigstore keyless flow: it
+// generates an ephemeral key, gets a certificate binding it to the identity
+// of opts.IDToken from Fulcio, signs the in-toto statement of the manifest
+// (see dir.Manifest.StatementPayload) into a DSSE envelope and records the
+// signature in Rekor. The returned bundle holds everything a verifier needs.
+func SignManifest(ctx context.Context, manifest *dir.Manifest, opts *Options) (*bundle.Bundle, error) {
+	if opts == nil {
+		opts = DefaultOptions()
+	}
+	if opts.IDToken == "" {
+		return nil, ErrNoIDToken
+	}
+
+	payload, err := manifest.StatementPayload()
+	if err != nil {
+		return nil, fmt.Errorf("building statement: %w", err)
+	}
+
+	keypair, err := sigstore.NewEphemeralKeypair(nil)
+	if err != nil {
+		return nil, fmt.Errorf("generating signing key: %w", err)
+	}
+
+	certificateProvider := opts.CertificateProvider
+	if certificateProvider == nil {
+		certificateProvider = sigstore.NewFulcio(&sigstore.FulcioOptions{BaseURL: opts.FulcioURL})
+	}
+	transparencyLogs := opts.TransparencyLogs
+	if transparencyLogs == nil {
+		transparencyLogs = []sigstore.Transparency{
+			sigstore.NewRekor(&sigstore.RekorOptions{BaseURL: opts.RekorURL}),
+		}
+	}
+
+	pb, err := sigstore.Bundle(
+		&sigstore.DSSEData{Data: payload, PayloadType: dir.PayloadTypeInToto},
+		keypair,
+		sigstore.BundleOptions{
+			CertificateProvider:        certificateProvider,
+			CertificateProviderOptions: &sigstore.CertificateProviderOptions{IDToken: opts.IDToken},
+			TransparencyLogs:           transparencyLogs,
+			Context:                    ctx,
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("signing statement: %w", err)
+	}
+
+	b, err := bundle.NewBundle(pb)
+	if err != nil {
+		return nil, fmt.Errorf("building bundle: %w", err)
+	}
+	return b, nil
+}
@@ -0,0 +1,151 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package sign
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	protobundle "github.com/sigstore/protobuf-specs/gen/pb-go/bundle/v1"
+	sigstore "github.com/sigstore/sigstore-go/pkg/sign"
+
+	"github.com/carabiner-dev/model-signing/internal/serializer/dir"
+)
+
+// testCA issues certificates for any key, standing in for Fulcio.
+type testCA struct {
+	key  *ecdsa.PrivateKey
+	cert *x509.Certificate
+	// token is the identity token of the last request
+	token string
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate CA key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create CA certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Failed to parse CA certificate: %v", err)
+	}
+	return &testCA{key: key, cert: cert}
+}
+
+func (ca *testCA) GetCertificate(_ context.Context, keypair sigstore.Keypair, opts *sigstore.CertificateProviderOptions) ([]byte, error) {
+	ca.token = opts.IDToken
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(10 * time.Minute),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	}
+	return x509.CreateCertificate(rand.Reader, template, ca.cert, keypair.GetPublicKey(), ca.key)
+}
+
+// noopLog accepts every signature without recording it.
+type noopLog struct{ calls int }
+
+func (l *noopLog) GetTransparencyLogEntry(context.Context, []byte, *protobundle.Bundle) error {
+	l.calls++
+	return nil
+}
+
+func newTestManifest(t *testing.T) *dir.Manifest {
+	t.Helper()
+	modelPath := t.TempDir()
+	if err := os.WriteFile(filepath.Join(modelPath, "model.bin"), []byte("weights"), 0o644); err != nil {
+		t.Fatalf("Failed to write model: %v", err)
+	}
+	manifest, err := dir.New(nil).Serialize(modelPath)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	return manifest
+}
+
+func TestSignManifest(t *testing.T) {
+	manifest := newTestManifest(t)
+	ca := newTestCA(t)
+	log := &noopLog{}
+
+	opts := DefaultOptions()
+	opts.IDToken = "token"
+	opts.CertificateProvider = ca
+	opts.TransparencyLogs = []sigstore.Transparency{log}
+
+	b, err := SignManifest(context.Background(), manifest, opts)
+	if err != nil {
+		t.Fatalf("SignManifest failed: %v", err)
+	}
+	if ca.token != "token" || log.calls != 1 {
+		t.Errorf("Expected the token sent to the CA and one log entry, got %q and %d", ca.token, log.calls)
+	}
+
+	// The envelope holds the statement of the manifest
+	envelope := b.GetDsseEnvelope()
+	if envelope == nil {
+		t.Fatal("Bundle has no DSSE envelope")
+	}
+	payload, err := manifest.StatementPayload()
+	if err != nil {
+		t.Fatalf("StatementPayload failed: %v", err)
+	}
+	if envelope.GetPayloadType() != dir.PayloadTypeInToto || !bytes.Equal(envelope.GetPayload(), payload) {
+		t.Errorf("Unexpected envelope payload %s: %s", envelope.GetPayloadType(), envelope.GetPayload())
+	}
+
+	// Signed by the key of the certificate
+	cert, err := x509.ParseCertificate(b.GetVerificationMaterial().GetCertificate().GetRawBytes())
+	if err != nil {
+		t.Fatalf("Failed to parse bundle certificate: %v", err)
+	}
+	if err := cert.CheckSignatureFrom(ca.cert); err != nil {
+		t.Errorf("Certificate not issued by the CA: %v", err)
+	}
+	preimage, err := manifest.DSSEPreimage("")
+	if err != nil {
+		t.Fatalf("DSSEPreimage failed: %v", err)
+	}
+	if len(envelope.GetSignatures()) != 1 {
+		t.Fatalf("Expected one signature, got %d", len(envelope.GetSignatures()))
+	}
+	digest := sha256.Sum256(preimage)
+	pub, ok := cert.PublicKey.(*ecdsa.PublicKey)
+	if !ok || !ecdsa.VerifyASN1(pub, digest[:], envelope.GetSignatures()[0].GetSig()) {
+		t.Error("Signature does not verify with the certificate key")
+	}
+}
+
+func TestSignManifestNoToken(t *testing.T) {
+	manifest := newTestManifest(t)
+	if _, err := SignManifest(context.Background(), manifest, nil); !errors.Is(err, ErrNoIDToken) {
+		t.Errorf("Expected ErrNoIDToken, got %v", err)
+	}
+}
@@ -0,0 +1,148 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package sign
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	intoto "github.com/in-toto/attestation/go/v1"
+	"github.com/sigstore/sigstore-go/pkg/root"
+	"github.com/sigstore/sigstore-go/pkg/verify"
+
+	"github.com/carabiner-dev/model-signing/internal/serializer/dir"
+	"github.com/carabiner-dev/model-signing/internal/serializer/options"
+)
+
+var (
+	// ErrNoIdentity is returned when verifying without an expected signer
+	// identity: both its issuer and its identity are needed, as an empty
+	// one would accept any.
+	ErrNoIdentity = errors.New("no signer identity to verify")
+
+	// ErrSignatureInvalid is returned by VerifyBundle when the bundle
+	// signature, certificate chain, transparency log entry or signer
+	// identity does not verify.
+	ErrSignatureInvalid = errors.New("invalid signature")
+
+	// ErrNotModelStatement is returned by VerifyBundle when the bundle
+	// verifies but does not sign a model signature statement.
+	ErrNotModelStatement = errors.New("not a model signature statement")
+)
+
+// VerifyOptions configure VerifyBundle.
+type VerifyOptions struct {
+	// TrustedMaterial holds the certificate authorities and transparency
+	// logs to trust. When nil, the trusted root of the sigstore public
+	// good infrastructure is fetched with TUF.
+	TrustedMaterial root.TrustedMaterial
+
+	// Issuer is the expected OIDC issuer of the signing certificate.
+	// Required.
+	Issuer string
+
+	// Identity is the expected subject alternative name of the signing
+	// certificate, such as an email address. Required.
+	Identity string
+
+	// Serializer are the options to serialize the model with. The hash
+	// algorithm, root digest mode and IncludeMetadataInDigest are taken
+	// from the signed statement; when the latter is set, the options must
+	// record the annotations the signer did, like RecordSizes.
+	Serializer *options.Options
+}
+
+// VerifyBundle checks that the model at modelPath is the one signed in the
+// bundle: it verifies the bundle signature, certificate chain and
+// transparency log entry against the trusted material and the signer
+// identity, then serializes the model and compares its root digest with
+// the one of the signed statement. A bad signature fails with an error
+// wrapping ErrSignatureInvalid, a different model with one wrapping
+// dir.ErrRootDigestMismatch.
+func VerifyBundle(ctx context.Context, modelPath string, bundle verify.SignedEntity, opts *VerifyOptions) error {
+	if opts == nil {
+		opts = &VerifyOptions{}
+	}
+	if opts.Issuer == "" || opts.Identity == "" {
+		return ErrNoIdentity
+	}
+
+	trustedMaterial := opts.TrustedMaterial
+	if trustedMaterial == nil {
+		trustedRoot, err := root.FetchTrustedRoot()
+		if err != nil {
+			return fmt.Errorf("fetching trusted root: %w", err)
+		}
+		trustedMaterial = trustedRoot
+	}
+
+	verifier, err := verify.NewVerifier(trustedMaterial, verify.WithTransparencyLog(1), verify.WithIntegratedTimestamps(1))
+	if err != nil {
+		return fmt.Errorf("creating verifier: %w", err)
+	}
+	identity, err := verify.NewShortCertificateIdentity(opts.Issuer, "", opts.Identity, "")
+	if err != nil {
+		return fmt.Errorf("building signer identity: %w", err)
+	}
+	result, err := verifier.Verify(bundle, verify.NewPolicy(verify.WithoutArtifactUnsafe(), verify.WithCertificateIdentity(identity)))
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrSignatureInvalid, err)
+	}
+
+	algo, expected, mode, err := signedRootDigest(result.Statement)
+	if err != nil {
+		return err
+	}
+
+	// Statements carry hex digests
+	o := options.Default()
+	if opts.Serializer != nil {
+		o = new(options.Options)
+		*o = *opts.Serializer
+	}
+	o.HashAlgorithm = algo
+	o.RootDigestMode = mode
+	o.DigestEncoding = options.DigestEncodingHex
+	o.IncludeMetadataInDigest = result.Statement.GetPredicate().GetFields()["metadataInDigest"].GetBoolValue()
+
+	manifest, err := dir.New(o).SerializeContext(ctx, modelPath)
+	if err != nil {
+		return fmt.Errorf("serializing model: %w", err)
+	}
+	actual, err := dir.ComputeRootDigest(manifest)
+	if err != nil {
+		return fmt.Errorf("computing root digest: %w", err)
+	}
+	if !strings.EqualFold(actual, expected) {
+		return fmt.Errorf("%w: expected %s:%s, got %s:%s", dir.ErrRootDigestMismatch, algo, expected, algo, actual)
+	}
+	return nil
+}
+
+// signedRootDigest returns the root digest of a model signature statement,
+// its hash algorithm and the root digest mode of the predicate. The root
+// digest is the first subject, see dir.Manifest.ToStatement.
+func signedRootDigest(statement *intoto.Statement) (intoto.HashAlgorithm, string, options.RootDigestMode, error) {
+	if statement == nil {
+		return "", "", "", fmt.Errorf("%w: bundle holds no in-toto statement", ErrNotModelStatement)
+	}
+	if statement.GetPredicateType() != dir.PredicateType {
+		return "", "", "", fmt.Errorf("%w: predicate type %q", ErrNotModelStatement, statement.GetPredicateType())
+	}
+	if len(statement.GetSubject()) == 0 || len(statement.GetSubject()[0].GetDigest()) != 1 {
+		return "", "", "", fmt.Errorf("%w: no root digest subject", ErrNotModelStatement)
+	}
+
+	mode := options.RootDigestConcat
+	if value, ok := statement.GetPredicate().GetFields()["rootDigestMode"]; ok {
+		mode = options.RootDigestMode(value.GetStringValue())
+	}
+	var algo, digest string
+	for algo, digest = range statement.GetSubject()[0].GetDigest() {
+		break
+	}
+	return intoto.HashAlgorithm(algo), digest, mode, nil
+}
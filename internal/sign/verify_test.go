@@ -0,0 +1,163 @@
+// SPDX-FileCopyrightText: Copyright 2025 Carabiner Systems, Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package sign
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sigstore/sigstore-go/pkg/testing/ca"
+
+	"github.com/carabiner-dev/model-signing/internal/serializer/dir"
+	"github.com/carabiner-dev/model-signing/internal/serializer/options"
+)
+
+func TestVerifyBundle(t *testing.T) {
+	const (
+		identity = "signer@example.com"
+		issuer   = "https://issuer.example.com"
+	)
+
+	sigstore, err := ca.NewVirtualSigstore()
+	if err != nil {
+		t.Fatalf("Failed to create virtual sigstore: %v", err)
+	}
+	other, err := ca.NewVirtualSigstore()
+	if err != nil {
+		t.Fatalf("Failed to create virtual sigstore: %v", err)
+	}
+
+	modelPath := t.TempDir()
+	for name, content := range map[string]string{
+		"config.json":      `{"layers": 2}`,
+		"model.bin":        "weights",
+		"subdir/layer.bin": "layer",
+	} {
+		path := filepath.Join(modelPath, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	attest := func(t *testing.T, opts *options.Options) ([]byte, error) {
+		t.Helper()
+		manifest, err := dir.New(opts).Serialize(modelPath)
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+		return manifest.StatementPayload()
+	}
+
+	payload, err := attest(t, nil)
+	if err != nil {
+		t.Fatalf("StatementPayload failed: %v", err)
+	}
+	entity, err := sigstore.Attest(identity, issuer, payload)
+	if err != nil {
+		t.Fatalf("Attest failed: %v", err)
+	}
+
+	opts := &VerifyOptions{TrustedMaterial: sigstore, Issuer: issuer, Identity: identity}
+	ctx := context.Background()
+
+	t.Run("valid", func(t *testing.T) {
+		if err := VerifyBundle(ctx, modelPath, entity, opts); err != nil {
+			t.Errorf("VerifyBundle failed: %v", err)
+		}
+	})
+
+	t.Run("algorithm and mode from statement", func(t *testing.T) {
+		payload, err := attest(t, options.Default().Apply(
+			options.WithHashAlgorithm("sha512"),
+			options.WithRootDigestMode(options.RootDigestNameAndLength),
+			options.WithDigestEncoding(options.DigestEncodingBase64),
+		))
+		if err != nil {
+			t.Fatalf("StatementPayload failed: %v", err)
+		}
+		entity, err := sigstore.Attest(identity, issuer, payload)
+		if err != nil {
+			t.Fatalf("Attest failed: %v", err)
+		}
+		if err := VerifyBundle(ctx, modelPath, entity, opts); err != nil {
+			t.Errorf("VerifyBundle failed: %v", err)
+		}
+	})
+
+	t.Run("metadata in digest", func(t *testing.T) {
+		payload, err := attest(t, options.Default().Apply(
+			options.WithRecordSizes(true),
+			options.WithIncludeMetadataInDigest(true),
+		))
+		if err != nil {
+			t.Fatalf("StatementPayload failed: %v", err)
+		}
+		entity, err := sigstore.Attest(identity, issuer, payload)
+		if err != nil {
+			t.Fatalf("Attest failed: %v", err)
+		}
+		withSizes := *opts
+		withSizes.Serializer = options.Default().Apply(options.WithRecordSizes(true))
+		if err := VerifyBundle(ctx, modelPath, entity, &withSizes); err != nil {
+			t.Errorf("VerifyBundle failed: %v", err)
+		}
+		// Without the sizes, the metadata does not match
+		if err := VerifyBundle(ctx, modelPath, entity, opts); !errors.Is(err, dir.ErrRootDigestMismatch) {
+			t.Errorf("Expected ErrRootDigestMismatch, got %v", err)
+		}
+	})
+
+	t.Run("no identity", func(t *testing.T) {
+		for _, partial := range []*VerifyOptions{
+			{TrustedMaterial: sigstore},
+			{TrustedMaterial: sigstore, Issuer: issuer},
+			{TrustedMaterial: sigstore, Identity: identity},
+		} {
+			if err := VerifyBundle(ctx, modelPath, entity, partial); !errors.Is(err, ErrNoIdentity) {
+				t.Errorf("Issuer %q and identity %q: expected ErrNoIdentity, got %v", partial.Issuer, partial.Identity, err)
+			}
+		}
+	})
+
+	t.Run("wrong identity", func(t *testing.T) {
+		wrong := &VerifyOptions{TrustedMaterial: sigstore, Issuer: issuer, Identity: "someone@example.com"}
+		if err := VerifyBundle(ctx, modelPath, entity, wrong); !errors.Is(err, ErrSignatureInvalid) {
+			t.Errorf("Expected ErrSignatureInvalid, got %v", err)
+		}
+	})
+
+	t.Run("untrusted", func(t *testing.T) {
+		untrusted := &VerifyOptions{TrustedMaterial: other, Issuer: issuer, Identity: identity}
+		if err := VerifyBundle(ctx, modelPath, entity, untrusted); !errors.Is(err, ErrSignatureInvalid) {
+			t.Errorf("Expected ErrSignatureInvalid, got %v", err)
+		}
+	})
+
+	t.Run("not a model statement", func(t *testing.T) {
+		entity, err := sigstore.Attest(identity, issuer, []byte(`{"_type": "https://in-toto.io/Statement/v1", "subject": [{"name": "x", "digest": {"sha256": "00"}}], "predicateType": "https://example.com/other", "predicate": {}}`))
+		if err != nil {
+			t.Fatalf("Attest failed: %v", err)
+		}
+		if err := VerifyBundle(ctx, modelPath, entity, opts); !errors.Is(err, ErrNotModelStatement) {
+			t.Errorf("Expected ErrNotModelStatement, got %v", err)
+		}
+	})
+
+	// Last, it modifies the model
+	t.Run("modified model", func(t *testing.T) {
+		if err := os.WriteFile(filepath.Join(modelPath, "model.bin"), []byte("tampered"), 0o644); err != nil {
+			t.Fatalf("Failed to modify model: %v", err)
+		}
+		err := VerifyBundle(ctx, modelPath, entity, opts)
+		if !errors.Is(err, dir.ErrRootDigestMismatch) || errors.Is(err, ErrSignatureInvalid) {
+			t.Errorf("Expected only ErrRootDigestMismatch, got %v", err)
+		}
+	})
+}